@@ -0,0 +1,53 @@
+package logharbour
+
+import "errors"
+
+// ErrNotWritten is passed to the acknowledgment callback of LogActivityAck when the entry
+// was not written, for instance because it was filtered out by priority or sampling, the
+// Logger is in dry run mode, or an asynchronous writer dropped it.
+var ErrNotWritten = errors.New("log entry not written")
+
+// ackWriter is implemented by asynchronous writers that can tell when an entry has been
+// delivered to their sink. writeEntryAck writes entry and calls ack exactly once, when
+// the entry is delivered or fails to be, including when it cannot be accepted at all.
+type ackWriter interface {
+	writeEntryAck(entry LogEntry, ack func(error))
+}
+
+// LogActivityAck logs an activity entry as LogActivity does and calls ack with the outcome
+// once the entry has been written, for the rare entries that the caller must know are
+// stored before going on, such as the record of a payment:
+//
+//	done := make(chan error, 1)
+//	logger.LogActivityAck("payment captured", data, func(err error) { done <- err })
+//	if err := <-done; err != nil {
+//		// the entry may be lost
+//	}
+//
+// ack is called exactly once, with nil if the entry was written, with the validation error
+// if it failed validation, even when it was then written to the fallback writer, with
+// ErrNotWritten if it was not written, and with the write error otherwise.
+//
+// If the Logger's writer is an asynchronous writer that supports acknowledgments, that is
+// a CloudWatchWriter or a SplitWriter, ack is called from the writer's goroutine once the
+// entry has been delivered: for a CloudWatchWriter, when the flush that sends it is done,
+// with the first error of that flush, and for a SplitWriter, when the durable or buffered
+// writer has written it. Otherwise, including when such a writer is wrapped by another
+// writer, ack is called before LogActivityAck returns, once the writer's Write has
+// returned; for a writer that buffers entries, that only means the entry is buffered.
+// ack must not block for long, since it may hold up an asynchronous writer.
+// If ack panics, the panic is handled like that of other callbacks (see
+// LoggerContext.SetPropagatePanics), also on a writer's goroutine.
+func (l *Logger) LogActivityAck(message string, data ActivityInfo, ack func(error)) {
+	entry := l.newLogEntry(message, data)
+	entry.Type = Activity
+	l.logAck(entry, ack)
+}
+
+// acked calls ack, if not nil, with err, and returns err.
+func acked(ack func(error), err error) error {
+	if ack != nil {
+		ack(err)
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package logharbour
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChangeSummary is the Data of an entry logged by a ChangeAggregator. It summarizes
+// the changes to one class of objects over a window.
+type ChangeSummary struct {
+	Records  int            `json:"records"`  // Number of changes aggregated.
+	Affected int            `json:"affected"` // Number of objects affected, counting AffectedCount or 1 per change.
+	Ops      map[string]int `json:"ops"`      // Number of changes per operation, e.g. "Update".
+	Fields   map[string]int `json:"fields"`   // Number of changes per changed field.
+	From     time.Time      `json:"from"`     // Time of the first aggregated change.
+	To       time.Time      `json:"to"`       // Time of the last aggregated change.
+}
+
+// ChangeAggregator groups data changes by class and logs one summary entry per class
+// instead of one entry per change. It is meant for bulk operations, such as nightly
+// imports, where individual change entries would flood the audit log. The summary keeps
+// the number of changes per operation and per field, but not the values.
+//
+// Changes are summarized until Flush is called, or every window if the aggregator was
+// created with a positive window. Logging changes individually with LogDataChange remains
+// the default; use an aggregator only where the per-record detail is not needed.
+//
+//	agg := logharbour.NewChangeAggregator(logger, time.Minute)
+//	defer agg.Close()
+//	for _, rec := range records {
+//		change := logharbour.NewChangeInfo("Product", "Update").AddChange("price", rec.Old, rec.New)
+//		agg.LogDataChange("Product", *change)
+//	}
+type ChangeAggregator struct {
+	logger *Logger
+	groups map[string]*ChangeSummary
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+	mu     sync.Mutex
+}
+
+// NewChangeAggregator creates a ChangeAggregator that logs its summaries with logger.
+// If window is positive, the summaries are flushed every window until Close is called.
+func NewChangeAggregator(logger *Logger, window time.Duration) *ChangeAggregator {
+	agg := &ChangeAggregator{
+		logger: logger,
+		groups: make(map[string]*ChangeSummary),
+		done:   make(chan struct{}),
+	}
+	if window > 0 {
+		agg.wg.Add(1)
+		go agg.run(window)
+	}
+	return agg
+}
+
+// LogDataChange adds a change to the objects of class to the summary of that class.
+func (agg *ChangeAggregator) LogDataChange(class string, data ChangeInfo) {
+	now := time.Now()
+	agg.mu.Lock()
+	defer agg.mu.Unlock()
+	summary, ok := agg.groups[class]
+	if !ok {
+		summary = &ChangeSummary{Ops: make(map[string]int), Fields: make(map[string]int), From: now}
+		agg.groups[class] = summary
+	}
+	summary.Records++
+	if data.AffectedCount > 0 {
+		summary.Affected += data.AffectedCount
+	} else {
+		summary.Affected++
+	}
+	summary.Ops[data.Op]++
+	for _, change := range data.Changes {
+		summary.Fields[change.Field]++
+	}
+	summary.To = now
+}
+
+// Flush logs a summary entry for every class with changes since the last flush,
+// in order of class, and starts new summaries.
+func (agg *ChangeAggregator) Flush() {
+	agg.mu.Lock()
+	groups := agg.groups
+	agg.groups = make(map[string]*ChangeSummary)
+	agg.mu.Unlock()
+
+	classes := make([]string, 0, len(groups))
+	for class := range groups {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		summary := groups[class]
+		logger := agg.logger.WithClass(class)
+		entry := logger.newLogEntry(fmt.Sprintf("%d changes to %s", summary.Records, class), *summary)
+		entry.Type = Change
+		logger.log(entry)
+	}
+}
+
+// Close stops the periodic flush, if any, and flushes the remaining summaries.
+// It may be called more than once.
+func (agg *ChangeAggregator) Close() {
+	agg.once.Do(func() {
+		close(agg.done)
+		agg.wg.Wait()
+	})
+	agg.Flush()
+}
+
+// run flushes the summaries every window until the aggregator is closed.
+func (agg *ChangeAggregator) run(window time.Duration) {
+	defer agg.wg.Done()
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			agg.Flush()
+		case <-agg.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WithAlert returns a new Logger whose entries have the Alert flag set.
+// The flag marks entries that should page someone regardless of their priority, for
+// example a successful but suspicious admin action logged at Info. Downstream routing,
+// or an AlertWriter, can act on the flag without looking at the priority.
+//
+// This is distinct from WithEscalation, which raises the priority of entries that repeat
+// too often: an alert leaves the priority unchanged, and escalation never sets the flag.
+func (l *Logger) WithAlert() *Logger {
+	newLogger := l.clone()
+	newLogger.alert = true
+	if newLogger.tracing {
+		newLogger.traceMutation("WithAlert", true)
+	}
+	return newLogger
+}
+
+// LogAlert logs an activity event with the Alert flag set.
+func (l *Logger) LogAlert(message string, data ActivityInfo) {
+	l.WithAlert().LogActivity(message, data)
+}
+
+// AlertWriter is an io.Writer that forwards only alert-flagged log entries to the next
+// writer, such as a writer that posts to a paging or webhook service. Other entries, and
+// anything that is not a log entry, are discarded. It is typically combined with the
+// regular writer through io.MultiWriter, so that all entries are logged and alerts are
+// also sent to the pager.
+type AlertWriter struct {
+	next io.Writer
+}
+
+// NewAlertWriter creates an AlertWriter that forwards alert-flagged entries to next.
+func NewAlertWriter(next io.Writer) *AlertWriter {
+	return &AlertWriter{next: next}
+}
+
+// Write forwards p to the next writer if it is an alert-flagged log entry.
+// It implements io.Writer.
+func (w *AlertWriter) Write(p []byte) (n int, err error) {
+	var entry struct {
+		Alert bool `json:"alert"`
+	}
+	if err := json.Unmarshal(p, &entry); err != nil || !entry.Alert {
+		return len(p), nil
+	}
+	if _, err := w.next.Write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,33 @@
+package logharbour
+
+// Attachment references a large artifact related to a log entry, such as an uploaded file
+// or a request body, that is stored elsewhere (for example in object storage) instead of
+// inline in the entry. The logger only records the reference; it never reads the data.
+type Attachment struct {
+	URI         string `json:"uri"`                    // Location of the artifact, e.g. "s3://bucket/key".
+	ContentType string `json:"content_type,omitempty"` // MIME type of the artifact.
+	Size        int64  `json:"size,omitempty"`         // Size of the artifact in bytes.
+	Checksum    string `json:"checksum,omitempty"`     // Checksum of the artifact, prefixed by its algorithm, e.g. "sha256:9f86d0...".
+}
+
+// WithAttachment returns a new Logger that adds a reference to a to its entries.
+// Attachments accumulate: each call adds to the attachments set by earlier calls.
+// Use ClearAttachments to drop them.
+func (l *Logger) WithAttachment(a Attachment) *Logger {
+	newLogger := l.clone()
+	newLogger.attachments = append(l.attachments[:len(l.attachments):len(l.attachments)], a)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithAttachment", a)
+	}
+	return newLogger
+}
+
+// ClearAttachments returns a new Logger without any of the attachments set with WithAttachment.
+func (l *Logger) ClearAttachments() *Logger {
+	newLogger := l.clone()
+	newLogger.attachments = nil
+	if newLogger.tracing {
+		newLogger.traceMutation("ClearAttachments", nil)
+	}
+	return newLogger
+}
@@ -0,0 +1,44 @@
+package logharbour
+
+// Authorization decisions recorded by LogAuthz.
+const (
+	AuthzAllow = "allow"
+	AuthzDeny  = "deny"
+)
+
+// AuthzDecision is the Data of an entry logged by LogAuthz.
+type AuthzDecision struct {
+	Subject  string `json:"subject"`  // Who or what asked for access, e.g. a user or service ID.
+	Action   string `json:"action"`   // What the subject tried to do, e.g. "read".
+	Resource string `json:"resource"` // What the action applies to, e.g. "invoice/42".
+	Decision string `json:"decision"` // AuthzAllow or AuthzDeny.
+	Policy   string `json:"policy"`   // The policy or rule that made the decision.
+}
+
+// LogAuthz logs an authorization check as an activity entry at priority Sec, so that a
+// SIEM can build access dashboards from a single, stable shape. The entry carries the
+// Logger's who, remote IP and other fields, the message
+// "authz <decision> <subject> <action> <resource>", status Success if access was
+// allowed and Failure if it was denied, and an AuthzDecision as its data:
+//
+//	"data": {"subject": "u-17", "action": "read", "resource": "invoice/42", "decision": "deny", "policy": "owner-only"}
+//
+// The priority is Sec regardless of the Logger's priority, so authorization decisions
+// are written unless the minimum priority is above Sec.
+func (l *Logger) LogAuthz(subject, action, resource string, allowed bool, policy string) {
+	decision, status := AuthzDeny, Failure
+	if allowed {
+		decision, status = AuthzAllow, Success
+	}
+	entry := l.newLogEntry("authz "+decision+" "+subject+" "+action+" "+resource, AuthzDecision{
+		Subject:  subject,
+		Action:   action,
+		Resource: resource,
+		Decision: decision,
+		Policy:   policy,
+	})
+	entry.Type = Activity
+	entry.Pri = Sec
+	entry.Status = status
+	l.log(entry)
+}
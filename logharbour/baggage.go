@@ -0,0 +1,33 @@
+package logharbour
+
+// WithBaggage returns a new Logger that logs members in the 'baggage' field of its
+// entries. It is meant for business context propagated across services, such as W3C
+// baggage; the otelbaggage package sets it from OpenTelemetry baggage. Baggage is kept
+// out of Data so that the documented shapes of Data, such as ChangeInfo, are unchanged.
+//
+// Baggage accumulates: each call adds to the members set by earlier calls, later values
+// overwriting earlier ones for the same key. Use ClearBaggage to drop it.
+func (l *Logger) WithBaggage(members map[string]string) *Logger {
+	newLogger := l.clone()
+	newLogger.baggage = make(map[string]string, len(l.baggage)+len(members))
+	for k, v := range l.baggage {
+		newLogger.baggage[k] = v
+	}
+	for k, v := range members {
+		newLogger.baggage[k] = v
+	}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithBaggage", members)
+	}
+	return newLogger
+}
+
+// ClearBaggage returns a new Logger without any of the baggage set with WithBaggage.
+func (l *Logger) ClearBaggage() *Logger {
+	newLogger := l.clone()
+	newLogger.baggage = nil
+	if newLogger.tracing {
+		newLogger.traceMutation("ClearBaggage", nil)
+	}
+	return newLogger
+}
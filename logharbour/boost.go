@@ -0,0 +1,50 @@
+package logharbour
+
+import "net/http"
+
+// DebugHeader is the HTTP request header that BoostFromRequest looks for.
+// Its value may name the priority to boost to, e.g. "Debug1"; an empty or
+// unrecognised value boosts to Debug2.
+const DebugHeader = "X-Logharbour-Debug"
+
+// WithBoostedLevel runs fn with a clone of the Logger whose minimum log priority is
+// lowered to p, regardless of the minimum priority of the shared LoggerContext.
+// If p is a debug priority, LogDebug is also enabled for the clone.
+// The original Logger and its context are left unchanged, so this is safe to use for
+// turning on verbose logging for a single request during an incident.
+func (l *Logger) WithBoostedLevel(p LogPriority, fn func(l *Logger)) {
+	fn(l.boosted(p))
+}
+
+// BoostFromRequest returns a Logger boosted to the priority named in the DebugHeader
+// of r, as with WithBoostedLevel. If the header is absent, the Logger is returned unchanged.
+//
+// The header is set by the client, so any caller can turn on verbose logging for its
+// requests, filling the logs and exposing the debug data of the code it reaches. Call
+// BoostFromRequest only for requests from trusted callers, such as authenticated
+// operators or internal networks, and strip the header from the others at the edge.
+func (l *Logger) BoostFromRequest(r *http.Request) *Logger {
+	value, ok := r.Header[http.CanonicalHeaderKey(DebugHeader)]
+	if !ok {
+		return l
+	}
+	p := Debug2
+	if len(value) > 0 {
+		if parsed, err := ParseLogPriority(value[0]); err == nil {
+			p = parsed
+		}
+	}
+	return l.boosted(p)
+}
+
+// boosted returns a clone of the Logger with its minimum priority lowered to p.
+func (l *Logger) boosted(p LogPriority) *Logger {
+	newLogger := l.clone()
+	newLogger.minPriority = p
+	return newLogger
+}
+
+// isBoostedToDebug reports whether the Logger has been boosted to a debug priority.
+func (l *Logger) isBoostedToDebug() bool {
+	return l.minPriority != 0 && l.minPriority <= Debug0
+}
@@ -0,0 +1,41 @@
+package logharbour
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// WithCanonicalData returns a new Logger that re-encodes the Data payload of every entry
+// in canonical form, with the keys of every JSON object in alphabetical order, if
+// canonical is true, which is the default, or writes Data as it encodes if false.
+//
+// The JSON encoder already writes the keys of Go maps in sorted order at every nesting
+// level, but Data types with a custom MarshalJSON may not sort their keys, which breaks
+// golden-file tests and deduplication. Canonical mode fixes this at the cost of decoding
+// and re-encoding the payload; performance-sensitive applications whose Data does not
+// use such types can turn it off with WithCanonicalData(false).
+func (l *Logger) WithCanonicalData(canonical bool) *Logger {
+	newLogger := l.clone()
+	newLogger.rawData = !canonical
+	if newLogger.tracing {
+		newLogger.traceMutation("WithCanonicalData", canonical)
+	}
+	return newLogger
+}
+
+// genericData converts v to its generic JSON form made of maps, slices, strings,
+// booleans and json.Number values. Numbers are kept as json.Number so that no
+// precision is lost.
+func genericData(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
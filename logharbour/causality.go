@@ -0,0 +1,49 @@
+package logharbour
+
+// WithCausedBy returns a new Logger whose entries record that they were caused by the
+// entry with the given ID, as returned by LogActivityWithID or LogDataChangeWithID.
+//
+// Together, the ID and CausedBy fields of entries form a causality graph that is finer
+// than a trace: a consumer reconstructs the chain of causes of an entry by looking up
+// the entry whose "id" is its "caused_by", then that entry's "caused_by", and so on,
+// until an entry without "caused_by" is reached. The effects of an entry are the entries
+// whose "caused_by" is its "id". Entries of one chain may come from different processes,
+// so the lookup is done in the log store, not by the Logger.
+//
+//	id := logger.LogActivityWithID("order placed", order)
+//	logger.WithCausedBy(id).LogActivity("stock reserved", reservation)
+func (l *Logger) WithCausedBy(id string) *Logger {
+	newLogger := l.clone()
+	newLogger.causedBy = id
+	if newLogger.tracing {
+		newLogger.traceMutation("WithCausedBy", id)
+	}
+	return newLogger
+}
+
+// LogActivityWithID logs an activity event like LogActivity, with a generated unique ID
+// set on the entry, and returns the ID for use with WithCausedBy. The ID is returned even
+// if the entry is not written, for instance because of its priority, in which case links
+// to it cannot be followed.
+func (l *Logger) LogActivityWithID(message string, data ActivityInfo) string {
+	entry := l.newLogEntry(message, data)
+	entry.Type = Activity
+	entry.ID = newEntryID()
+	l.log(entry)
+	return entry.ID
+}
+
+// LogDataChangeWithID logs a data change event like LogDataChange, with a generated
+// unique ID set on the entry, and returns the ID for use with WithCausedBy.
+func (l *Logger) LogDataChangeWithID(message string, data ChangeInfo) string {
+	entry := l.newLogEntry(message, data)
+	entry.Type = Change
+	entry.ID = newEntryID()
+	l.log(entry)
+	return entry.ID
+}
+
+// newEntryID generates a random 128-bit entry ID encoded as hex.
+func newEntryID() string {
+	return newRandomID(16)
+}
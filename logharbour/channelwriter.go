@@ -0,0 +1,67 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"sync/atomic"
+)
+
+// DropPolicy defines what a ChannelWriter does when its channel is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the entry being written if the channel is full, so a slow
+	// consumer never stalls logging. This is the default.
+	DropNewest DropPolicy = iota
+	// Block waits until the consumer has made room in the channel.
+	Block
+)
+
+// ChannelWriter is an io.Writer that decodes log entries and sends them to a channel,
+// so that in-process components can react to entries in real time.
+// It can be combined with other writers using io.MultiWriter:
+//
+//	ch := make(chan logharbour.LogEntry, 100)
+//	writer := io.MultiWriter(file, logharbour.NewChannelWriter(ch, logharbour.DropNewest))
+//
+// The consumer must keep draining the channel. With DropNewest, entries written while
+// the channel is full are discarded and counted in Dropped; with Block, logging stops
+// until the consumer receives from the channel.
+type ChannelWriter struct {
+	ch      chan<- LogEntry
+	onFull  DropPolicy
+	dropped atomic.Int64
+}
+
+// NewChannelWriter creates a ChannelWriter that sends entries to ch according to onFull.
+func NewChannelWriter(ch chan<- LogEntry, onFull DropPolicy) *ChannelWriter {
+	return &ChannelWriter{
+		ch:     ch,
+		onFull: onFull,
+	}
+}
+
+// Write decodes a log entry and sends it to the channel. It implements io.Writer.
+// It returns an error only if p is not a valid log entry; a dropped entry is not an error.
+func (cw *ChannelWriter) Write(p []byte) (n int, err error) {
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return 0, err
+	}
+
+	if cw.onFull == Block {
+		cw.ch <- entry
+		return len(p), nil
+	}
+
+	select {
+	case cw.ch <- entry:
+	default:
+		cw.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of entries discarded because the channel was full.
+func (cw *ChannelWriter) Dropped() int64 {
+	return cw.dropped.Load()
+}
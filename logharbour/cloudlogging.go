@@ -0,0 +1,85 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Field names recognized by Google Cloud Logging in structured logs.
+const (
+	CloudLoggingTraceKey  = "logging.googleapis.com/trace"
+	CloudLoggingSpanIDKey = "logging.googleapis.com/spanId"
+)
+
+// CloudLoggingEncoder encodes entries as the structured JSON lines that Google Cloud
+// Logging parses from the stdout of Cloud Run, GKE, App Engine and Cloud Functions, one
+// JSON object per line:
+//
+//	logger := logharbour.NewLogger(lctx, "billing", logharbour.NewEncodedWriter(os.Stdout, logharbour.CloudLoggingEncoder{ProjectID: "my-project"}))
+//
+// The fields Cloud Logging recognizes are set from the entry:
+//   - "severity": DEBUG for the debug priorities, INFO, WARNING, ERROR, CRITICAL for
+//     Crit and ALERT for Sec;
+//   - "message": the message, shown as the summary line of the entry;
+//   - "time": When, in RFC 3339 format with nanoseconds;
+//   - "logging.googleapis.com/trace": "projects/<ProjectID>/traces/<TraceID>", which links
+//     the entry to the trace, if ProjectID and TraceID are set;
+//   - "logging.googleapis.com/spanId": SpanID, if set with the trace.
+//
+// Cloud Logging removes these fields and stores all the others, that is, the remaining
+// fields of the entry under their JSON names, including "data", as the jsonPayload of
+// the log entry. The monitored resource is detected by the platform and cannot be set
+// from the line. Without ProjectID, the trace ID is kept in jsonPayload as "trace_id".
+type CloudLoggingEncoder struct {
+	ProjectID string // Google Cloud project ID used in trace links.
+}
+
+// Encode implements Encoder.
+func (ce CloudLoggingEncoder) Encode(entry LogEntry) ([]byte, error) {
+	fields, err := genericData(entry)
+	if err != nil {
+		return nil, err
+	}
+	payload, ok := fields.(map[string]any)
+	if !ok {
+		payload = make(map[string]any)
+	}
+	for _, name := range []string{"when", "pri", "msg"} {
+		delete(payload, name)
+	}
+	payload["severity"] = cloudLoggingSeverity(entry.Pri)
+	payload["message"] = entry.Msg
+	payload["time"] = entry.When.Format(time.RFC3339Nano)
+	if ce.ProjectID != "" && entry.TraceID != "" {
+		delete(payload, "trace_id")
+		payload[CloudLoggingTraceKey] = "projects/" + ce.ProjectID + "/traces/" + entry.TraceID
+		if entry.SpanID != "" {
+			delete(payload, "span_id")
+			payload[CloudLoggingSpanIDKey] = entry.SpanID
+		}
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return append(encoded, '\n'), nil
+}
+
+// cloudLoggingSeverity maps a priority to a Cloud Logging LogSeverity name.
+func cloudLoggingSeverity(p LogPriority) string {
+	switch {
+	case p <= Debug0:
+		return "DEBUG"
+	case p == Info:
+		return "INFO"
+	case p == Warn:
+		return "WARNING"
+	case p == Err:
+		return "ERROR"
+	case p == Crit:
+		return "CRITICAL"
+	default:
+		return "ALERT"
+	}
+}
@@ -0,0 +1,349 @@
+package logharbour
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CloudWatch Logs limits for a single PutLogEvents call.
+const (
+	cloudWatchMaxBatchEvents  = 10000
+	cloudWatchMaxBatchBytes   = 1048576
+	cloudWatchEventOverhead   = 26
+	cloudWatchMaxBatchSpan    = 24 * time.Hour
+	defaultCloudWatchInterval = 5 * time.Second
+	defaultCloudWatchRetries  = 5
+	defaultCloudWatchBackoff  = 200 * time.Millisecond
+	defaultCloudWatchBuffer   = 100000
+	cloudWatchTimeout         = 30 * time.Second
+)
+
+// ErrCloudWatchThrottled should be returned (or wrapped) by a CloudWatchClient when
+// CloudWatch rejects a request because of throttling. The writer backs off and retries
+// the batch in that case.
+var ErrCloudWatchThrottled = errors.New("cloudwatch: request throttled")
+
+// ErrCloudWatchWriterClosed is returned by a CloudWatchWriter for the entries written after Close.
+var ErrCloudWatchWriterClosed = errors.New("cloudwatch: writer closed")
+
+// ErrCloudWatchNoFallback is returned by NewCloudWatchWriter if the fallback writer is nil.
+var ErrCloudWatchNoFallback = errors.New("cloudwatch: no fallback writer")
+
+// CloudWatchEvent is a single log event sent to CloudWatch Logs.
+type CloudWatchEvent struct {
+	Timestamp time.Time
+	Message   string
+}
+
+// CloudWatchClient is the subset of the CloudWatch Logs API used by CloudWatchWriter.
+// It is typically implemented by a thin adapter around the AWS SDK client, which keeps
+// the AWS SDK out of the dependencies of applications that don't use CloudWatch.
+// The region is configured on the underlying SDK client.
+//
+// CreateLogGroup and CreateLogStream must return nil if the resource already exists.
+// PutLogEvents returns the next sequence token to use, if any.
+type CloudWatchClient interface {
+	CreateLogGroup(ctx context.Context, group string) error
+	CreateLogStream(ctx context.Context, group, stream string) error
+	PutLogEvents(ctx context.Context, group, stream string, events []CloudWatchEvent, sequenceToken *string) (*string, error)
+}
+
+// CloudWatchConfig holds the configuration for a CloudWatchWriter.
+// Optional fields are pointers, which allows us to distinguish between a field that is not set and a field set with its zero value.
+type CloudWatchConfig struct {
+	LogGroup  string // Name of the log group to write to
+	LogStream string // Name of the log stream to write to
+
+	FlushInterval *time.Duration // How often buffered events are sent
+	MaxRetries    *int           // Maximum number of retries for a throttled batch
+	Backoff       *time.Duration // Initial backoff after throttling; doubled on every retry
+	MaxBuffered   *int           // Maximum number of buffered events before overflow is spilled to the fallback writer
+	SyncPriority  *LogPriority   // Entries at or above this priority are sent immediately; defaults to Err
+}
+
+// CloudWatchWriter is an io.Writer that sends log entries to AWS CloudWatch Logs.
+// Entries are buffered and sent in batches that respect CloudWatch's limits on batch
+// size and time ordering. The upload sequence token is tracked across calls.
+// If CloudWatch throttles a batch beyond the retry limit, or the buffer overflows,
+// the affected entries are written to the fallback writer instead.
+//
+// Entries at or above the sync priority (Err by default, see CloudWatchConfig) are not
+// left in the buffer: writing one flushes the buffer, including the entry, before Write
+// returns, so the entries most needed after a crash are not lost with the buffer. Such
+// writes take as long as a PutLogEvents call, and longer when throttled, so the sync
+// priority should be set high enough that this only happens for rare entries.
+type CloudWatchWriter struct {
+	client   CloudWatchClient
+	group    string
+	stream   string
+	fallback io.Writer
+
+	interval     time.Duration
+	maxRetries   int
+	backoff      time.Duration
+	maxBuffered  int
+	syncPriority LogPriority
+
+	token  *string
+	buffer []CloudWatchEvent
+	acks   []func(error) // Acknowledgment callbacks of the buffered entries.
+	done   chan struct{}
+	closed atomic.Bool
+	wg     sync.WaitGroup
+	mu     sync.Mutex // guards buffer and acks
+	sendMu sync.Mutex // serializes PutLogEvents calls and guards token
+}
+
+// NewCloudWatchWriter creates a CloudWatchWriter for the configured log group and stream,
+// creating them if they don't exist. Entries that cannot be delivered are written to
+// fallback, which is required. The writer flushes in the background until Close is called.
+func NewCloudWatchWriter(client CloudWatchClient, cfg CloudWatchConfig, fallback io.Writer) (*CloudWatchWriter, error) {
+	if fallback == nil {
+		return nil, ErrCloudWatchNoFallback
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+	defer cancel()
+	if err := client.CreateLogGroup(ctx, cfg.LogGroup); err != nil {
+		return nil, err
+	}
+	if err := client.CreateLogStream(ctx, cfg.LogGroup, cfg.LogStream); err != nil {
+		return nil, err
+	}
+
+	cw := &CloudWatchWriter{
+		client:       client,
+		group:        cfg.LogGroup,
+		stream:       cfg.LogStream,
+		fallback:     fallback,
+		interval:     defaultCloudWatchInterval,
+		maxRetries:   defaultCloudWatchRetries,
+		backoff:      defaultCloudWatchBackoff,
+		maxBuffered:  defaultCloudWatchBuffer,
+		syncPriority: Err,
+		done:         make(chan struct{}),
+	}
+	if cfg.FlushInterval != nil {
+		cw.interval = *cfg.FlushInterval
+	}
+	if cfg.MaxRetries != nil {
+		cw.maxRetries = *cfg.MaxRetries
+	}
+	if cfg.Backoff != nil {
+		cw.backoff = *cfg.Backoff
+	}
+	if cfg.MaxBuffered != nil {
+		cw.maxBuffered = *cfg.MaxBuffered
+	}
+	if cfg.SyncPriority != nil {
+		cw.syncPriority = *cfg.SyncPriority
+	}
+
+	cw.wg.Add(1)
+	go cw.run()
+	return cw, nil
+}
+
+// Write buffers a log entry for delivery to CloudWatch. It implements io.Writer.
+// The event timestamp is taken from the entry's "when" field, or the current time if absent.
+// If the buffer is full, the entry is written to the fallback writer. Entries written
+// after Close are rejected with ErrCloudWatchWriterClosed.
+func (cw *CloudWatchWriter) Write(p []byte) (n int, err error) {
+	when, pri := entryHeader(p)
+	if err := cw.add(p, when, pri, nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry buffers a structured log entry, which spares decoding it to find its
+// timestamp and priority.
+func (cw *CloudWatchWriter) writeEntry(entry LogEntry) error {
+	encoded, err := JSONEncoder{}.Encode(entry)
+	if err != nil {
+		return err
+	}
+	return cw.add(encoded, entry.When, entry.Pri, nil)
+}
+
+// writeEntryAck buffers a structured log entry and calls ack with the outcome of the
+// flush that sends it, or right away if it is written to the fallback writer.
+func (cw *CloudWatchWriter) writeEntryAck(entry LogEntry, ack func(error)) {
+	encoded, err := JSONEncoder{}.Encode(entry)
+	if err != nil {
+		acked(ack, err)
+	} else {
+		err = cw.add(encoded, entry.When, entry.Pri, ack)
+	}
+	if err != nil {
+		writeLastResort(err, entry)
+	}
+}
+
+// add buffers an encoded entry, or writes it to the fallback writer if the buffer is
+// full, and flushes the buffer if the entry is at or above the sync priority. ack, if
+// not nil, is called by the flush that sends the entry, or right away if the entry is
+// written to the fallback writer.
+func (cw *CloudWatchWriter) add(p []byte, when time.Time, pri LogPriority, ack func(error)) error {
+	cw.mu.Lock()
+	// Checked with cw.mu held, so that the final flush of Close sends every entry buffered
+	// before Close.
+	if cw.closed.Load() {
+		cw.mu.Unlock()
+		return acked(ack, ErrCloudWatchWriterClosed)
+	}
+	if len(cw.buffer) >= cw.maxBuffered {
+		cw.mu.Unlock()
+		_, err := cw.fallback.Write(p)
+		return acked(ack, err)
+	}
+	cw.buffer = append(cw.buffer, CloudWatchEvent{Timestamp: when, Message: string(p)})
+	if ack != nil {
+		cw.acks = append(cw.acks, ack)
+	}
+	cw.mu.Unlock()
+
+	if pri >= cw.syncPriority {
+		// Undelivered entries are written to the fallback writer by Flush, so the
+		// error is not returned, which would have the entry written once more.
+		cw.Flush()
+	}
+	return nil
+}
+
+// Pressure returns how full the buffer is, as a ratio between 0 and 1; it implements
+// PressureReporter. Once it reaches 1, entries are written to the fallback writer.
+func (cw *CloudWatchWriter) Pressure() float64 {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.maxBuffered <= 0 {
+		return 1
+	}
+	return float64(len(cw.buffer)) / float64(cw.maxBuffered)
+}
+
+// Flush sends all buffered events to CloudWatch. The acknowledgment callbacks of the
+// entries sent, if any, are called with the first error of the flush.
+func (cw *CloudWatchWriter) Flush() error {
+	cw.mu.Lock()
+	events, acks := cw.buffer, cw.acks
+	cw.buffer, cw.acks = nil, nil
+	cw.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	// CloudWatch requires the events of a batch to be in chronological order.
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	var firstErr error
+	for _, batch := range splitCloudWatchBatches(events) {
+		if err := cw.send(batch); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, ack := range acks {
+		ack(firstErr)
+	}
+	return firstErr
+}
+
+// Close stops the background flusher and sends any remaining buffered events.
+// Entries written after Close are rejected with ErrCloudWatchWriterClosed.
+// Closing the writer again does nothing.
+func (cw *CloudWatchWriter) Close() error {
+	if !cw.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	close(cw.done)
+	cw.wg.Wait()
+	return cw.Flush()
+}
+
+// run periodically flushes buffered events until the writer is closed.
+func (cw *CloudWatchWriter) run() {
+	defer cw.wg.Done()
+	ticker := time.NewTicker(cw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cw.Flush()
+		case <-cw.done:
+			return
+		}
+	}
+}
+
+// send delivers one batch, backing off and retrying on throttling.
+// If the batch cannot be delivered, it is written to the fallback writer.
+func (cw *CloudWatchWriter) send(batch []CloudWatchEvent) error {
+	cw.sendMu.Lock()
+	defer cw.sendMu.Unlock()
+
+	backoff := cw.backoff
+	var err error
+	for attempt := 0; attempt <= cw.maxRetries; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+		var next *string
+		next, err = cw.client.PutLogEvents(ctx, cw.group, cw.stream, batch, cw.token)
+		cancel()
+		if err == nil {
+			cw.token = next
+			return nil
+		}
+		if !errors.Is(err, ErrCloudWatchThrottled) {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	for _, event := range batch {
+		cw.fallback.Write([]byte(event.Message))
+	}
+	return err
+}
+
+// splitCloudWatchBatches splits chronologically sorted events into batches that fit
+// CloudWatch's limits on event count, total size and time span.
+func splitCloudWatchBatches(events []CloudWatchEvent) [][]CloudWatchEvent {
+	var batches [][]CloudWatchEvent
+	start, size := 0, 0
+	for i, event := range events {
+		eventSize := len(event.Message) + cloudWatchEventOverhead
+		if i > start && (i-start >= cloudWatchMaxBatchEvents ||
+			size+eventSize > cloudWatchMaxBatchBytes ||
+			event.Timestamp.Sub(events[start].Timestamp) > cloudWatchMaxBatchSpan) {
+			batches = append(batches, events[start:i])
+			start, size = i, 0
+		}
+		size += eventSize
+	}
+	if start < len(events) {
+		batches = append(batches, events[start:])
+	}
+	return batches
+}
+
+// entryHeader extracts the "when" and "pri" fields of a serialized log entry.
+// The time is the current time if the field is missing or cannot be parsed, and the
+// priority is zero if it cannot be parsed.
+func entryHeader(p []byte) (time.Time, LogPriority) {
+	var entry struct {
+		When time.Time   `json:"when"`
+		Pri  LogPriority `json:"pri"`
+	}
+	if err := json.Unmarshal(p, &entry); err != nil || entry.When.IsZero() {
+		return time.Now(), entry.Pri
+	}
+	return entry.When, entry.Pri
+}
@@ -0,0 +1,200 @@
+package logharbour
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeCloudWatchClient struct {
+	batches   [][]CloudWatchEvent
+	tokens    []*string
+	throttles int
+}
+
+func (c *fakeCloudWatchClient) CreateLogGroup(ctx context.Context, group string) error {
+	return nil
+}
+
+func (c *fakeCloudWatchClient) CreateLogStream(ctx context.Context, group, stream string) error {
+	return nil
+}
+
+func (c *fakeCloudWatchClient) PutLogEvents(ctx context.Context, group, stream string, events []CloudWatchEvent, sequenceToken *string) (*string, error) {
+	if c.throttles > 0 {
+		c.throttles--
+		return nil, ErrCloudWatchThrottled
+	}
+	c.batches = append(c.batches, events)
+	c.tokens = append(c.tokens, sequenceToken)
+	next := "token"
+	return &next, nil
+}
+
+func TestCloudWatchWriter(t *testing.T) {
+	client := &fakeCloudWatchClient{throttles: 1}
+	fallback := &bytes.Buffer{}
+	interval := time.Hour
+	backoff := time.Millisecond
+	cw, err := NewCloudWatchWriter(client, CloudWatchConfig{
+		LogGroup:      "group",
+		LogStream:     "stream",
+		FlushInterval: &interval,
+		Backoff:       &backoff,
+	}, fallback)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	logger := NewLogger(NewLoggerContext(Info), "testApp", cw)
+	logger.WithWhen(time.Now().Add(time.Minute)).LogActivity("second", nil)
+	logger.WithWhen(time.Now()).LogActivity("first", nil)
+
+	if err := cw.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(client.batches) != 1 || len(client.batches[0]) != 2 {
+		t.Fatalf("Expected one batch of 2 events. Got: %v", client.batches)
+	}
+	if !client.batches[0][0].Timestamp.Before(client.batches[0][1].Timestamp) {
+		t.Errorf("Expected events to be sorted by timestamp")
+	}
+
+	logger.LogActivity("third", nil)
+	if err := cw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Errorf("Expected closing again to do nothing. Got: %v", err)
+	}
+	if len(client.tokens) != 2 || client.tokens[1] == nil || *client.tokens[1] != "token" {
+		t.Errorf("Expected second batch to use the returned sequence token")
+	}
+	if fallback.Len() != 0 {
+		t.Errorf("Expected nothing in the fallback writer. Got: %s", fallback.String())
+	}
+}
+
+func TestCloudWatchWriterSyncPriority(t *testing.T) {
+	client := &fakeCloudWatchClient{}
+	interval := time.Hour
+	cw, err := NewCloudWatchWriter(client, CloudWatchConfig{
+		LogGroup:      "group",
+		LogStream:     "stream",
+		FlushInterval: &interval,
+	}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cw.Close()
+
+	logger := NewLogger(NewLoggerContext(Info), "testApp", cw)
+	logger.LogActivity("buffered", nil)
+	if len(client.batches) != 0 {
+		t.Fatalf("Expected Info entries to be buffered. Got: %v", client.batches)
+	}
+
+	logger.Err().LogActivity("sent immediately", nil)
+	if len(client.batches) != 1 || len(client.batches[0]) != 2 {
+		t.Fatalf("Expected the Err entry to flush the buffer. Got: %v", client.batches)
+	}
+
+	// Entries reaching the writer as bytes are inspected too.
+	NewLogger(NewLoggerContext(Info), "testApp", NewFallbackWriter(cw, &bytes.Buffer{})).Crit().LogActivity("critical", nil)
+	if len(client.batches) != 2 {
+		t.Errorf("Expected the Crit entry to be sent immediately. Got: %v", client.batches)
+	}
+}
+
+func TestCloudWatchWriterAck(t *testing.T) {
+	client := &fakeCloudWatchClient{}
+	interval := time.Hour
+	cw, err := NewCloudWatchWriter(client, CloudWatchConfig{
+		LogGroup:      "group",
+		LogStream:     "stream",
+		FlushInterval: &interval,
+	}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cw.Close()
+
+	logger := NewLogger(NewLoggerContext(Info), "testApp", cw)
+	acked := make(chan error, 1)
+	logger.LogActivityAck("payment captured", nil, func(err error) { acked <- err })
+	select {
+	case err := <-acked:
+		t.Fatalf("Expected no acknowledgment before the flush. Got: %v", err)
+	default:
+	}
+
+	if err := cw.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case err := <-acked:
+		if err != nil || len(client.batches) != 1 {
+			t.Errorf("Expected a successful acknowledgment after the flush. Got: %v", err)
+		}
+	default:
+		t.Errorf("Expected an acknowledgment after the flush")
+	}
+}
+
+func TestCloudWatchWriterPressure(t *testing.T) {
+	interval := time.Hour
+	maxBuffered := 4
+	cw, err := NewCloudWatchWriter(&fakeCloudWatchClient{}, CloudWatchConfig{
+		LogGroup:      "group",
+		LogStream:     "stream",
+		FlushInterval: &interval,
+		MaxBuffered:   &maxBuffered,
+	}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer cw.Close()
+
+	logger := NewLogger(NewLoggerContext(Info), "testApp", cw)
+	logger.LogActivity("first", nil)
+	logger.LogActivity("second", nil)
+	logger.LogActivity("third", nil)
+	if p := logger.Pressure(); p != 0.75 {
+		t.Errorf("Expected pressure 0.75. Got: %v", p)
+	}
+	if err := cw.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p := logger.Pressure(); p != 0 {
+		t.Errorf("Expected pressure 0 after a flush. Got: %v", p)
+	}
+}
+
+func TestCloudWatchWriterClose(t *testing.T) {
+	if _, err := NewCloudWatchWriter(&fakeCloudWatchClient{}, CloudWatchConfig{LogGroup: "group", LogStream: "stream"}, nil); !errors.Is(err, ErrCloudWatchNoFallback) {
+		t.Errorf("Expected ErrCloudWatchNoFallback for a nil fallback writer. Got: %v", err)
+	}
+
+	client := &fakeCloudWatchClient{}
+	cw, err := NewCloudWatchWriter(client, CloudWatchConfig{LogGroup: "group", LogStream: "stream"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := cw.Write([]byte(`{"msg":"before close"}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(client.batches) != 1 {
+		t.Errorf("Expected the buffered entry sent by Close. Got: %v", client.batches)
+	}
+	if _, err := cw.Write([]byte(`{"msg":"after close"}`)); !errors.Is(err, ErrCloudWatchWriterClosed) {
+		t.Errorf("Expected ErrCloudWatchWriterClosed after Close. Got: %v", err)
+	}
+	if p := cw.Pressure(); p != 0 {
+		t.Errorf("Expected nothing buffered after Close. Got pressure %v", p)
+	}
+}
@@ -0,0 +1,45 @@
+package logharbour
+
+import "time"
+
+// compactLogEntry is LogEntry with omitempty set on the fields left out by JSONEncoder.Compact.
+// Its fields must match those of LogEntry, in the same order, as entries are converted to it.
+type compactLogEntry struct {
+	App                 string                 `json:"app"`
+	System              string                 `json:"system"`
+	Env                 string                 `json:"env,omitempty"`
+	Tenant              string                 `json:"tenant,omitempty"`
+	Module              string                 `json:"module,omitempty"`
+	Type                LogType                `json:"type"`
+	Pri                 LogPriority            `json:"pri"`
+	When                time.Time              `json:"when"`
+	Seq                 uint64                 `json:"seq,omitempty"`
+	SeqSource           string                 `json:"seq_source,omitempty"`
+	Who                 string                 `json:"who"`
+	ActorType           string                 `json:"actor_type,omitempty"`
+	Op                  string                 `json:"op,omitempty"`
+	Class               string                 `json:"class,omitempty"`
+	InstanceId          string                 `json:"instance,omitempty"`
+	SubjectType         string                 `json:"subject_type,omitempty"`
+	Status              Status                 `json:"status"`
+	Error               string                 `json:"error,omitempty"`
+	RemoteIP            string                 `json:"remote_ip,omitempty"`
+	TraceID             string                 `json:"trace_id,omitempty"`
+	SpanID              string                 `json:"span_id,omitempty"`
+	ParentSpanID        string                 `json:"parent_span_id,omitempty"`
+	Baggage             map[string]string      `json:"baggage,omitempty"`
+	DeadlineRemainingMs *int64                 `json:"deadline_remaining_ms,omitempty"`
+	UptimeMs            *int64                 `json:"uptime_ms,omitempty"`
+	LibVersion          string                 `json:"lh_version,omitempty"`
+	ID                  string                 `json:"id,omitempty"`
+	CausedBy            string                 `json:"caused_by,omitempty"`
+	DedupKey            string                 `json:"dedup_key,omitempty"`
+	Alert               bool                   `json:"alert,omitempty"`
+	Msg                 string                 `json:"msg"`
+	Data                any                    `json:"data"`
+	EscalatedFrom       LogPriority            `json:"escalated_from,omitempty"`
+	Exemplar            *MetricExemplar        `json:"exemplar,omitempty"`
+	Attachments         []Attachment           `json:"attachments,omitempty"`
+	Truncated           bool                   `json:"truncated,omitempty"`
+	ValidationErrors    []FieldValidationError `json:"validation_errors,omitempty"`
+}
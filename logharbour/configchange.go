@@ -0,0 +1,35 @@
+package logharbour
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// LogConfigChange logs a data change entry auditing a configuration reload, with a
+// ChangeDetail for every field that differs between oldConfig and newConfig, which must
+// be values of the same struct type or pointers to it. The entity of the change is the
+// name of the type and its operation "Reload".
+//
+// Fields are compared as by NewChangeInfoFromDiff: fields tagged `logharbour:"-"` or
+// `json:"-"` are left out, and fields tagged `logharbour:"sensitive"`, such as passwords or API keys,
+// are reported as changed without their values, also when nested in other structs:
+//
+//	type Config struct {
+//		Port     int    `json:"port"`
+//		DBPass   string `json:"db_pass" logharbour:"sensitive"`
+//		Internal string `logharbour:"-"`
+//	}
+//
+// A reload that changes nothing is logged too, with no changes. An error is returned,
+// and nothing logged, if the values cannot be compared.
+func (l *Logger) LogConfigChange(oldConfig, newConfig any) error {
+	change, err := NewChangeInfoFromDiff("", "Reload", oldConfig, newConfig, false)
+	if err != nil {
+		return err
+	}
+	entity := reflect.Indirect(reflect.ValueOf(newConfig)).Type().Name()
+	change.Entity = entity
+	message := fmt.Sprintf("configuration %s reloaded with %d changes", entity, len(change.Changes))
+	l.LogDataChange(message, *change)
+	return nil
+}
@@ -0,0 +1,17 @@
+package logharbour
+
+import "context"
+
+// loggerKey is the context key under which a Logger is stored.
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx that carries l.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the Logger stored in ctx by NewContext, if any.
+func FromContext(ctx context.Context) (*Logger, bool) {
+	l, ok := ctx.Value(loggerKey{}).(*Logger)
+	return l, ok
+}
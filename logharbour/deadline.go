@@ -0,0 +1,20 @@
+package logharbour
+
+import "context"
+
+// WithDeadline returns a new Logger whose entries record, in deadline_remaining_ms, how
+// much time was left before the deadline of ctx when they were created, to show how close
+// to its timeout each step of a request ran. The value is negative once the deadline has
+// passed. If ctx has no deadline, the entries carry no such field.
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+//	defer cancel()
+//	logger = logger.WithDeadline(ctx)
+func (l *Logger) WithDeadline(ctx context.Context) *Logger {
+	newLogger := l.clone()
+	newLogger.deadline, _ = ctx.Deadline()
+	if newLogger.tracing {
+		newLogger.traceMutation("WithDeadline", newLogger.deadline)
+	}
+	return newLogger
+}
@@ -0,0 +1,31 @@
+package logharbour
+
+import "sync/atomic"
+
+// debugCaptureDisabled is 1 if LogDebug must not capture the caller and stack trace.
+var debugCaptureDisabled int32
+
+// DisableDebugCapture makes LogDebug skip the capture of the caller's file name, line
+// number, function name and stack trace, which are left empty in the entries it logs.
+// The capture calls runtime.Caller and runtime.Stack on every LogDebug call, which is
+// pure overhead in production builds where nobody reads debug entries.
+//
+// LogDebug returns before the capture when the LoggerContext's debug mode is off and the
+// Logger is not boosted to debug (see SetDebugMode and WithBoostedLevel). Otherwise the capture
+// happens before the priority check, because escalation rules may raise the priority of
+// the entry: an entry filtered out by the minimum priority still pays for its capture.
+// DisableDebugCapture removes that cost as well, for instance when a stray LogDebug call
+// remains in a hot path while debug mode is on.
+func DisableDebugCapture() {
+	atomic.StoreInt32(&debugCaptureDisabled, 1)
+}
+
+// EnableDebugCapture restores the capture of debug information by LogDebug, which is the default.
+func EnableDebugCapture() {
+	atomic.StoreInt32(&debugCaptureDisabled, 0)
+}
+
+// isDebugCaptureDisabled reports whether DisableDebugCapture is in effect.
+func isDebugCaptureDisabled() bool {
+	return atomic.LoadInt32(&debugCaptureDisabled) == 1
+}
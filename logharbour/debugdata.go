@@ -0,0 +1,64 @@
+package logharbour
+
+// WithDebugData returns a new Logger that adds data to the Data of entries logged at a
+// debug priority (Debug0, Debug1 or Debug2) and leaves it out of all other entries.
+// This keeps verbose diagnostics off normal Info lines while having them available when
+// debugging, for instance together with WithBoostedLevel.
+//
+// Debug data accumulates: each call adds to the keys set by earlier calls, later values
+// overwriting earlier ones for the same key. Use ClearDebugData to drop it.
+// The keys are merged into the entry's Data; struct Data, such as the DebugInfo of
+// LogDebug, is merged in its JSON form (see mergeData).
+func (l *Logger) WithDebugData(data map[string]any) *Logger {
+	newLogger := l.clone()
+	newLogger.debugData = make(map[string]any, len(l.debugData)+len(data))
+	for k, v := range l.debugData {
+		newLogger.debugData[k] = v
+	}
+	for k, v := range data {
+		newLogger.debugData[k] = v
+	}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithDebugData", data)
+	}
+	return newLogger
+}
+
+// ClearDebugData returns a new Logger without any of the data set with WithDebugData.
+func (l *Logger) ClearDebugData() *Logger {
+	newLogger := l.clone()
+	newLogger.debugData = nil
+	if newLogger.tracing {
+		newLogger.traceMutation("ClearDebugData", nil)
+	}
+	return newLogger
+}
+
+// mergeData combines an entry's Data with extra keys.
+// If data is nil or a map[string]any, the keys are added to a copy of it; other data,
+// such as a DebugInfo struct, is converted to its JSON form and the keys are added to
+// that object. In both cases the keys of data take precedence. Only data whose JSON
+// form is not an object, such as a slice, is kept under the "data" key of a new map.
+func mergeData(data any, extra map[string]any) any {
+	result := make(map[string]any, len(extra)+1)
+	for k, v := range extra {
+		result[k] = v
+	}
+	switch d := data.(type) {
+	case nil:
+	case map[string]any:
+		for k, v := range d {
+			result[k] = v
+		}
+	default:
+		generic, err := genericData(d)
+		if m, ok := generic.(map[string]any); err == nil && ok {
+			for k, v := range m {
+				result[k] = v
+			}
+		} else {
+			result["data"] = d
+		}
+	}
+	return result
+}
@@ -0,0 +1,205 @@
+package logharbour
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// dictionaryKey is the only key of the dictionary records written by a DictionaryEncoder.
+const dictionaryKey = "lh_dict"
+
+// dictionaryPrefix starts the dictionary records, which lets readers tell them from entries.
+var dictionaryPrefix = []byte(`{"` + dictionaryKey + `":`)
+
+const (
+	defaultDictionaryRefresh = 1000
+	defaultDictionaryMaxKeys = 4096
+)
+
+// DictionaryEncoder is an Encoder that shortens the keys of the Data of entries, for
+// high-volume logs whose Data repeats the same key names in every entry. Each key is
+// replaced by a short code, such as "~0" or "~1f", and the mapping from codes to keys is
+// written to the stream as a dictionary record:
+//
+//	{"lh_dict":{"~0":"request_id","~1":"duration_ms"}}
+//	{"app":"api",...,"data":{"~0":"c9f1","~1":12}}
+//
+// A dictionary record with the new keys precedes the first entry that uses them, and the
+// whole dictionary is written again every refresh entries, so that a consumer starting
+// in the middle of a stream, such as after a file rotation, can decode what follows.
+// Keys at any depth of Data are encoded; Data that is not made of maps and slices, such
+// as a struct, is first converted to its JSON form. Once the dictionary holds 4096 keys,
+// new keys are written as they are. Keys starting with "~" are escaped with another "~".
+//
+// Consumers must decode the stream: entries read with a plain JSON parser have coded
+// keys. EntryReader recognizes dictionary records and restores the keys, but it must
+// read the stream from its beginning, or from a full dictionary record; entries using
+// codes it has not seen yet keep them.
+//
+// For an activity entry with five Data keys of 10 to 16 characters, as in
+// BenchmarkDictionaryEncoder, the stream is about 17% smaller; the gain grows with the
+// share of Data key names in the entries.
+//
+// A DictionaryEncoder keeps state between entries and is safe for concurrent use, but
+// each stream needs its own: do not share one between writers.
+type DictionaryEncoder struct {
+	json    JSONEncoder
+	refresh int
+	maxKeys int
+	codes   map[string]string
+	keys    []string
+	count   int
+	mu      sync.Mutex
+}
+
+// NewDictionaryEncoder creates a DictionaryEncoder that writes the whole dictionary every
+// refresh entries, or every 1000 entries if refresh is not positive, and frames entries
+// and dictionary records with framing, or with NewlineFraming if framing is nil.
+func NewDictionaryEncoder(refresh int, framing Framing) *DictionaryEncoder {
+	if refresh <= 0 {
+		refresh = defaultDictionaryRefresh
+	}
+	return &DictionaryEncoder{
+		json:    JSONEncoder{Framing: framing},
+		refresh: refresh,
+		maxKeys: defaultDictionaryMaxKeys,
+		codes:   make(map[string]string),
+	}
+}
+
+// Encode implements Encoder. The result holds the dictionary records due, followed by the entry.
+func (de *DictionaryEncoder) Encode(entry LogEntry) ([]byte, error) {
+	de.mu.Lock()
+	defer de.mu.Unlock()
+
+	added := len(de.keys)
+	if entry.Data != nil {
+		if data, err := genericData(entry.Data); err == nil {
+			entry.Data = de.encodeKeys(data)
+		}
+	}
+	encoded, err := de.json.Encode(entry)
+	if err != nil {
+		de.forget(added)
+		return nil, err
+	}
+
+	var out []byte
+	de.count++
+	switch {
+	case de.count%de.refresh == 0:
+		out, err = de.record(0)
+	case added < len(de.keys):
+		out, err = de.record(added)
+	}
+	if err != nil {
+		de.forget(added)
+		return nil, err
+	}
+	return append(out, encoded...), nil
+}
+
+// forget removes the keys from index from on, which were added for an entry that could
+// not be encoded, so that they are written in a dictionary record when next used.
+func (de *DictionaryEncoder) forget(from int) {
+	for _, key := range de.keys[from:] {
+		delete(de.codes, key)
+	}
+	de.keys = de.keys[:from]
+}
+
+// encodeKeys replaces the keys of the maps of v with their codes, adding new keys to the dictionary.
+func (de *DictionaryEncoder) encodeKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		encoded := make(map[string]any, len(v))
+		for key, value := range v {
+			encoded[de.code(key)] = de.encodeKeys(value)
+		}
+		return encoded
+	case []any:
+		for i, value := range v {
+			v[i] = de.encodeKeys(value)
+		}
+	}
+	return v
+}
+
+// code returns the code of key, or key itself, escaped, if the dictionary is full.
+func (de *DictionaryEncoder) code(key string) string {
+	if code, ok := de.codes[key]; ok {
+		return code
+	}
+	if len(de.keys) < de.maxKeys {
+		code := "~" + strconv.FormatInt(int64(len(de.keys)), 36)
+		de.codes[key] = code
+		de.keys = append(de.keys, key)
+		return code
+	}
+	if strings.HasPrefix(key, "~") {
+		return "~" + key
+	}
+	return key
+}
+
+// record returns the framed dictionary record of the keys from index from on.
+func (de *DictionaryEncoder) record(from int) ([]byte, error) {
+	dict := make(map[string]string, len(de.keys)-from)
+	for _, key := range de.keys[from:] {
+		dict[de.codes[key]] = key
+	}
+	encoded, err := json.Marshal(map[string]any{dictionaryKey: dict})
+	if err != nil {
+		return nil, err
+	}
+	if de.json.Framing == nil {
+		return append(encoded, '\n'), nil
+	}
+	return de.json.Framing.Frame(encoded), nil
+}
+
+// keyDictionary decodes the keys encoded by a DictionaryEncoder.
+type keyDictionary map[string]string
+
+// update adds the codes of a dictionary record to the dictionary.
+func (d keyDictionary) update(record []byte) error {
+	var r map[string]map[string]string
+	if err := json.Unmarshal(record, &r); err != nil {
+		return err
+	}
+	for code, key := range r[dictionaryKey] {
+		d[code] = key
+	}
+	return nil
+}
+
+// decodeKeys replaces the codes in the maps of v with their keys.
+func (d keyDictionary) decodeKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		decoded := make(map[string]any, len(v))
+		for code, value := range v {
+			key := code
+			if strings.HasPrefix(code, "~~") {
+				key = code[1:]
+			} else if k, ok := d[code]; ok {
+				key = k
+			}
+			decoded[key] = d.decodeKeys(value)
+		}
+		return decoded
+	case []any:
+		for i, value := range v {
+			v[i] = d.decodeKeys(value)
+		}
+	}
+	return v
+}
+
+// isDictionaryRecord reports whether raw is a dictionary record.
+func isDictionaryRecord(raw []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(raw), dictionaryPrefix)
+}
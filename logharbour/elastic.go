@@ -108,6 +108,8 @@ func NewElasticsearchClient(cfg elasticsearch.Config) (*ElasticsearchClient, err
 }
 
 // Write sends a document to Elasticsearch. It implements ElasticsearchWriter.
+// Passing the entry's dedup key as documentID makes repeated writes of the same
+// entry overwrite a single document instead of creating duplicates.
 func (ec *ElasticsearchClient) Write(index string, documentID string, body string) error {
 	req := esapi.IndexRequest{
 		Index:      index,
@@ -0,0 +1,55 @@
+package logharbour
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// EmptyDataMode defines how JSONEncoder writes an empty Data payload, that is, nil or
+// encoded as null, {} or [], so that consumers need not handle several forms of "no data".
+// With EmptyDataOmit, consumers find Data nil when reading such entries back.
+type EmptyDataMode int
+
+const (
+	// EmptyDataAsIs writes Data as encoding/json does: null for nil, {} for an empty
+	// map or struct, [] for an empty slice. This is the default.
+	EmptyDataAsIs EmptyDataMode = iota
+	// EmptyDataOmit leaves the data field out of entries whose Data is empty.
+	EmptyDataOmit
+	// EmptyDataObject writes empty Data as an empty object, {}.
+	EmptyDataObject
+)
+
+// normalizeEmptyData returns the Data to encode for data according to mode, and
+// whether the data field is to be left out.
+func normalizeEmptyData(data any, mode EmptyDataMode) (normalized any, omit bool) {
+	if mode == EmptyDataAsIs || !isEmptyData(data) {
+		return data, false
+	}
+	if mode == EmptyDataOmit {
+		return nil, true
+	}
+	return struct{}{}, false
+}
+
+// isEmptyData reports whether data is nil or is encoded as null, {} or [].
+func isEmptyData(data any) bool {
+	if data == nil {
+		return true
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+	switch string(encoded) {
+	case "null", "{}", "[]":
+		return true
+	}
+	return false
+}
+
+// omitNullData removes the data field from an entry encoded with a nil Data.
+// The fields before data hold no objects, so the first match is the top-level field.
+func omitNullData(encoded []byte) []byte {
+	return bytes.Replace(encoded, []byte(`,"data":null`), nil, 1)
+}
@@ -0,0 +1,314 @@
+package logharbour
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Encoder serializes a log entry into the bytes written to a writer.
+type Encoder interface {
+	Encode(entry LogEntry) ([]byte, error)
+}
+
+// EncoderFunc adapts an ordinary function to the Encoder interface, which is handy for
+// one-off formats such as the shape of a previous log schema.
+type EncoderFunc func(entry LogEntry) ([]byte, error)
+
+// Encode implements Encoder by calling f.
+func (f EncoderFunc) Encode(entry LogEntry) ([]byte, error) {
+	return f(entry)
+}
+
+// JSONEncoder encodes entries as JSON objects, one per line by default. This is the
+// format the Logger uses for writers that have no Encoder of their own. Framing sets
+// how entries are delimited instead, for instance with no separator or with a length
+// prefix; EntryReader reads them back with the same framing.
+// Empty Data is written according to EmptyData.
+//
+// TimePrecision truncates When to a multiple of the given duration, such as time.Second,
+// time.Millisecond or time.Microsecond, for stores that keep less than nanosecond
+// precision or golden tests that should not depend on it. When keeps its location, and
+// trailing zeros are dropped from the fraction of seconds, so a time truncated to
+// milliseconds is written as "2024-01-02T15:04:05.123+05:30". Readers accept any
+// precision.
+//
+// Unlike json.Marshal, the encoder does not escape "<", ">" and "&" as \u003c, \u003e and
+// \u0026, so URLs and markup in messages and data are written as they are; set EscapeHTML
+// for output that may be embedded in HTML. Marshal replaces encoding/json altogether,
+// for instance with a faster library; it is given the entry in the form encoding/json
+// would marshal, and EscapeHTML does not apply to it.
+//
+// By default entries are written in verbose form, with every field present, so that
+// existing parsers and index mappings keep working. Compact leaves out the module, op,
+// class, instance and remote_ip fields when they are empty. The fields app, system, type,
+// pri, when, who, status, msg and data are always present, and the other fields are
+// always omitted when empty (see LogEntry). Entries in either form are read back into
+// the same LogEntry, since missing fields decode to their zero value.
+//
+// MaxLineBytes is a hard cap on the size of an encoded entry, not counting the framing,
+// for pipelines that drop lines over a fixed length; zero means no cap. An entry over the
+// cap is shortened step by step until it fits, and its Truncated field is set:
+//   - if Data is a JSON object, its values are replaced by TruncatedSuffix, largest
+//     first, keeping its keys;
+//   - then Data as a whole is replaced by TruncatedSuffix;
+//   - then Msg is shortened.
+//
+// The other fields are kept, so an entry with very large top-level fields may still be
+// over the cap. The result is always valid JSON.
+//
+// The status is written as an integer by default, so that existing parsers and index
+// mappings keep working. StatusAsString writes it as its name, such as "Success",
+// instead; the status is then written after the other fields. Readers accept both forms.
+type JSONEncoder struct {
+	Framing        Framing                     // Delimits entries; nil means NewlineFraming
+	TimePrecision  time.Duration               // Precision of When; zero or negative means full precision
+	EscapeHTML     bool                        // Escape <, > and & in strings, as json.Marshal does
+	Marshal        func(v any) ([]byte, error) // Encodes the entry as a JSON object; nil means encoding/json
+	StatusAsString bool                        // Write the status as its name rather than as an integer
+	Compact        bool                        // Leave out the module, op, class, instance and remote_ip fields when empty
+	EmptyData      EmptyDataMode               // How empty Data is written; defaults to EmptyDataAsIs
+	MaxLineBytes   int                         // Maximum size of an encoded entry; zero means no cap
+}
+
+// Encode implements Encoder.
+func (je JSONEncoder) Encode(entry LogEntry) ([]byte, error) {
+	if je.TimePrecision > 0 {
+		entry.When = entry.When.Truncate(je.TimePrecision)
+	}
+	var omitData bool
+	entry.Data, omitData = normalizeEmptyData(entry.Data, je.EmptyData)
+	encoded, err := je.marshalEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	if omitData {
+		encoded = omitNullData(encoded)
+	}
+	if je.MaxLineBytes > 0 && len(encoded) > je.MaxLineBytes {
+		if encoded, err = capLine(entry, je.MaxLineBytes, je.marshalEntry); err != nil {
+			return nil, err
+		}
+	}
+	if je.Framing == nil {
+		return append(encoded, '\n'), nil
+	}
+	return je.Framing.Frame(encoded), nil
+}
+
+// marshalEntry encodes entry as a JSON object, with no framing.
+func (je JSONEncoder) marshalEntry(entry LogEntry) ([]byte, error) {
+	return je.marshal(je.encodable(entry))
+}
+
+// encodable returns the value to marshal for entry.
+func (je JSONEncoder) encodable(entry LogEntry) any {
+	// The outer status field hides the one of the embedded entry.
+	switch {
+	case je.Compact && je.StatusAsString:
+		return struct {
+			compactLogEntry
+			Status string `json:"status"`
+		}{compactLogEntry(entry), entry.Status.String()}
+	case je.StatusAsString:
+		return struct {
+			LogEntry
+			Status string `json:"status"`
+		}{entry, entry.Status.String()}
+	case je.Compact:
+		return compactLogEntry(entry)
+	}
+	return entry
+}
+
+// marshal encodes v with Marshal, or with encoding/json, escaping HTML as configured.
+func (je JSONEncoder) marshal(v any) ([]byte, error) {
+	if je.Marshal != nil {
+		return je.Marshal(v)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(je.EscapeHTML)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// ConsoleEncoder encodes entries as human-readable lines for a terminal, such as
+//
+//	15:04:05.000 Info   myapp/billing  invoice sent  op=send who=john status=Success data={"id":42}
+//
+// If Color is set, the priority is colored with ANSI escape codes.
+type ConsoleEncoder struct {
+	Color bool
+}
+
+// Encode implements Encoder.
+func (ce ConsoleEncoder) Encode(entry LogEntry) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString(entry.When.Local().Format("15:04:05.000") + " ")
+	pri := fmt.Sprintf("%-6s", entry.Pri)
+	if ce.Color {
+		pri = priorityColor(entry.Pri) + pri + "\x1b[0m"
+	}
+	sb.WriteString(pri + " ")
+	sb.WriteString(entry.App)
+	if entry.Module != "" {
+		sb.WriteString("/" + entry.Module)
+	}
+	sb.WriteString("  " + entry.Msg + " ")
+	for _, field := range []struct{ name, value string }{
+		{"op", entry.Op},
+		{"who", entry.Who},
+		{"class", entry.Class},
+		{"instance", entry.InstanceId},
+		{"error", entry.Error},
+		{"trace_id", entry.TraceID},
+	} {
+		if field.value != "" {
+			sb.WriteString(" " + field.name + "=" + field.value)
+		}
+	}
+	sb.WriteString(" status=" + entry.Status.String())
+	if entry.Data != nil {
+		data, err := json.Marshal(entry.Data)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(" data=" + string(data))
+	}
+	sb.WriteString("\n")
+	return []byte(sb.String()), nil
+}
+
+// priorityColor returns the ANSI color code used by ConsoleEncoder for a priority.
+func priorityColor(p LogPriority) string {
+	switch {
+	case p <= Debug0:
+		return "\x1b[90m" // gray
+	case p == Info:
+		return "\x1b[32m" // green
+	case p == Warn:
+		return "\x1b[33m" // yellow
+	default:
+		return "\x1b[31m" // red
+	}
+}
+
+// entryWriter is implemented by writers that accept structured log entries in addition
+// to encoded bytes. The Logger passes entries to such writers unencoded, so that each
+// writer in a chain can apply its own Encoder to the same entry.
+type entryWriter interface {
+	io.Writer
+	writeEntry(entry LogEntry) error
+}
+
+// EncodedWriter is a writer that encodes log entries with its own Encoder before
+// writing them to the next writer. Combined with NewMultiWriter, it lets a Logger write
+// the same entries in different formats to different destinations:
+//
+//	writer := logharbour.NewMultiWriter(
+//		logharbour.NewEncodedWriter(os.Stdout, logharbour.ConsoleEncoder{Color: true}),
+//		file, // JSON
+//	)
+//
+// When the Logger writes to an EncodedWriter directly or through a MultiWriter, it hands
+// over the entry unencoded. When the EncodedWriter is behind another io.Writer, such as
+// a FallbackWriter, it receives JSON, which it decodes and encodes again.
+type EncodedWriter struct {
+	next io.Writer
+	enc  Encoder
+}
+
+// NewEncodedWriter creates an EncodedWriter that writes entries encoded with enc to next.
+func NewEncodedWriter(next io.Writer, enc Encoder) *EncodedWriter {
+	return &EncodedWriter{next: next, enc: enc}
+}
+
+// Write decodes a JSON log entry and writes it in the writer's format. It implements io.Writer.
+func (ew *EncodedWriter) Write(p []byte) (n int, err error) {
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return 0, err
+	}
+	if err := ew.writeEntry(entry); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry encodes entry and writes it to the next writer.
+func (ew *EncodedWriter) writeEntry(entry LogEntry) error {
+	encoded, err := ew.enc.Encode(entry)
+	if err != nil {
+		return err
+	}
+	_, err = ew.next.Write(encoded)
+	return err
+}
+
+// MultiWriter writes each log entry to all of its writers, like io.MultiWriter.
+// Writers with their own Encoder, such as an EncodedWriter, receive the entry
+// unencoded; the others receive it as JSON.
+//
+// Pairing each writer with an Encoder renders the same entry in several formats from a
+// single log call, for example to feed the old and the new pipeline in parallel during
+// a schema migration:
+//
+//	writer := logharbour.NewMultiWriter(
+//		logharbour.NewEncodedWriter(oldSink, logharbour.EncoderFunc(encodeOldSchema)),
+//		logharbour.NewEncodedWriter(newSink, logharbour.JSONEncoder{}),
+//	)
+//
+// Every entry is encoded once per writer, so the cost of encoding grows with the number
+// of formats: two formats roughly double the CPU time and allocations spent on encoding.
+// Writers sharing the JSON format should be grouped under one writer, such as an
+// io.MultiWriter wrapped by a single EncodedWriter, to encode the entry only once for them.
+type MultiWriter struct {
+	writers []io.Writer
+}
+
+// NewMultiWriter creates a MultiWriter that writes to all of writers.
+func NewMultiWriter(writers ...io.Writer) *MultiWriter {
+	return &MultiWriter{writers: writers}
+}
+
+// Write decodes a JSON log entry and writes it to all writers. It implements io.Writer.
+func (mw *MultiWriter) Write(p []byte) (n int, err error) {
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return 0, err
+	}
+	if err := mw.writeEntry(entry); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry writes entry to every writer, even if some fail, and returns the first error.
+func (mw *MultiWriter) writeEntry(entry LogEntry) error {
+	var firstErr error
+	for _, w := range mw.writers {
+		if err := writeEntryTo(w, entry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeEntryTo writes entry to w, unencoded if w is an entryWriter and as JSON otherwise.
+func writeEntryTo(w io.Writer, entry LogEntry) error {
+	if ew, ok := w.(entryWriter); ok {
+		return ew.writeEntry(entry)
+	}
+	encoded, err := JSONEncoder{}.Encode(entry)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
@@ -0,0 +1,186 @@
+package logharbour
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EncryptedPrefix starts the values encrypted by a FieldCipher:
+//
+//	"lhenc:<key ID>:<base64 of nonce and ciphertext>"
+const EncryptedPrefix = "lhenc:"
+
+// ErrUnknownFieldKey is returned by DecryptEntry for values encrypted with a key it was not given.
+var ErrUnknownFieldKey = errors.New("no cipher for the key of an encrypted value")
+
+// FieldCipher encrypts the values of sensitive fields in the Data of entries, for values
+// that must not be readable in the logs but must be recoverable by authorized tools,
+// which redaction does not allow. Each value is replaced by a string holding its JSON
+// form encrypted with an AEAD, such as AES-256-GCM, under a random nonce; the name of the
+// field is authenticated with the value, so that a value cannot be moved to another field
+// unnoticed. The other fields of the entry are left readable.
+//
+//	block, _ := aes.NewCipher(key) // 32 bytes, from the key management system
+//	aead, _ := cipher.NewGCM(block)
+//	fc := logharbour.NewFieldCipher("2024-01", aead)
+//	logger = logger.WithRedactor(fc.EncryptDataKeys("pan", "dob"), nil)
+//
+// To also redact other fields, combine the redactors with ChainRedactors, since
+// WithRedactor holds a single redactor.
+//
+// Key management is up to the application: the keys are symmetric, so the key used by
+// the writing services is also the key that decrypts, and it should come from a key
+// management system rather than from configuration files. The key ID is written with
+// every value; when rotating keys, use a new ID and keep the old keys available to
+// DecryptEntry for as long as entries encrypted with them are retained. With AES-GCM and
+// random nonces, a key should not encrypt more than about 2^32 values.
+type FieldCipher struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewFieldCipher creates a FieldCipher that encrypts with aead, identified by keyID.
+// keyID must not contain ":".
+func NewFieldCipher(keyID string, aead cipher.AEAD) *FieldCipher {
+	return &FieldCipher{keyID: keyID, aead: aead}
+}
+
+// EncryptDataKeys returns a Redactor, to be passed to WithRedactor, that encrypts the
+// values of the given keys in the Data of entries, at any depth. As with RedactDataKeys,
+// Data that is not made of maps and slices is first converted to its JSON form, and the
+// caller's data is never modified. A value that cannot be encrypted, and Data that cannot
+// be converted, is replaced with RedactedArg.
+func (fc *FieldCipher) EncryptDataKeys(keys ...string) Redactor {
+	encrypted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		encrypted[key] = true
+	}
+	return RedactorFunc(func(entry *LogEntry) {
+		if entry.Data == nil {
+			return
+		}
+		data, err := genericData(entry.Data)
+		if err != nil {
+			entry.Data = RedactedArg // fail closed
+			return
+		}
+		entry.Data = fc.encryptKeys(data, encrypted)
+	})
+}
+
+// encryptKeys replaces the values of the encrypted keys in the maps of v.
+func (fc *FieldCipher) encryptKeys(v any, encrypted map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if encrypted[key] {
+				v[key] = fc.encrypt(key, value)
+			} else {
+				v[key] = fc.encryptKeys(value, encrypted)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = fc.encryptKeys(value, encrypted)
+		}
+	}
+	return v
+}
+
+// encrypt returns the encrypted form of the value of key, or RedactedArg if it fails.
+func (fc *FieldCipher) encrypt(key string, value any) string {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return RedactedArg
+	}
+	nonce := make([]byte, fc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return RedactedArg
+	}
+	sealed := fc.aead.Seal(nonce, nonce, plaintext, []byte(key))
+	return EncryptedPrefix + fc.keyID + ":" + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// DecryptEntry restores the values of the Data of entry encrypted by FieldCiphers with
+// the keys of ciphers, at any depth. The Data is converted to its generic JSON form, as
+// when the entry is read from a log. It returns an error, and leaves entry unchanged, if
+// a value cannot be decrypted, such as when its key is not among ciphers.
+func DecryptEntry(entry *LogEntry, ciphers ...*FieldCipher) error {
+	if entry.Data == nil {
+		return nil
+	}
+	byID := make(map[string]*FieldCipher, len(ciphers))
+	for _, fc := range ciphers {
+		byID[fc.keyID] = fc
+	}
+	data, err := genericData(entry.Data)
+	if err != nil {
+		return err
+	}
+	data, err = decryptKeys(data, byID)
+	if err != nil {
+		return err
+	}
+	entry.Data = data
+	return nil
+}
+
+// decryptKeys replaces the encrypted values in the maps of v.
+func decryptKeys(v any, ciphers map[string]*FieldCipher) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			var err error
+			if s, ok := value.(string); ok && strings.HasPrefix(s, EncryptedPrefix) {
+				v[key], err = decrypt(key, s, ciphers)
+			} else {
+				v[key], err = decryptKeys(value, ciphers)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, value := range v {
+			var err error
+			if v[i], err = decryptKeys(value, ciphers); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// decrypt returns the value of key encrypted in s.
+func decrypt(key, s string, ciphers map[string]*FieldCipher) (any, error) {
+	keyID, encoded, ok := strings.Cut(strings.TrimPrefix(s, EncryptedPrefix), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed encrypted value of %q", key)
+	}
+	fc, ok := ciphers[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q of %q", ErrUnknownFieldKey, keyID, key)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < fc.aead.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted value of %q", key)
+	}
+	nonce, ciphertext := sealed[:fc.aead.NonceSize()], sealed[fc.aead.NonceSize():]
+	plaintext, err := fc.aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt the value of %q: %w", key, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(plaintext))
+	dec.UseNumber() // as genericData does for the rest of the data
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,129 @@
+package logharbour
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// firstCustomLogType is the value of the first LogType registered with RegisterLogType.
+// Values below it are reserved for the built-in types.
+const firstCustomLogType LogType = 100
+
+// logTypes is the registry of the custom log types.
+var logTypes = struct {
+	sync.RWMutex
+	byName map[string]LogType // Keyed by lower-case name.
+	names  []string           // Names of the registered types, by value - firstCustomLogType.
+}{byName: make(map[string]LogType)}
+
+// RegisterLogType registers a domain-specific log type, such as "Audit" or "Security",
+// and returns its LogType, so that teams can categorize entries for routing and
+// dashboards beyond Change, Activity, Debug and Metric. Registering a name again returns
+// the same LogType. Names are letters, digits and underscores, start with a letter, and
+// are matched case-insensitively; the name as first registered is the one written in the
+// "type" field of entries. The names of the built-in types, short ("C") or long
+// ("Change"), cannot be registered.
+//
+// Types are usually registered at package initialization, before logging:
+//
+//	var Audit = logharbour.MustRegisterLogType("Audit")
+//	...
+//	logger.WithType("Audit").Log("export approved")
+//
+// Custom types are LogType values like the built-in ones, so they work wherever a
+// LogType does, such as WithRequiredFields. Their numeric values, from 100 on, depend on
+// the order of registration and are only meaningful within the process; entries always
+// carry the name, which is what consumers should match on. The built-in types keep their
+// values and short names, so existing entries and consumers are not affected, but
+// consumers that only know the built-in names reject entries of custom types, and so
+// does UnmarshalJSON in a process that has not registered them.
+func RegisterLogType(name string) (LogType, error) {
+	if !isLogTypeName(name) {
+		return 0, fmt.Errorf("invalid log type name %q", name)
+	}
+	if _, err := parseBuiltinLogType(name); err == nil {
+		return 0, fmt.Errorf("log type %q is built in", name)
+	}
+
+	logTypes.Lock()
+	defer logTypes.Unlock()
+	key := strings.ToLower(name)
+	if lt, ok := logTypes.byName[key]; ok {
+		return lt, nil
+	}
+	lt := firstCustomLogType + LogType(len(logTypes.names))
+	logTypes.byName[key] = lt
+	logTypes.names = append(logTypes.names, name)
+	return lt, nil
+}
+
+// MustRegisterLogType is like RegisterLogType but panics if the name is invalid.
+func MustRegisterLogType(name string) LogType {
+	lt, err := RegisterLogType(name)
+	if err != nil {
+		panic(err)
+	}
+	return lt
+}
+
+// WithType returns a new Logger whose entries have the log type named name, whichever
+// method logs them. name is parsed by ParseLogType, so it is either a built-in type, such
+// as "Change" or "activity", or a type registered with RegisterLogType. If no type has
+// that name, the entries fail validation and are written to the fallback writer, like
+// other invalid entries.
+func (l *Logger) WithType(name string) *Logger {
+	newLogger := l.clone()
+	newLogger.logType = invalidLogType
+	if lt, err := ParseLogType(name); err == nil {
+		newLogger.logType = lt
+	}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithType", name)
+	}
+	return newLogger
+}
+
+// invalidLogType is set by WithType for unknown names, to have the entries rejected.
+const invalidLogType LogType = -1
+
+// lookupLogType returns the custom log type registered under name.
+func lookupLogType(name string) (LogType, bool) {
+	logTypes.RLock()
+	defer logTypes.RUnlock()
+	lt, ok := logTypes.byName[strings.ToLower(strings.TrimSpace(name))]
+	return lt, ok
+}
+
+// customLogTypeName returns the name of a custom log type, if lt is registered.
+func customLogTypeName(lt LogType) (string, bool) {
+	logTypes.RLock()
+	defer logTypes.RUnlock()
+	i := int(lt - firstCustomLogType)
+	if i < 0 || i >= len(logTypes.names) {
+		return "", false
+	}
+	return logTypes.names[i], true
+}
+
+// isLogTypeName reports whether name can be the name of a custom log type.
+func isLogTypeName(name string) bool {
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || i > 0 && (unicode.IsDigit(r) || r == '_')) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// newValidator returns the validator of log entries, which knows the "logtype" tag.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("logtype", func(fl validator.FieldLevel) bool {
+		return LogType(fl.Field().Int()).IsValid()
+	})
+	return v
+}
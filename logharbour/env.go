@@ -0,0 +1,45 @@
+package logharbour
+
+import "fmt"
+
+// EnvVar is the environment variable from which NewLogger and NewLoggerWithFallback
+// take the initial deployment environment of a Logger, such as "dev", "staging" or "prod".
+const EnvVar = "APP_ENV"
+
+// WithEnv returns a new Logger with the 'env' field set to the specified value.
+// The environment is omitted from entries when empty. Loggers take their initial
+// environment from the EnvVar environment variable.
+func (l *Logger) WithEnv(env string) *Logger {
+	newLogger := l.clone()
+	newLogger.env = env
+	if newLogger.tracing {
+		newLogger.traceMutation("WithEnv", env)
+	}
+	return newLogger
+}
+
+// SetAllowedEnvs restricts the environments of entries logged by Loggers using this
+// context to envs. Entries with any other non-empty environment fail validation and
+// are handled like other invalid entries. Calling it without arguments removes the restriction.
+func (lc *LoggerContext) SetAllowedEnvs(envs ...string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if len(envs) == 0 {
+		lc.allowedEnvs = nil
+		return
+	}
+	lc.allowedEnvs = make(map[string]bool, len(envs))
+	for _, env := range envs {
+		lc.allowedEnvs[env] = true
+	}
+}
+
+// checkEnv returns an error if env is not in the context's allowed environments.
+func (lc *LoggerContext) checkEnv(env string) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if env == "" || lc.allowedEnvs == nil || lc.allowedEnvs[env] {
+		return nil
+	}
+	return fmt.Errorf("environment %q is not allowed", env)
+}
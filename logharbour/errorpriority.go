@@ -0,0 +1,50 @@
+package logharbour
+
+// WithErrorPriorityMapper returns a new Logger whose failed results, logged with
+// LogResult and the helpers built on it such as LogQuery, get the priority returned by
+// mapper for their error instead of Err. This keeps the error severity policy of an
+// application in one place rather than in Warn() or Crit() calls at every call site.
+// The mapper is inherited by clones. Pass nil to restore the default.
+//
+// The mapper should use errors.Is and errors.As, so that wrapped errors are mapped like
+// the errors they wrap. If it returns an invalid priority, Err is used. If it panics,
+// the panic is handled like that of other callbacks (see LoggerContext.SetPropagatePanics)
+// and Err is used.
+//
+//	logger = logger.WithErrorPriorityMapper(func(err error) logharbour.LogPriority {
+//		var pgErr *pgconn.PgError
+//		switch {
+//		case errors.Is(err, ErrNotFound):
+//			return logharbour.Info
+//		case errors.As(err, &pgErr):
+//			return logharbour.Crit
+//		default:
+//			return logharbour.Err
+//		}
+//	})
+func (l *Logger) WithErrorPriorityMapper(mapper func(error) LogPriority) *Logger {
+	newLogger := l.clone()
+	newLogger.errorPriority = mapper
+	if newLogger.tracing {
+		newLogger.traceMutation("WithErrorPriorityMapper", mapper)
+	}
+	return newLogger
+}
+
+// errorPriorityOf returns the priority of entry, a result that failed with err.
+func (l *Logger) errorPriorityOf(entry LogEntry, err error) LogPriority {
+	if l.errorPriority == nil {
+		return Err
+	}
+	// The report leaves out Data, which is not resolved or redacted yet.
+	withoutData := entry
+	withoutData.Data = nil
+	var p LogPriority
+	if !l.callSafely("error priority mapper", withoutData, func() { p = l.errorPriority(err) }) {
+		return Err
+	}
+	if p >= Debug2 && p <= Sec {
+		return p
+	}
+	return Err
+}
@@ -0,0 +1,72 @@
+package logharbour
+
+import (
+	"sync"
+	"time"
+)
+
+// escalationPolicy raises the priority of matching log entries once they repeat
+// more than count times within window.
+type escalationPolicy struct {
+	match  func(LogEntry) bool
+	count  int
+	window time.Duration
+	to     LogPriority
+	seen   []time.Time // times of the last count+1 matching entries within window, oldest first
+	mu     sync.Mutex
+}
+
+// WithEscalation returns a new Logger that escalates repeated entries.
+// Every entry for which match returns true is counted. Once more than count matching
+// entries have been logged within window, each further matching entry is logged with
+// priority to, and its EscalatedFrom field records the original priority.
+// Entries whose priority is already at or above to are left unchanged.
+//
+// The escalation state is shared by all loggers cloned from the returned Logger.
+//
+// Example: escalate to Crit when more than 10 Err entries are logged within a minute.
+//
+//	logger = logger.WithEscalation(func(e logharbour.LogEntry) bool {
+//		return e.Pri == logharbour.Err
+//	}, 10, time.Minute, logharbour.Crit)
+func (l *Logger) WithEscalation(match func(LogEntry) bool, count int, window time.Duration, to LogPriority) *Logger {
+	newLogger := l.clone()
+	newLogger.escalation = &escalationPolicy{
+		match:  match,
+		count:  count,
+		window: window,
+		to:     to,
+	}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithEscalation", to)
+	}
+	return newLogger
+}
+
+// apply counts entry if it matches the policy and escalates it if the threshold is exceeded.
+func (ep *escalationPolicy) apply(entry *LogEntry) {
+	if !ep.match(*entry) {
+		return
+	}
+
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-ep.window)
+	i := 0
+	for i < len(ep.seen) && !ep.seen[i].After(cutoff) {
+		i++
+	}
+	ep.seen = append(ep.seen[i:], now)
+	// Only the last count+1 times decide whether the threshold is exceeded, so older
+	// ones are dropped to keep a burst of matching entries from growing the slice.
+	if keep := ep.count + 1; keep > 0 && len(ep.seen) > keep {
+		ep.seen = ep.seen[len(ep.seen)-keep:]
+	}
+
+	if len(ep.seen) > ep.count && entry.Pri < ep.to {
+		entry.EscalatedFrom = entry.Pri
+		entry.Pri = ep.to
+	}
+}
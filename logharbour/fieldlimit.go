@@ -0,0 +1,124 @@
+package logharbour
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncatedSuffix is appended to field values shortened by WithMaxFieldBytes.
+const TruncatedSuffix = "...(truncated)"
+
+// dataFieldPrefix prefixes field names that refer to keys within the Data payload.
+const dataFieldPrefix = "data."
+
+// limitableFields maps the JSON names of the string fields of LogEntry that can be
+// limited to a function returning a pointer to the field.
+var limitableFields = map[string]func(*LogEntry) *string{
+	"app":       func(e *LogEntry) *string { return &e.App },
+	"system":    func(e *LogEntry) *string { return &e.System },
+	"module":    func(e *LogEntry) *string { return &e.Module },
+	"who":       func(e *LogEntry) *string { return &e.Who },
+	"op":        func(e *LogEntry) *string { return &e.Op },
+	"class":     func(e *LogEntry) *string { return &e.Class },
+	"instance":  func(e *LogEntry) *string { return &e.InstanceId },
+	"error":     func(e *LogEntry) *string { return &e.Error },
+	"remote_ip": func(e *LogEntry) *string { return &e.RemoteIP },
+	"msg":       func(e *LogEntry) *string { return &e.Msg },
+}
+
+// WithMaxFieldBytes returns a new Logger that truncates the named field to at most n bytes,
+// followed by TruncatedSuffix, whenever it is longer than that.
+// The field is named by its JSON name, e.g. "msg" or "error". Keys within Data are named
+// with a "data." prefix and may be nested, e.g. "data.stackTrace" or "data.request.body";
+// only string values are truncated.
+// Truncation never splits a multi-byte UTF-8 character.
+// An error is returned if the field name is unknown or n is not positive.
+func (l *Logger) WithMaxFieldBytes(field string, n int) (*Logger, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid maximum size %d for field %q", n, field)
+	}
+	if _, ok := limitableFields[field]; !ok {
+		if !strings.HasPrefix(field, dataFieldPrefix) || len(field) == len(dataFieldPrefix) {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	newLogger := l.clone()
+	newLogger.fieldLimits = make(map[string]int, len(l.fieldLimits)+1)
+	for k, v := range l.fieldLimits {
+		newLogger.fieldLimits[k] = v
+	}
+	newLogger.fieldLimits[field] = n
+	if newLogger.tracing {
+		newLogger.traceMutation("WithMaxFieldBytes", []any{field, n})
+	}
+	return newLogger, nil
+}
+
+// ClearFieldLimits returns a new Logger without any of the field size limits set
+// with WithMaxFieldBytes.
+func (l *Logger) ClearFieldLimits() *Logger {
+	newLogger := l.clone()
+	newLogger.fieldLimits = nil
+	if newLogger.tracing {
+		newLogger.traceMutation("ClearFieldLimits", nil)
+	}
+	return newLogger
+}
+
+// applyFieldLimits truncates the fields of entry according to limits.
+func applyFieldLimits(entry *LogEntry, limits map[string]int) {
+	var dataLimits map[string]int
+	for field, n := range limits {
+		if get, ok := limitableFields[field]; ok {
+			p := get(entry)
+			*p = truncateString(*p, n)
+			continue
+		}
+		if dataLimits == nil {
+			dataLimits = make(map[string]int)
+		}
+		dataLimits[strings.TrimPrefix(field, dataFieldPrefix)] = n
+	}
+	if dataLimits == nil || entry.Data == nil {
+		return
+	}
+
+	// Convert the payload to its generic JSON form so that limits apply to any Data type.
+	data, err := genericData(entry.Data)
+	if err != nil {
+		return
+	}
+	for path, n := range dataLimits {
+		truncatePath(data, strings.Split(path, "."), n)
+	}
+	entry.Data = data
+}
+
+// truncatePath truncates the string at path within v, if there is one.
+func truncatePath(v any, path []string, n int) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	if len(path) > 1 {
+		truncatePath(m[path[0]], path[1:], n)
+		return
+	}
+	if s, ok := m[path[0]].(string); ok {
+		m[path[0]] = truncateString(s, n)
+	}
+}
+
+// truncateString shortens s to at most n bytes plus TruncatedSuffix,
+// backing off to the start of a UTF-8 character if needed.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + TruncatedSuffix
+}
@@ -0,0 +1,58 @@
+package logharbour
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// FieldMutation records one 'With' or 'Clear' call applied to a Logger with field tracing enabled.
+type FieldMutation struct {
+	Method string // Name of the method, e.g. "WithModule".
+	Value  any    // Value passed to the method.
+	Caller string // File and line of the call, e.g. "handler.go:42".
+}
+
+// String returns a readable form of the mutation, e.g. `WithModule("billing") at handler.go:42`.
+func (m FieldMutation) String() string {
+	return fmt.Sprintf("%s(%#v) at %s", m.Method, m.Value, m.Caller)
+}
+
+// WithFieldTracing returns a new Logger that records every 'With' call, whether it sets an
+// entry field (such as WithModule, WithWho or WithPriority) or a setting (such as
+// WithTimeZone or WithRedactor), and every 'Clear' call, such as ClearBaggage, on it and
+// on the loggers derived from it. The recorded sequence is returned by FieldTrace and
+// helps to find which call set a field to a surprising value, e.g. in a stack of
+// middleware.
+//
+// Tracing is meant for debugging. Loggers without tracing don't record anything.
+func (l *Logger) WithFieldTracing() *Logger {
+	newLogger := l.clone()
+	newLogger.tracing = true
+	return newLogger
+}
+
+// FieldTrace returns the 'With' and 'Clear' calls recorded since field tracing was enabled, oldest first.
+// It returns nil if field tracing is not enabled.
+func (l *Logger) FieldTrace() []FieldMutation {
+	if !l.tracing {
+		return nil
+	}
+	return append([]FieldMutation(nil), l.mutations...)
+}
+
+// traceMutation records a 'With' or 'Clear' call. It must be called directly from the
+// method so that the caller is reported correctly, and only if l.tracing is set, which
+// the method checks first so that value is not converted to any when tracing is off:
+// the conversion allocates, and field tracing must cost nothing when disabled.
+func (l *Logger) traceMutation(method string, value any) {
+	caller := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = fmt.Sprintf("%s:%d", file, line)
+	}
+	// Limit the capacity so that appending never writes into a slice shared with the parent.
+	l.mutations = append(l.mutations[:len(l.mutations):len(l.mutations)], FieldMutation{
+		Method: method,
+		Value:  value,
+		Caller: caller,
+	})
+}
@@ -0,0 +1,24 @@
+package logharbour
+
+// FlagEvaluation is the Data of an entry logged by LogFlagEvaluation.
+type FlagEvaluation struct {
+	Flag    string `json:"flag"`    // Key of the feature flag.
+	Variant string `json:"variant"` // Variant served, e.g. "on", "off" or "blue".
+	Reason  string `json:"reason"`  // Why this variant was served, e.g. "targeting_match" or "default".
+}
+
+// LogFlagEvaluation logs the decision of a feature-flag system as an activity entry, so
+// that questions such as "why did user X see variant Y" can be answered from the logs.
+// The entry carries the Logger's who, op and other fields, the message
+// "flag <flag> evaluated to <variant>" and a FlagEvaluation as its data:
+//
+//	"data": {"flag": "new-checkout", "variant": "on", "reason": "targeting_match"}
+//
+// This shape is stable: fields may be added but are not renamed or removed.
+func (l *Logger) LogFlagEvaluation(flag string, variant string, reason string) {
+	l.LogActivity("flag "+flag+" evaluated to "+variant, FlagEvaluation{
+		Flag:    flag,
+		Variant: variant,
+		Reason:  reason,
+	})
+}
@@ -0,0 +1,118 @@
+package logharbour
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// followPollInterval is how often FollowReader checks a file for new data.
+const followPollInterval = 250 * time.Millisecond
+
+// FollowReader reads the log entries in the file at path, like tail -f. It first sends the
+// entries already in the file, then keeps waiting for new entries as they are appended.
+//
+// If the file is truncated, reading restarts from its beginning. If the file is rotated,
+// that is, path now refers to a new file, the rest of the old file is read and reading
+// continues with the new file. The file is polled for changes, so new entries are
+// delivered with a short delay.
+//
+// Lines that are not valid log entries are reported on the error channel and skipped.
+// Both channels are closed when ctx is cancelled or the file can no longer be read;
+// the caller must keep draining them until then.
+func FollowReader(ctx context.Context, path string) (<-chan LogEntry, <-chan error) {
+	entries := make(chan LogEntry)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(entries)
+		defer close(errs)
+		if err := follow(ctx, path, entries, errs); err != nil && !errors.Is(err, context.Canceled) {
+			errs <- err
+		}
+	}()
+	return entries, errs
+}
+
+// follow implements FollowReader. It returns when ctx is done or a file error occurs.
+func follow(ctx context.Context, path string, entries chan<- LogEntry, errs chan<- error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	var partial []byte
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		offset += int64(len(line))
+		if err == nil {
+			line = append(partial, line...)
+			partial = nil
+			var entry LogEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				select {
+				case errs <- fmt.Errorf("invalid log entry at offset %d: %w", offset-int64(len(line)), jsonErr):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				continue
+			}
+			select {
+			case entries <- entry:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		// Keep an incomplete last line until the rest of it is written.
+		partial = append(partial, line...)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(followPollInterval):
+		}
+
+		current, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if current.Size() < offset {
+			// The file was truncated: start again from the beginning.
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			reader.Reset(file)
+			offset, partial = 0, nil
+			continue
+		}
+		if current.Size() > offset {
+			continue
+		}
+		latest, err := os.Stat(path)
+		if err != nil || os.SameFile(current, latest) {
+			// Either the file is being rotated right now or nothing changed.
+			continue
+		}
+		// The file was rotated and the old one has been read completely.
+		newFile, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		file.Close()
+		file = newFile
+		reader.Reset(file)
+		offset, partial = 0, nil
+	}
+}
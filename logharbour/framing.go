@@ -0,0 +1,127 @@
+package logharbour
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Framing delimits the entries written by a JSONEncoder in a stream, and lets an
+// EntryReader find them again. The default framing is a newline after each entry.
+type Framing interface {
+	// Frame returns the encoded entry with its framing.
+	Frame(entry []byte) []byte
+	// reader returns a function that reads the successive entries of r, without their framing.
+	reader(r io.Reader) func() ([]byte, error)
+}
+
+// SeparatorFraming writes the separator after each entry, e.g. "\n" or "\x1e".
+// An empty separator writes the JSON objects back to back, which suits sinks that add
+// their own framing; readers then split the stream at the end of each object.
+type SeparatorFraming string
+
+// NewlineFraming writes a newline after each entry. It is the default framing.
+const NewlineFraming SeparatorFraming = "\n"
+
+// Frame implements Framing.
+func (f SeparatorFraming) Frame(entry []byte) []byte {
+	return append(entry, f...)
+}
+
+func (f SeparatorFraming) reader(r io.Reader) func() ([]byte, error) {
+	if f == "" {
+		dec := json.NewDecoder(r)
+		return func() ([]byte, error) {
+			var raw json.RawMessage
+			err := dec.Decode(&raw)
+			return raw, err
+		}
+	}
+	br := bufio.NewReader(r)
+	return func() ([]byte, error) {
+		var entry []byte
+		for {
+			b, err := br.ReadByte()
+			if err != nil {
+				if err == io.EOF && len(bytes.TrimSpace(entry)) > 0 {
+					return entry, nil // the last entry may lack its separator
+				}
+				return nil, err
+			}
+			entry = append(entry, b)
+			if bytes.HasSuffix(entry, []byte(f)) {
+				return entry[:len(entry)-len(f)], nil
+			}
+		}
+	}
+}
+
+// LengthPrefixFraming writes each entry preceded by its length as a 4-byte big-endian
+// integer, for binary streams that expect length-prefixed records.
+type LengthPrefixFraming struct{}
+
+// Frame implements Framing.
+func (LengthPrefixFraming) Frame(entry []byte) []byte {
+	framed := make([]byte, 4, 4+len(entry))
+	binary.BigEndian.PutUint32(framed, uint32(len(entry)))
+	return append(framed, entry...)
+}
+
+func (LengthPrefixFraming) reader(r io.Reader) func() ([]byte, error) {
+	br := bufio.NewReader(r)
+	return func() ([]byte, error) {
+		var size [4]byte
+		if _, err := io.ReadFull(br, size[:]); err != nil {
+			return nil, err
+		}
+		entry := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(br, entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return entry, nil
+	}
+}
+
+// EntryReader reads the log entries written with a given framing from a stream.
+// It decodes the Data keys of streams written by a DictionaryEncoder.
+type EntryReader struct {
+	next func() ([]byte, error)
+	dict keyDictionary
+}
+
+// NewEntryReader creates an EntryReader that reads entries framed with framing from r.
+// A nil framing means NewlineFraming.
+func NewEntryReader(r io.Reader, framing Framing) *EntryReader {
+	if framing == nil {
+		framing = NewlineFraming
+	}
+	return &EntryReader{next: framing.reader(r)}
+}
+
+// Next returns the next entry. It returns io.EOF when there are no more entries.
+func (er *EntryReader) Next() (LogEntry, error) {
+	var entry LogEntry
+	raw, err := er.next()
+	for err == nil && isDictionaryRecord(raw) {
+		if er.dict == nil {
+			er.dict = make(keyDictionary)
+		}
+		if err = er.dict.update(raw); err == nil {
+			raw, err = er.next()
+		}
+	}
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(raw, &entry)
+	if er.dict != nil {
+		entry.Data = er.dict.decodeKeys(entry.Data)
+	}
+	return entry, err
+}
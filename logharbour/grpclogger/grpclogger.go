@@ -0,0 +1,119 @@
+// Package grpclogger provides gRPC server interceptors that log requests with logharbour.
+//
+// It lives in its own package so that applications which do not use gRPC do not need
+// to depend on it.
+package grpclogger
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/remiges-tech/logharbour/logharbour"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a unary server interceptor that logs every call.
+//
+// For each call it derives a Logger from base with op set to the full method name,
+// remoteIP set to the peer address and the trace ID taken from the request metadata
+// (see traceIDFromMetadata). The Logger is stored in the call context, where handlers
+// can retrieve it with logharbour.FromContext. When the handler returns, an activity
+// entry is logged with the gRPC status code and the duration of the call.
+func UnaryServerInterceptor(base *logharbour.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		logger := requestLogger(ctx, base, info.FullMethod)
+		resp, err := handler(logharbour.NewContext(ctx, logger), req)
+		logCompletion(logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor that logs every stream.
+// It derives and stores the Logger in the same way as UnaryServerInterceptor and logs
+// an activity entry when the stream handler returns.
+func StreamServerInterceptor(base *logharbour.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		logger := requestLogger(ss.Context(), base, info.FullMethod)
+		err := handler(srv, &loggingStream{
+			ServerStream: ss,
+			ctx:          logharbour.NewContext(ss.Context(), logger),
+		})
+		logCompletion(logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// loggingStream overrides the context of a grpc.ServerStream.
+type loggingStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream context carrying the request-scoped Logger.
+func (s *loggingStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestLogger derives the request-scoped Logger for a call.
+func requestLogger(ctx context.Context, base *logharbour.Logger, method string) *logharbour.Logger {
+	logger := base.WithOp(method)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		logger = logger.WithRemoteIP(addr)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if traceID := traceIDFromMetadata(md); traceID != "" {
+			logger = logger.WithTraceID(traceID)
+		}
+	}
+	return logger
+}
+
+// traceIDFromMetadata returns the trace ID of a call from its metadata. It looks at the
+// W3C "traceparent" header, then "x-b3-traceid" and finally "x-request-id".
+func traceIDFromMetadata(md metadata.MD) string {
+	if v := md.Get("traceparent"); len(v) > 0 {
+		// version-traceid-parentid-flags
+		if parts := strings.Split(v[0], "-"); len(parts) == 4 {
+			return parts[1]
+		}
+	}
+	for _, key := range []string{"x-b3-traceid", "x-request-id"} {
+		if v := md.Get(key); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// logCompletion logs the outcome of a call. Errors caused by the client are logged at
+// Warn and all other errors at Err, both with status Failure.
+func logCompletion(logger *logharbour.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	data := map[string]any{
+		"method":      method,
+		"code":        code.String(),
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+
+	switch code {
+	case codes.OK:
+		logger.WithStatus(logharbour.Success).LogActivity("call completed", data)
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange:
+		logger.WithStatus(logharbour.Failure).Error(err).Warn().LogActivity("call completed", data)
+	default:
+		logger.WithStatus(logharbour.Failure).Error(err).Err().LogActivity("call completed", data)
+	}
+}
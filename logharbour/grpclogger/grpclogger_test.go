@@ -0,0 +1,143 @@
+package grpclogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/remiges-tech/logharbour/logharbour"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestTraceIDFromMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string
+	}{
+		{"traceparent", metadata.Pairs("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"), "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"malformed traceparent", metadata.Pairs("traceparent", "garbage", "x-request-id", "req-1"), "req-1"},
+		{"b3", metadata.Pairs("x-b3-traceid", "b3-trace", "x-request-id", "req-1"), "b3-trace"},
+		{"request id", metadata.Pairs("x-request-id", "req-1"), "req-1"},
+		{"empty b3", metadata.Pairs("x-b3-traceid", "", "x-request-id", "req-1"), "req-1"},
+		{"none", metadata.MD{}, ""},
+	}
+	for _, tt := range tests {
+		if got := traceIDFromMetadata(tt.md); got != tt.want {
+			t.Errorf("%s: expected %q. Got: %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestLogCompletion(t *testing.T) {
+	tests := []struct {
+		err    error
+		pri    logharbour.LogPriority
+		status logharbour.Status
+		code   string
+	}{
+		{nil, logharbour.Info, logharbour.Success, "OK"},
+		{status.Error(codes.NotFound, "no such user"), logharbour.Warn, logharbour.Failure, "NotFound"},
+		{status.Error(codes.Unauthenticated, "no token"), logharbour.Warn, logharbour.Failure, "Unauthenticated"},
+		{status.Error(codes.Internal, "database down"), logharbour.Err, logharbour.Failure, "Internal"},
+		{errors.New("not a status"), logharbour.Err, logharbour.Failure, "Unknown"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger := logharbour.NewLogger(logharbour.NewLoggerContext(logharbour.Info), "grpc", &buf)
+		logCompletion(logger, "/users.Users/Get", time.Now(), tt.err)
+		entry := decodeEntry(t, &buf)
+		if entry.Pri != tt.pri || entry.Status != tt.status {
+			t.Errorf("%v: expected %v with status %v. Got: %s", tt.err, tt.pri, tt.status, buf.String())
+		}
+		data, _ := entry.Data.(map[string]any)
+		if data["code"] != tt.code || data["method"] != "/users.Users/Get" {
+			t.Errorf("%v: unexpected data: %v", tt.err, entry.Data)
+		}
+		if tt.err != nil && entry.Error != tt.err.Error() {
+			t.Errorf("%v: expected the error to be logged. Got: %q", tt.err, entry.Error)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	base := logharbour.NewLogger(logharbour.NewLoggerContext(logharbour.Info), "grpc", &buf)
+	interceptor := UnaryServerInterceptor(base)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-7"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 52000}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/Get"}
+	var handlerLogger *logharbour.Logger
+	handler := func(ctx context.Context, req any) (any, error) {
+		handlerLogger, _ = logharbour.FromContext(ctx)
+		return "response", status.Error(codes.PermissionDenied, "not allowed")
+	}
+
+	resp, err := interceptor(ctx, "request", info, handler)
+	if resp != "response" || status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected the handler's response and error. Got: %v, %v", resp, err)
+	}
+	if handlerLogger == nil {
+		t.Fatalf("Expected the handler to find the request Logger in its context")
+	}
+	entry := decodeEntry(t, &buf)
+	if entry.Op != "/users.Users/Get" || entry.RemoteIP != "10.0.0.5" || entry.TraceID != "req-7" {
+		t.Errorf("Unexpected request fields: %s", buf.String())
+	}
+	if entry.Pri != logharbour.Warn || entry.Status != logharbour.Failure {
+		t.Errorf("Expected a client error to be logged at Warn. Got: %s", buf.String())
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	base := logharbour.NewLogger(logharbour.NewLoggerContext(logharbour.Info), "grpc", &buf)
+	interceptor := StreamServerInterceptor(base)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-b3-traceid", "b3-trace"))
+	info := &grpc.StreamServerInfo{FullMethod: "/users.Users/Watch", IsServerStream: true}
+	handler := func(srv any, ss grpc.ServerStream) error {
+		if _, ok := logharbour.FromContext(ss.Context()); !ok {
+			t.Errorf("Expected the stream context to carry the request Logger")
+		}
+		return nil
+	}
+
+	if err := interceptor(nil, &fakeServerStream{ctx: ctx}, info, handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry := decodeEntry(t, &buf)
+	if entry.Op != "/users.Users/Watch" || entry.TraceID != "b3-trace" || entry.RemoteIP != "" {
+		t.Errorf("Unexpected request fields: %s", buf.String())
+	}
+	if entry.Pri != logharbour.Info || entry.Status != logharbour.Success {
+		t.Errorf("Expected a successful stream to be logged at Info. Got: %s", buf.String())
+	}
+}
+
+func decodeEntry(t *testing.T, buf *bytes.Buffer) logharbour.LogEntry {
+	t.Helper()
+	var entry logharbour.LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error decoding %q: %v", buf.String(), err)
+	}
+	return entry
+}
@@ -0,0 +1,40 @@
+package logharbour
+
+import (
+	"sync"
+	"time"
+)
+
+// StartHeartbeat starts a goroutine that logs message as an Info activity entry every
+// interval, until the returned stop function is called. Entries carry the Logger's
+// context, such as its module and op, so each worker can identify itself.
+//
+// It is safe to start one heartbeat per worker. The stop function waits for the
+// goroutine to exit and may be called more than once.
+func (l *Logger) StartHeartbeat(interval time.Duration, message string) (stop func()) {
+	logger := l.Info()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				logger.LogActivity(message, nil)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
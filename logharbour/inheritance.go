@@ -0,0 +1,24 @@
+package logharbour
+
+// WithInheritanceTracking returns a new Logger that, passed to Middleware, makes it warn
+// when a handler creates a fresh Logger with NewLogger or NewLoggerWithFallback instead
+// of deriving one from the request Logger returned by FromContext. Such a fresh Logger
+// loses the request's trace ID, remote IP and other fields, which is easy to miss in review.
+//
+// Tracking only works in binaries built with the logharbourdebug build tag:
+//
+//	go test -tags logharbourdebug ./...
+//
+// Without the tag, it does nothing and costs nothing, so it can be left enabled in code
+// that is also built for production. With the tag, Middleware records the goroutine
+// handling each request, and NewLogger writes a warning naming the request ID to the
+// last resort writer when it is called on that goroutine. Loggers created in other
+// goroutines started by the handler are not detected.
+func (l *Logger) WithInheritanceTracking(enable bool) *Logger {
+	newLogger := l.clone()
+	newLogger.trackInheritance = enable
+	if newLogger.tracing {
+		newLogger.traceMutation("WithInheritanceTracking", enable)
+	}
+	return newLogger
+}
@@ -0,0 +1,55 @@
+//go:build logharbourdebug
+
+package logharbour
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// inheritanceTrackingBuild is true in builds where WithInheritanceTracking is effective.
+const inheritanceTrackingBuild = true
+
+var (
+	requestScopes      sync.Map // goroutine ID -> request ID
+	requestScopesCount int32
+)
+
+// enterRequestScope records that the current goroutine handles the request with the given
+// ID, and returns a function that forgets it.
+func enterRequestScope(requestID string) (leave func()) {
+	id := goroutineID()
+	requestScopes.Store(id, requestID)
+	atomic.AddInt32(&requestScopesCount, 1)
+	return func() {
+		requestScopes.Delete(id)
+		atomic.AddInt32(&requestScopesCount, -1)
+	}
+}
+
+// checkInheritance warns if the current goroutine handles a tracked request.
+func checkInheritance() {
+	if atomic.LoadInt32(&requestScopesCount) == 0 {
+		return
+	}
+	if requestID, ok := requestScopes.Load(goroutineID()); ok {
+		writeLastResort(fmt.Errorf("new Logger created while handling request %s: derive it from logharbour.FromContext(r.Context()) to keep the request context", requestID), LogEntry{})
+	}
+}
+
+// goroutineID returns the ID of the current goroutine, parsed from its stack header,
+// "goroutine 123 [running]:". It is slow and meant for debug builds only.
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
@@ -0,0 +1,14 @@
+//go:build !logharbourdebug
+
+package logharbour
+
+// inheritanceTrackingBuild is true in builds where WithInheritanceTracking is effective.
+const inheritanceTrackingBuild = false
+
+// enterRequestScope does nothing without the logharbourdebug build tag.
+func enterRequestScope(requestID string) (leave func()) {
+	return func() {}
+}
+
+// checkInheritance does nothing without the logharbourdebug build tag.
+func checkInheritance() {}
@@ -0,0 +1,68 @@
+package logharbour
+
+import (
+	"fmt"
+	"time"
+)
+
+// CallInfo is the Data of the completion entry logged by Instrument and InstrumentR.
+type CallInfo struct {
+	DurationMs int64 `json:"duration_ms"`        // How long the call took, in milliseconds.
+	Panicked   bool  `json:"panicked,omitempty"` // True if the call panicked.
+}
+
+// Instrument calls fn, logging an activity entry "<op> started" before the call and a
+// completion entry, as LogResult does, after it: "<op> completed" with status Success, or
+// "<op> failed" with status Failure, the error and priority Err. The completion entry has
+// a CallInfo with the duration of the call as its data. Both entries carry op. The error
+// of fn is returned as is.
+//
+//	err := logharbour.Instrument(logger, "sync inventory", func() error {
+//		return inventory.Sync(ctx)
+//	})
+//
+// If fn panics, the completion entry is logged with status Failure, the panic value as
+// its error and Panicked set in its data, and the panic is raised again.
+func Instrument(logger *Logger, op string, fn func() error) error {
+	_, err := InstrumentR(logger, op, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// InstrumentR is like Instrument for functions that return a value, which it returns along
+// with the error.
+//
+//	order, err := logharbour.InstrumentR(logger, "load order", func() (*Order, error) {
+//		return store.LoadOrder(ctx, id)
+//	})
+func InstrumentR[T any](logger *Logger, op string, fn func() (T, error)) (result T, err error) {
+	logger = logger.WithOp(op)
+	logger.LogActivity(op+" started", nil)
+	start := time.Now()
+
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		r := recover()
+		if r == nil {
+			return // runtime.Goexit, as called by t.FailNow.
+		}
+		logger.LogResult(op+" failed", fmt.Errorf("panic: %v", r), CallInfo{
+			DurationMs: time.Since(start).Milliseconds(),
+			Panicked:   true,
+		})
+		panic(r)
+	}()
+	result, err = fn()
+	completed = true
+
+	message := op + " completed"
+	if err != nil {
+		message = op + " failed"
+	}
+	logger.LogResult(message, err, CallInfo{DurationMs: time.Since(start).Milliseconds()})
+	return result, err
+}
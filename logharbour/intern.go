@@ -0,0 +1,73 @@
+package logharbour
+
+import "sync"
+
+// defaultInternerSize is the default maximum number of distinct values kept by an Interner.
+const defaultInternerSize = 10000
+
+// Interner makes identical strings share their backing storage. A Logger with an Interner
+// (see WithInterner) interns the values of its who, module, op, class, actorType,
+// subjectType and remoteIP fields, which repeat across the many Loggers derived for
+// requests or jobs, so that memory-constrained services keep a single copy of each value
+// instead of one per Logger, for instance when op is built as r.Method+" "+r.URL.Path.
+//
+// An Interner keeps up to a fixed number of distinct values; once full, further values
+// are returned as they are, which bounds its memory if a field turns out to have many
+// distinct values. It is safe for concurrent use and may be shared by many Loggers.
+// Interning costs a map lookup under a lock per value set. It saves the memory held by
+// the duplicate values of retained Loggers, not allocations: a value built by the caller
+// is still allocated, but only the interned copy is kept. BenchmarkInterner reports the
+// memory retained per Logger with and without interning.
+type Interner struct {
+	values  map[string]string
+	maxSize int
+	mu      sync.RWMutex
+}
+
+// NewInterner creates an Interner that keeps up to maxSize distinct values, or 10000 if
+// maxSize is not positive.
+func NewInterner(maxSize int) *Interner {
+	if maxSize <= 0 {
+		maxSize = defaultInternerSize
+	}
+	return &Interner{values: make(map[string]string), maxSize: maxSize}
+}
+
+// Intern returns the stored string equal to s, storing s first if there is room.
+func (in *Interner) Intern(s string) string {
+	in.mu.RLock()
+	v, ok := in.values[s]
+	in.mu.RUnlock()
+	if ok {
+		return v
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if v, ok := in.values[s]; ok {
+		return v
+	}
+	if len(in.values) >= in.maxSize {
+		return s
+	}
+	in.values[s] = s
+	return s
+}
+
+// WithInterner returns a new Logger that interns the field values it is given with in,
+// as do the Loggers derived from it. Pass nil to stop interning.
+func (l *Logger) WithInterner(in *Interner) *Logger {
+	newLogger := l.clone()
+	newLogger.interner = in
+	if newLogger.tracing {
+		newLogger.traceMutation("WithInterner", in)
+	}
+	return newLogger
+}
+
+// intern returns s interned with the Logger's Interner, if any.
+func (l *Logger) intern(s string) string {
+	if l.interner == nil || s == "" {
+		return s
+	}
+	return l.interner.Intern(s)
+}
@@ -0,0 +1,28 @@
+package logharbour
+
+// JobInfo is the Data of an entry logged by LogJob. It describes a background job and the
+// state of its queue when the entry was logged, to correlate job latency with backlog.
+// Fields left at their zero value are omitted. QueueDepth and InFlight are pointers, so
+// that a depth of zero, an empty queue, is written and a missing one means not reported.
+type JobInfo struct {
+	Queue      string `json:"queue,omitempty"`       // Name of the queue the job was taken from, e.g. "emails".
+	JobID      string `json:"job_id,omitempty"`      // ID of the job.
+	Attempt    int    `json:"attempt,omitempty"`     // Number of the attempt, starting at 1.
+	QueueDepth *int   `json:"queue_depth,omitempty"` // Number of jobs waiting in the queue, if reported.
+	InFlight   *int   `json:"in_flight,omitempty"`   // Number of jobs being processed by the workers, if reported.
+}
+
+// LogJob logs an activity entry about a background job, with a JobInfo as its data and
+// the given status, so that all workers log jobs in the same shape for a shared
+// dashboard:
+//
+//	"data": {"attempt": 2, "in_flight": 8, "job_id": "j-981", "queue": "emails", "queue_depth": 340}
+//
+// The entry is logged at the Logger's priority whatever the status; raise it, as with
+// logger.Err(), for failed jobs that need attention.
+func (l *Logger) LogJob(message string, info JobInfo, status Status) {
+	entry := l.newLogEntry(message, info)
+	entry.Type = Activity
+	entry.Status = status
+	l.log(entry)
+}
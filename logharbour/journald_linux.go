@@ -0,0 +1,167 @@
+//go:build linux
+
+package logharbour
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// journalSocket is the path of the systemd journal's native protocol socket.
+const journalSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter is an io.Writer that sends log entries to the systemd journal using its
+// native protocol, so that entry fields become structured journal fields.
+//
+// Every top-level field of the entry is sent as a journal field named after its JSON name
+// in upper case, e.g. "who" becomes WHO and "remote_ip" becomes REMOTE_IP. String values
+// are sent as is and other values, such as Data, as JSON. In addition:
+//   - MESSAGE is set to the entry message,
+//   - PRIORITY is set from the entry priority: Debug2-Debug0 map to 7 (debug), Info to 6,
+//     Warn to 4, Err to 3, Crit to 2 and Sec to 1 (alert),
+//   - SYSLOG_IDENTIFIER is set to the application name.
+//
+// Entries too large for a datagram are passed to the journal in a sealed memory file, as
+// journald expects. If the journal socket is not available, or an entry cannot be sent,
+// for instance because it is not a JSON object, the entry is written to the fallback
+// writer instead.
+type JournaldWriter struct {
+	conn     *net.UnixConn
+	addr     *net.UnixAddr
+	fallback io.Writer
+	closed   atomic.Bool
+	mu       sync.Mutex
+}
+
+// NewJournaldWriter creates a JournaldWriter. If fallback is nil, os.Stderr is used.
+// It does not fail if the journal is unavailable; entries then go to the fallback writer.
+func NewJournaldWriter(fallback io.Writer) *JournaldWriter {
+	if fallback == nil {
+		fallback = os.Stderr
+	}
+	jw := &JournaldWriter{
+		addr:     &net.UnixAddr{Name: journalSocket, Net: "unixgram"},
+		fallback: fallback,
+	}
+	if conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"}); err == nil {
+		jw.conn = conn
+	}
+	return jw
+}
+
+// Write sends a serialized log entry to the journal. It implements io.Writer.
+func (jw *JournaldWriter) Write(p []byte) (n int, err error) {
+	if jw.conn == nil {
+		return jw.fallback.Write(p)
+	}
+
+	msg, err := journalMessage(p)
+	if err == nil {
+		err = jw.send(msg)
+	}
+	if err != nil {
+		return jw.fallback.Write(p)
+	}
+	return len(p), nil
+}
+
+// journalMessage converts a serialized log entry to a message in the journal native format.
+func journalMessage(p []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err == nil {
+		appendJournalField(&buf, "MESSAGE", entry.Msg)
+		appendJournalField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(entry.Pri)))
+		appendJournalField(&buf, "SYSLOG_IDENTIFIER", entry.App)
+	}
+	for name, raw := range fields {
+		value := sdValue(raw)
+		if value == "" {
+			continue
+		}
+		appendJournalField(&buf, journalFieldName(name), value)
+	}
+	return buf.Bytes(), nil
+}
+
+// send sends msg to the journal, in a memory file if it is too large for a datagram.
+func (jw *JournaldWriter) send(msg []byte) error {
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	_, _, err := jw.conn.WriteMsgUnix(msg, nil, jw.addr)
+	if errors.Is(err, unix.EMSGSIZE) || errors.Is(err, unix.ENOBUFS) {
+		err = jw.sendFile(msg)
+	}
+	return err
+}
+
+// sendFile writes msg to a sealed memfd and passes its file descriptor to the journal.
+func (jw *JournaldWriter) sendFile(msg []byte) error {
+	fd, err := unix.MemfdCreate("logharbour-journal", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return err
+	}
+	file := os.NewFile(uintptr(fd), "logharbour-journal")
+	defer file.Close()
+	if _, err := file.Write(msg); err != nil {
+		return err
+	}
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, seals); err != nil {
+		return err
+	}
+	_, _, err = jw.conn.WriteMsgUnix(nil, unix.UnixRights(fd), jw.addr)
+	return err
+}
+
+// Close closes the connection to the journal. Closing the writer again does nothing.
+func (jw *JournaldWriter) Close() error {
+	if jw.conn == nil || !jw.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	return jw.conn.Close()
+}
+
+// appendJournalField appends a field in the journal native format. Values containing
+// a newline are written in the binary form: name, newline, 64-bit little-endian length, value.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts a JSON field name to a valid journal field name:
+// upper case letters, digits and underscores, not starting with an underscore.
+func journalFieldName(name string) string {
+	b := []byte(strings.ToUpper(name))
+	for i, c := range b {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	return strings.TrimLeft(string(b), "_")
+}
@@ -0,0 +1,99 @@
+//go:build linux
+
+package logharbour
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// newTestJournal listens on a unixgram socket standing for the journal and returns a
+// JournaldWriter that sends to it.
+func newTestJournal(t *testing.T, fallback io.Writer) (*JournaldWriter, *net.UnixConn) {
+	t.Helper()
+	addr := &net.UnixAddr{Name: t.TempDir() + "/journal", Net: "unixgram"}
+	journal, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	jw := NewJournaldWriter(fallback)
+	t.Cleanup(func() { jw.Close() })
+	jw.addr = addr
+	return jw, journal
+}
+
+func TestJournaldWriter(t *testing.T) {
+	var fallback strings.Builder
+	jw, journal := newTestJournal(t, &fallback)
+	logger := NewLogger(NewLoggerContext(Info), "testApp", jw).WithWho("john")
+	logger.LogActivity("small entry", nil)
+
+	buf := make([]byte, 65536)
+	n, err := journal.Read(buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, field := range []string{"MESSAGE=small entry\n", "PRIORITY=6\n", "SYSLOG_IDENTIFIER=testApp\n", "WHO=john\n"} {
+		if !strings.Contains(string(buf[:n]), field) {
+			t.Errorf("Expected %q in the journal message. Got: %q", field, buf[:n])
+		}
+	}
+
+	// An entry too large for a datagram is passed in a memory file.
+	large := strings.Repeat("x", 1<<20)
+	logger.LogActivity(large, nil)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := journal.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("Expected an empty datagram with a file descriptor. Got %d bytes", n)
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Expected one control message. Got: %v, %v", msgs, err)
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("Expected one file descriptor. Got: %v, %v", fds, err)
+	}
+	file := os.NewFile(uintptr(fds[0]), "journal")
+	defer file.Close()
+	content, err := io.ReadAll(io.NewSectionReader(file, 0, 1<<30))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(content), "MESSAGE="+large+"\n") {
+		t.Errorf("Expected the large message in the memory file")
+	}
+	if fallback.Len() != 0 {
+		t.Errorf("Expected nothing in the fallback writer. Got %d bytes", fallback.Len())
+	}
+}
+
+func TestJournaldWriterFallback(t *testing.T) {
+	var fallback strings.Builder
+	jw, journal := newTestJournal(t, &fallback)
+	journal.Close()
+	logger := NewLogger(NewLoggerContext(Info), "testApp", jw)
+	logger.LogActivity("no journal", nil)
+
+	if !strings.Contains(fallback.String(), "no journal") {
+		t.Errorf("Expected entry in the fallback writer when the journal is unavailable, got %q", fallback.String())
+	}
+
+	fallback.Reset()
+	if _, err := jw.Write([]byte("not JSON\n")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if fallback.String() != "not JSON\n" {
+		t.Errorf("Expected a payload that is not an entry in the fallback writer, got %q", fallback.String())
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+
+package logharbour
+
+import (
+	"io"
+	"os"
+)
+
+// JournaldWriter writes to the systemd journal on Linux. On other platforms there is
+// no journal, so all entries are written to the fallback writer.
+type JournaldWriter struct {
+	fallback io.Writer
+}
+
+// NewJournaldWriter creates a JournaldWriter. If fallback is nil, os.Stderr is used.
+func NewJournaldWriter(fallback io.Writer) *JournaldWriter {
+	if fallback == nil {
+		fallback = os.Stderr
+	}
+	return &JournaldWriter{fallback: fallback}
+}
+
+// Write writes the entry to the fallback writer. It implements io.Writer.
+func (jw *JournaldWriter) Write(p []byte) (n int, err error) {
+	return jw.fallback.Write(p)
+}
+
+// Close does nothing on platforms without a journal.
+func (jw *JournaldWriter) Close() error {
+	return nil
+}
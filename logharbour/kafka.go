@@ -69,6 +69,9 @@ type kafkaWriter struct {
 // Write sends a message to a Kafka topic. It implements io.Writer.
 // It works with kafkaConnectionPool.
 // It retrieves a connection from the pool and releases it back to the pool after use.
+// If the log entry has a dedup key, it is used as the message key. Entries with the
+// same key therefore go to the same partition, and consumers can use the key to
+// ignore duplicates.
 func (kw *kafkaWriter) Write(p []byte) (n int, err error) {
 	producer := kw.pool.getConnection()
 	defer kw.pool.releaseConnection(producer)
@@ -77,6 +80,9 @@ func (kw *kafkaWriter) Write(p []byte) (n int, err error) {
 		Topic: kw.topic,
 		Value: sarama.ByteEncoder(p),
 	}
+	if key := entryDedupKey(p); key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
 
 	_, _, err = producer.SendMessage(msg)
 	if err != nil {
@@ -0,0 +1,40 @@
+package logharbour
+
+import "fmt"
+
+// badKey is the key of the LogKV values that have no valid key.
+const badKey = "!BADKEY"
+
+// LogKV logs an activity entry whose data is a map built from alternating keys and
+// values, for quick instrumentation without defining a data type:
+//
+//	logger.Info().LogKV("cache miss", "key", k, "ttl", ttl)
+//
+// Keys must be strings. As with log/slog, a value that is not preceded by a string key,
+// such as the last value of an odd number of arguments, is kept under the key "!BADKEY"
+// rather than dropped, so that mistakes show up in the logs. If there are several, they
+// are numbered: "!BADKEY", "!BADKEY2", and so on. A repeated key keeps its last value.
+func (l *Logger) LogKV(message string, keyvals ...any) {
+	l.LogActivity(message, kvData(keyvals))
+}
+
+// kvData builds the data map of LogKV from keyvals.
+func kvData(keyvals []any) map[string]any {
+	data := make(map[string]any, len(keyvals)/2)
+	bad := 0
+	for len(keyvals) > 0 {
+		key, ok := keyvals[0].(string)
+		if !ok || len(keyvals) == 1 {
+			if bad++; bad == 1 {
+				data[badKey] = keyvals[0]
+			} else {
+				data[fmt.Sprintf("%s%d", badKey, bad)] = keyvals[0]
+			}
+			keyvals = keyvals[1:]
+			continue
+		}
+		data[key] = keyvals[1]
+		keyvals = keyvals[2:]
+	}
+	return data
+}
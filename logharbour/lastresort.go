@@ -0,0 +1,41 @@
+package logharbour
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	lastResortWriter io.Writer // nil means os.Stderr
+	lastResortMu     sync.Mutex
+)
+
+// SetLastResortWriter sets the writer used when a log entry cannot be written anywhere else.
+//
+// When a Logger fails to write an entry, it tries the following in order:
+//  1. the Logger's writer;
+//  2. the fallback writer, if the Logger's writer is a FallbackWriter;
+//  3. the last resort writer.
+//
+// The last resort writer is the absolute last line of defense: if writing to it fails,
+// the entry is lost. It defaults to os.Stderr, which may be unavailable or discarded in
+// some sandboxed environments; in that case point it at a guaranteed-writable location
+// such as a local file. Passing nil restores the default.
+func SetLastResortWriter(w io.Writer) {
+	lastResortMu.Lock()
+	defer lastResortMu.Unlock()
+	lastResortWriter = w
+}
+
+// writeLastResort writes the error and the log entry to the last resort writer.
+func writeLastResort(err error, entry LogEntry) {
+	lastResortMu.Lock()
+	defer lastResortMu.Unlock()
+	var w io.Writer = os.Stderr
+	if lastResortWriter != nil {
+		w = lastResortWriter
+	}
+	fmt.Fprintf(w, "Error: %v, LogEntry: %+v\n", err, entry)
+}
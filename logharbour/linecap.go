@@ -0,0 +1,64 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// capLine encodes entry with marshal, shortening it to at most maxBytes bytes as
+// described for JSONEncoder.MaxLineBytes.
+func capLine(entry LogEntry, maxBytes int, marshal func(entry LogEntry) ([]byte, error)) ([]byte, error) {
+	entry.Truncated = true
+	encode := func() ([]byte, error) { return marshal(entry) }
+
+	if data, err := genericData(entry.Data); err == nil {
+		if m, ok := data.(map[string]any); ok {
+			entry.Data = m
+			// Replace the values of Data by the marker, largest first.
+			sizes := make(map[string]int, len(m))
+			keys := make([]string, 0, len(m))
+			for k, v := range m {
+				b, _ := json.Marshal(v)
+				sizes[k] = len(b)
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool {
+				if sizes[keys[i]] != sizes[keys[j]] {
+					return sizes[keys[i]] > sizes[keys[j]]
+				}
+				return keys[i] < keys[j]
+			})
+			for _, k := range keys {
+				m[k] = TruncatedSuffix
+				encoded, err := encode()
+				if err != nil {
+					return nil, err
+				}
+				if len(encoded) <= maxBytes {
+					return encoded, nil
+				}
+			}
+		}
+	}
+
+	entry.Data = TruncatedSuffix
+	encoded, err := encode()
+	if err != nil || len(encoded) <= maxBytes {
+		return encoded, err
+	}
+
+	// Shorten Msg by the excess. Escaping may make the encoded message longer than the
+	// message itself, so repeat until it fits or the message is gone.
+	for len(encoded) > maxBytes && entry.Msg != "" {
+		n := len(entry.Msg) - (len(encoded) - maxBytes) - len(TruncatedSuffix)
+		if n <= 0 {
+			entry.Msg = ""
+		} else {
+			entry.Msg = truncateString(entry.Msg, n)
+		}
+		if encoded, err = encode(); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
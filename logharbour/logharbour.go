@@ -1,11 +1,12 @@
 package logharbour
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,9 +22,16 @@ const DefaultPriority = Info
 // The mu Mutex ensures that all operations on the minLogPriority field are mutually exclusive,
 // regardless of which goroutine they are performed in.
 type LoggerContext struct {
-	minLogPriority LogPriority
-	debugMode      int32 // int32 to represent the boolean flag atomically
-	mu             sync.Mutex
+	minLogPriority  LogPriority
+	debugMode       int32 // int32 to represent the boolean flag atomically
+	propagatePanics int32 // int32 to represent the boolean flag atomically
+	collector       *ValidationCollector
+	allowedEnvs     map[string]bool
+	sequencing      int32         // int32 to represent the boolean flag atomically
+	seq             atomic.Uint64 // Last sequence number assigned to an entry
+	seqSource       string        // Random ID of the sequence, set once sequencing is first used
+	seqSourceOnce   sync.Once
+	mu              sync.Mutex
 }
 
 // NewLoggerContext creates a new LoggerContext with the specified minimum log priority.
@@ -49,95 +57,208 @@ func NewLoggerContext(minLogPriority LogPriority) *LoggerContext {
 // then set the desired field to the new value, and finally return the new Logger.
 // This approach provides a flexible way to create a new Logger with specific settings,
 // without having to provide all settings at once or change the settings of an existing Logger.
+//
+// Calling a 'With' method more than once follows two rules. Methods that set a single
+// value, such as WithModule or WithWho, overwrite the previous value. Methods that add to
+// a collection, such as WithMaxFieldBytes, accumulate: each call adds to what earlier
+// calls set. Accumulated collections can be dropped on a clone with the matching 'Clear'
+// method, such as ClearFieldLimits, ClearRequiredFields, ClearAttachments, ClearDebugData
+// or ClearBaggage.
 type Logger struct {
-	context    *LoggerContext      // Context for the logger. It is shared by all clones of the logger.
-	app        string              // Name of the application.
-	system     string              // System where the application is running.
-	module     string              // Module or subsystem within the application.
-	pri        LogPriority         // Priority level of the log messages.
-	who        string              // User or service performing the operation.
-	op         string              // Operation being performed.
-	class      string              // Class of the object instance involved.
-	instanceId string              // Unique ID of the object instance.
-	status     Status              // Status of the operation.
-	err        string              // Error associated with the operation.
-	remoteIP   string              // IP address of the remote endpoint.
-	writer     io.Writer           // Writer interface for log entries.
-	validator  *validator.Validate // Validator for log entries.
-	mu         sync.Mutex          // Mutex for thread-safe operations.
+	context          *LoggerContext          // Context for the logger. It is shared by all clones of the logger.
+	app              string                  // Name of the application.
+	system           string                  // System where the application is running.
+	env              string                  // Deployment environment, such as dev, staging or prod.
+	tenant           string                  // Tenant entries belong to in a multi-tenant system.
+	module           string                  // Module or subsystem within the application.
+	pri              LogPriority             // Priority level of the log messages.
+	who              string                  // User or service performing the operation.
+	actorType        string                  // Kind of actor performing the operation.
+	op               string                  // Operation being performed.
+	class            string                  // Class of the object instance involved.
+	instanceId       string                  // Unique ID of the object instance.
+	subjectType      string                  // Kind of subject the operation is performed on.
+	status           Status                  // Status of the operation.
+	err              string                  // Error associated with the operation.
+	remoteIP         string                  // IP address of the remote endpoint.
+	traceID          string                  // ID of the distributed trace.
+	spanID           string                  // ID of the span entries are logged in, if any.
+	parentSpanID     string                  // ID of the parent of that span, if any.
+	causedBy         string                  // ID of the entry that caused the entries logged, if any.
+	dedupKey         string                  // Deduplication key for idempotent delivery.
+	alert            bool                    // If true, entries are flagged for paging regardless of priority.
+	when             time.Time               // Explicit event time; zero means use the current time.
+	location         *time.Location          // Time zone used for When; nil means UTC.
+	deadline         time.Time               // Deadline of the bound request context; zero means none.
+	uptime           bool                    // Whether entries record the process uptime.
+	libVersion       bool                    // Whether entries record the logharbour version.
+	summarizer       *Summarizer             // Counts the entries for periodic summaries, if set.
+	summarizeOnly    bool                    // Whether entries are only counted by the summarizer, not written.
+	logType          LogType                 // Type of all entries, set by WithType; zero keeps the type of each method.
+	dryRun           bool                    // If true, entries are validated but not written.
+	trackInheritance bool                    // If true, request scopes entered by Middleware are tracked in debug builds.
+	tracing          bool                    // If true, 'With' calls are recorded in mutations.
+	mutations        []FieldMutation         // 'With' calls recorded while tracing.
+	escalation       *escalationPolicy       // Policy for escalating repeated entries, shared by clones.
+	stats            *logStats               // Counters of logged entries, shared by clones.
+	pause            *pauseState             // Pause state shared by all clones.
+	newlineMode      NewlineMode             // How newlines in string fields are handled.
+	fieldLimits      map[string]int          // Maximum sizes in bytes of individual fields, by JSON name.
+	requiredFields   map[LogType][]string    // JSON names of the fields entries of each type must have.
+	rawData          bool                    // If true, Data is not re-encoded with sorted object keys.
+	redactor         *redaction              // Redactor applied to entries, with its predicate.
+	heuristic        *redaction              // Heuristic key redaction, applied after the redactor.
+	validationPolicy ValidationPolicy        // Soft validation rules per field; fields without a rule fail hard.
+	redactQueryArgs  bool                    // If true, LogQuery replaces query arguments with RedactedArg.
+	errorPriority    func(error) LogPriority // Chooses the priority of failed results; nil means Err.
+	exemplar         *MetricExemplar         // Metric exemplar recorded on entries.
+	attachments      []Attachment            // References to artifacts stored elsewhere.
+	debugData        map[string]any          // Data merged into entries logged at a debug priority.
+	baggage          map[string]string       // Propagated baggage merged into the Data of entries.
+	maxStackDepth    int                     // Maximum number of stack frames in debug entries; zero means no limit.
+	sampler          *TraceSampler           // Sampler deciding which traces are logged.
+	minPriority      LogPriority             // Per-logger minimum priority overriding the context's; zero means not set.
+	schedule         *schedule               // Time-based minimum priority rules.
+	interner         *Interner               // Interns repeated field values; nil means no interning.
+	writer           io.Writer               // Writer interface for log entries.
+	validator        *validator.Validate     // Validator for log entries.
+	mu               sync.Mutex              // Mutex for thread-safe operations.
 }
 
 // clone creates and returns a new Logger with the same values as the original.
 func (l *Logger) clone() *Logger {
 	return &Logger{
-		context:    l.context,
-		app:        l.app,
-		system:     l.system,
-		module:     l.module,
-		pri:        l.pri,
-		who:        l.who,
-		op:         l.op,
-		class:      l.class,
-		instanceId: l.instanceId,
-		status:     l.status,
-		err:        l.err,
-		remoteIP:   l.remoteIP,
-		writer:     l.writer,
-		validator:  l.validator,
+		context:          l.context,
+		app:              l.app,
+		system:           l.system,
+		env:              l.env,
+		tenant:           l.tenant,
+		module:           l.module,
+		pri:              l.pri,
+		who:              l.who,
+		actorType:        l.actorType,
+		op:               l.op,
+		class:            l.class,
+		instanceId:       l.instanceId,
+		subjectType:      l.subjectType,
+		status:           l.status,
+		err:              l.err,
+		remoteIP:         l.remoteIP,
+		traceID:          l.traceID,
+		spanID:           l.spanID,
+		parentSpanID:     l.parentSpanID,
+		causedBy:         l.causedBy,
+		dedupKey:         l.dedupKey,
+		alert:            l.alert,
+		when:             l.when,
+		location:         l.location,
+		deadline:         l.deadline,
+		uptime:           l.uptime,
+		libVersion:       l.libVersion,
+		summarizer:       l.summarizer,
+		summarizeOnly:    l.summarizeOnly,
+		logType:          l.logType,
+		dryRun:           l.dryRun,
+		trackInheritance: l.trackInheritance,
+		tracing:          l.tracing,
+		mutations:        l.mutations,
+		escalation:       l.escalation,
+		stats:            l.stats,
+		pause:            l.pause,
+		newlineMode:      l.newlineMode,
+		fieldLimits:      l.fieldLimits,
+		requiredFields:   l.requiredFields,
+		rawData:          l.rawData,
+		redactor:         l.redactor,
+		heuristic:        l.heuristic,
+		validationPolicy: l.validationPolicy,
+		redactQueryArgs:  l.redactQueryArgs,
+		errorPriority:    l.errorPriority,
+		exemplar:         l.exemplar,
+		attachments:      l.attachments,
+		debugData:        l.debugData,
+		baggage:          l.baggage,
+		maxStackDepth:    l.maxStackDepth,
+		sampler:          l.sampler,
+		minPriority:      l.minPriority,
+		schedule:         l.schedule,
+		interner:         l.interner,
+		writer:           l.writer,
+		validator:        l.validator,
 	}
 }
 
 // NewLogger creates a new Logger with the specified application name and writer.
 // We recommend using NewLoggerWithFallback instead of this method.
 func NewLogger(context *LoggerContext, appName string, writer io.Writer) *Logger {
+	checkInheritance()
 	return &Logger{
 		context:   context,
 		app:       appName,
 		system:    getSystemName(),
+		env:       os.Getenv(EnvVar),
 		writer:    writer,
-		validator: validator.New(),
+		validator: newValidator(),
 		pri:       DefaultPriority,
+		stats:     newLogStats(),
+		pause:     &pauseState{},
 	}
 }
 
 // NewLoggerWithFallback creates a new Logger with a fallback writer.
 // The fallback writer is used if the primary writer fails or if validation of a log entry fails.
 func NewLoggerWithFallback(context *LoggerContext, appName string, fallbackWriter *FallbackWriter) *Logger {
+	checkInheritance()
 	return &Logger{
 		context:   context,
 		app:       appName,
 		system:    getSystemName(),
+		env:       os.Getenv(EnvVar),
 		writer:    fallbackWriter,
-		validator: validator.New(),
+		validator: newValidator(),
 		pri:       DefaultPriority,
+		stats:     newLogStats(),
+		pause:     &pauseState{},
 	}
 }
 
 // WithWho returns a new Logger with the 'who' field set to the specified value.
 func (l *Logger) WithWho(who string) *Logger {
-	newLogger := l.clone() // Create a copy of the logger
-	newLogger.who = who    // Change the 'who' field
-	return newLogger       // Return the new logger
+	newLogger := l.clone()        // Create a copy of the logger
+	newLogger.who = l.intern(who) // Change the 'who' field
+	if newLogger.tracing {
+		newLogger.traceMutation("WithWho", who)
+	}
+	return newLogger // Return the new logger
 }
 
 // WithModule returns a new Logger with the 'module' field set to the specified value.
 func (l *Logger) WithModule(module string) *Logger {
 	newLogger := l.clone()
-	newLogger.module = module
+	newLogger.module = l.intern(module)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithModule", module)
+	}
 	return newLogger
 }
 
 // WithOp returns a new Logger with the 'op' field set to the specified value.
 func (l *Logger) WithOp(op string) *Logger {
 	newLogger := l.clone()
-	newLogger.op = op
+	newLogger.op = l.intern(op)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithOp", op)
+	}
 	return newLogger
 }
 
 // WithClass returns a new Logger with the 'whatClass' field set to the specified value.
 func (l *Logger) WithClass(whatClass string) *Logger {
 	newLogger := l.clone()
-	newLogger.class = whatClass
+	newLogger.class = l.intern(whatClass)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithClass", whatClass)
+	}
 	return newLogger
 }
 
@@ -145,6 +266,75 @@ func (l *Logger) WithClass(whatClass string) *Logger {
 func (l *Logger) WithInstanceId(whatInstanceId string) *Logger {
 	newLogger := l.clone()
 	newLogger.instanceId = whatInstanceId
+	if newLogger.tracing {
+		newLogger.traceMutation("WithInstanceId", whatInstanceId)
+	}
+	return newLogger
+}
+
+// WithActorType returns a new Logger with the 'actorType' field set to the specified value.
+// It describes the kind of actor recorded in 'who', such as "admin" or "user".
+func (l *Logger) WithActorType(actorType string) *Logger {
+	newLogger := l.clone()
+	newLogger.actorType = l.intern(actorType)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithActorType", actorType)
+	}
+	return newLogger
+}
+
+// WithSubjectType returns a new Logger with the 'subjectType' field set to the specified value.
+// It describes the kind of subject recorded in 'class' and 'instanceId', such as "user".
+func (l *Logger) WithSubjectType(subjectType string) *Logger {
+	newLogger := l.clone()
+	newLogger.subjectType = l.intern(subjectType)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithSubjectType", subjectType)
+	}
+	return newLogger
+}
+
+// WithTraceID returns a new Logger with the 'traceID' field set to the specified value.
+// The trace ID links entries logged while handling the same distributed request.
+func (l *Logger) WithTraceID(traceID string) *Logger {
+	newLogger := l.clone()
+	newLogger.traceID = traceID
+	if newLogger.tracing {
+		newLogger.traceMutation("WithTraceID", traceID)
+	}
+	return newLogger
+}
+
+// WithDedupKey returns a new Logger with the 'dedupKey' field set to the specified value.
+// The key identifies an entry for idempotent delivery, so that entries re-emitted during
+// retries, e.g. "order-123-created", are not counted twice downstream. Writers use it as follows:
+//   - the Kafka writer sends it as the message key;
+//   - the log consumer (cmd/logConsumer) uses the message key as the Elasticsearch
+//     document ID, so re-delivered entries overwrite the same document;
+//   - callers of ElasticsearchClient.Write can pass it as the documentID.
+func (l *Logger) WithDedupKey(dedupKey string) *Logger {
+	newLogger := l.clone()
+	newLogger.dedupKey = dedupKey
+	if newLogger.tracing {
+		newLogger.traceMutation("WithDedupKey", dedupKey)
+	}
+	return newLogger
+}
+
+// WithExemplar returns a new Logger that records a reference to a metric exemplar on
+// its entries, linking them to the sample of metric taken for traceID. The reference is
+// written as the "exemplar" field and is omitted unless set.
+// Usually traceID is the same as the trace ID set with WithTraceID.
+// Pass an empty metric to remove the reference.
+func (l *Logger) WithExemplar(metric, traceID string, labels map[string]string) *Logger {
+	newLogger := l.clone()
+	newLogger.exemplar = nil
+	if metric != "" {
+		newLogger.exemplar = &MetricExemplar{Metric: metric, TraceID: traceID, Labels: labels}
+	}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithExemplar", metric)
+	}
 	return newLogger
 }
 
@@ -152,6 +342,9 @@ func (l *Logger) WithInstanceId(whatInstanceId string) *Logger {
 func (l *Logger) WithStatus(status Status) *Logger {
 	newLogger := l.clone()
 	newLogger.status = status
+	if newLogger.tracing {
+		newLogger.traceMutation("WithStatus", status)
+	}
 	return newLogger
 }
 
@@ -159,6 +352,9 @@ func (l *Logger) WithStatus(status Status) *Logger {
 func (l *Logger) Error(err error) *Logger {
 	newLogger := l.clone()
 	newLogger.err = err.Error()
+	if newLogger.tracing {
+		newLogger.traceMutation("Error", newLogger.err)
+	}
 	return newLogger
 }
 
@@ -178,13 +374,60 @@ func (l *Logger) Error(err error) *Logger {
 func (l *Logger) WithPriority(priority LogPriority) *Logger {
 	newLogger := l.clone()
 	newLogger.pri = priority
+	if newLogger.tracing {
+		newLogger.traceMutation("WithPriority", priority)
+	}
 	return newLogger
 }
 
 // WithRemoteIP returns a new Logger with the 'remoteIP' field set to the specified value.
 func (l *Logger) WithRemoteIP(remoteIP string) *Logger {
 	newLogger := l.clone()
-	newLogger.remoteIP = remoteIP
+	newLogger.remoteIP = l.intern(remoteIP)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithRemoteIP", remoteIP)
+	}
+	return newLogger
+}
+
+// WithWhen returns a new Logger that stamps log entries with the specified time
+// instead of the current time. This is useful when replaying historical events or
+// ingesting entries from another system that records the event time separately.
+// Passing the zero time restores the default behaviour of using the current time.
+func (l *Logger) WithWhen(when time.Time) *Logger {
+	newLogger := l.clone()
+	newLogger.when = when
+	if newLogger.tracing {
+		newLogger.traceMutation("WithWhen", when)
+	}
+	return newLogger
+}
+
+// WithTimeZone returns a new Logger that records the time of its entries in loc.
+// The serialized time includes the zone's offset from UTC, e.g. 2024-01-02T15:04:05+05:30,
+// so it stays unambiguous, and readers handle both forms. A nil loc means UTC, which is
+// the default and remains recommended: entries from different hosts then sort and compare
+// as written. Local time is meant for setups where logs must match other local-time logs
+// on the same host; use time.Local for the host's time zone.
+func (l *Logger) WithTimeZone(loc *time.Location) *Logger {
+	newLogger := l.clone()
+	newLogger.location = loc
+	if newLogger.tracing {
+		newLogger.traceMutation("WithTimeZone", loc)
+	}
+	return newLogger
+}
+
+// WithDryRun returns a new Logger that validates log entries without writing them.
+// Invalid entries are still reported the usual way: they are recorded by the context's
+// ValidationCollector, if any, and written to the fallback writer or stderr.
+// This lets tests exercise code paths and assert that no invalid entry is produced.
+func (l *Logger) WithDryRun(dryRun bool) *Logger {
+	newLogger := l.clone()
+	newLogger.dryRun = dryRun
+	if newLogger.tracing {
+		newLogger.traceMutation("WithDryRun", dryRun)
+	}
 	return newLogger
 }
 
@@ -192,69 +435,231 @@ func (l *Logger) WithRemoteIP(remoteIP string) *Logger {
 // If there's a problem with writing the log entry or if the log entry is invalid,
 // it attempts to write the error and the log entry to the fallback writer (if available).
 // If writing to the fallback writer fails or if the fallback writer is not available,
-// it writes the error and the log entry to stderr.
+// it writes the error and the log entry to the last resort writer (stderr by default).
 func (l *Logger) log(entry LogEntry) {
+	l.logAck(entry, nil)
+}
+
+// logAck writes a log entry as log does and, if ack is not nil, calls it once the entry
+// is written, with the outcome; see LogActivityAck.
+func (l *Logger) logAck(entry LogEntry, ack func(error)) {
+	if ack != nil {
+		// ack may be called from a writer's goroutine, where a panic would crash the
+		// process. The report leaves out Data, which is not redacted yet.
+		userAck, report := ack, entry
+		report.Data = nil
+		ack = func(err error) { l.callSafely("acknowledgment callback", report, func() { userAck(err) }) }
+	}
+	ackErr := ErrNotWritten
+	var aw ackWriter // set if the entry is to be written by an ackWriter
+	defer func() {
+		// Called after the mutex is released, so that ack may log, also when an ackWriter
+		// calls it before writeEntryAck returns.
+		switch {
+		case aw != nil:
+			aw.writeEntryAck(entry, ack)
+		case ack != nil:
+			ack(ackErr)
+		}
+	}()
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	entry.App = l.app
+	if l.logType != 0 {
+		entry.Type = l.logType
+	}
+	if l.escalation != nil {
+		// The report leaves out Data, which is not resolved or redacted yet.
+		report := entry
+		report.Data = nil
+		l.callSafely("escalation predicate", report, func() { l.escalation.apply(&entry) })
+	}
 	if !l.shouldLog(entry.Pri) {
 		return
 	}
-	if err := l.validator.Struct(entry); err != nil {
+	if l.summarizer != nil {
+		l.summarizer.add(entry)
+		if l.summarizeOnly {
+			return
+		}
+	}
+	if l.sampler != nil && !l.sampler.Keep(entry) {
+		return
+	}
+	// A panicking LogValue drops the Data, which is also left out of the panic report.
+	withoutData := entry
+	withoutData.Data = nil
+	if !l.callSafely("LogValue", withoutData, func() { entry.Data, _ = resolveLogValues(entry.Data, 0) }) {
+		entry.Data = nil
+	}
+	if l.debugData != nil && entry.Pri <= Debug0 {
+		// Merged once Data is resolved, since struct Data is merged in its JSON form.
+		// If resolving the debug data panics, the entry is written without it.
+		merged := mergeData(entry.Data, l.debugData)
+		if l.callSafely("LogValue", withoutData, func() { merged, _ = resolveLogValues(merged, 0) }) {
+			entry.Data = merged
+		}
+	}
+	if l.redactor != nil && !l.redactor.apply(l, &entry, withoutData) {
+		return
+	}
+	if l.heuristic != nil && !l.heuristic.apply(l, &entry, withoutData) {
+		return
+	}
+	normalizeNewlines(&entry, l.newlineMode)
+	if l.fieldLimits != nil {
+		applyFieldLimits(&entry, l.fieldLimits)
+	}
+	if !l.rawData && entry.Data != nil {
+		if data, err := genericData(entry.Data); err == nil {
+			entry.Data = data
+		}
+	}
+	err := l.validator.Struct(entry)
+	if err != nil && l.fixSoftFailures(&entry, err) {
+		err = l.validator.Struct(entry)
+	}
+	if err == nil {
+		err = l.context.checkEnv(entry.Env)
+	}
+	if err == nil && l.requiredFields != nil {
+		err = checkRequiredFields(entry, l.requiredFields[entry.Type])
+	}
+	if err != nil {
+		entry.ValidationErrors = fieldValidationErrors(err)
+		ackErr = err
+		l.context.recordValidationError(entry, err)
 		// Check if the writer is a FallbackWriter
 		if fw, ok := l.writer.(*FallbackWriter); ok {
 			// Write to the fallback writer if validation fails
-			if err := formatAndWriteEntry(fw.fallback, entry); err != nil {
-				// If writing to the fallback writer fails, write to stderr
-				fmt.Fprintf(os.Stderr, "Error: %v, LogEntry: %+v\n", err, entry)
+			if err := fw.writeInvalid(entry); err != nil {
+				// If writing to the fallback writer fails, write to the last resort writer
+				writeLastResort(err, entry)
 			}
 		} else {
-			fmt.Fprintf(os.Stderr, "Error: %v, LogEntry: %+v\n", err, entry)
+			writeLastResort(err, entry)
 		}
 		return
 	}
-	if err := formatAndWriteEntry(l.writer, entry); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v, LogEntry: %+v\n", err, entry)
+	if l.dryRun {
+		return
+	}
+	if w, ok := l.writer.(ackWriter); ok && ack != nil {
+		aw = w // the writer writes the entry and calls ack once the mutex is released
+	} else if ackErr = formatAndWriteEntry(l.writer, entry); ackErr != nil {
+		writeLastResort(ackErr, entry)
+	}
+	if l.stats != nil {
+		l.stats.record(entry.Pri, time.Now())
+	}
+}
+
+// PressureReporter is implemented by writers that buffer log entries and can report
+// how full their buffer is. Pressure returns a value between 0 (empty) and 1 (full).
+// SplitWriter and CloudWatchWriter implement it, and FallbackWriter forwards it.
+type PressureReporter interface {
+	Pressure() float64
+}
+
+// Pressure returns how saturated the Logger's writer is, as a ratio between 0 and 1.
+// Applications can use it to shed load or raise the minimum log priority when logging
+// falls behind. If the writer does not implement PressureReporter, as is the case for
+// synchronous writers, Pressure returns 0.
+func (l *Logger) Pressure() float64 {
+	if pr, ok := l.writer.(PressureReporter); ok {
+		return pr.Pressure()
 	}
+	return 0
 }
 
 // shouldLog determines whether a log entry should be written based on its priority.
+// A boosted logger uses the lower of its own minimum priority and the context's.
+// A matching schedule rule takes the place of the context's minimum priority.
+// While logging is paused, entries below the pause priority are never logged.
 func (l *Logger) shouldLog(p LogPriority) bool {
+	if l.pause != nil && l.pause.suppresses(p) {
+		return false
+	}
+	if l.minPriority != 0 && p >= l.minPriority {
+		return true
+	}
+	return p >= l.baseMinPriority()
+}
+
+// baseMinPriority returns the minimum priority set by the matching schedule rule, if
+// any, or by the context.
+func (l *Logger) baseMinPriority() LogPriority {
+	if l.schedule != nil {
+		if minPriority, ok := l.schedule.minPriorityAt(time.Now()); ok {
+			return minPriority
+		}
+	}
 	l.context.mu.Lock()
 	defer l.context.mu.Unlock()
-	return p >= l.context.minLogPriority
+	return l.context.minLogPriority
 }
 
 // formatAndWriteEntry formats a log entry as JSON and writes it to the Logger's writer.
+// Writers with their own Encoder, such as an EncodedWriter or a MultiWriter, receive
+// the entry unencoded instead.
 func formatAndWriteEntry(writer io.Writer, entry LogEntry) error {
-	formattedEntry, err := json.Marshal(entry)
-	if err != nil {
-		return err
-	}
-	formattedEntry = append(formattedEntry, '\n')
-	_, writeErr := writer.Write(formattedEntry)
-	return writeErr
+	return writeEntryTo(writer, entry)
 }
 
 // newLogEntry creates a new log entry with the specified message and data.
 func (l *Logger) newLogEntry(message string, data any) LogEntry {
-	return LogEntry{
-		App:        l.app,
-		System:     l.system,
-		Module:     l.module,
-		Pri:        l.pri,
-		Who:        l.who,
-		Op:         l.op,
-		When:       time.Now().UTC(),
-		Class:      l.class,
-		InstanceId: l.instanceId,
-		Status:     l.status,
-		Error:      l.err,
-		RemoteIP:   l.remoteIP,
-		Msg:        message,
-		Data:       data,
+	when := time.Now()
+	if !l.when.IsZero() {
+		when = l.when
+	}
+	if l.location != nil {
+		when = when.In(l.location)
+	} else {
+		when = when.UTC()
+	}
+	entry := LogEntry{
+		App:          l.app,
+		System:       l.system,
+		Env:          l.env,
+		Tenant:       l.tenant,
+		Module:       l.module,
+		Pri:          l.pri,
+		Who:          l.who,
+		ActorType:    l.actorType,
+		Op:           l.op,
+		When:         when,
+		Class:        l.class,
+		InstanceId:   l.instanceId,
+		SubjectType:  l.subjectType,
+		Status:       l.status,
+		Error:        l.err,
+		RemoteIP:     l.remoteIP,
+		TraceID:      l.traceID,
+		SpanID:       l.spanID,
+		ParentSpanID: l.parentSpanID,
+		Baggage:      l.baggage,
+		CausedBy:     l.causedBy,
+		DedupKey:     l.dedupKey,
+		Alert:        l.alert,
+		Msg:          message,
+		Data:         data,
+		Exemplar:     l.exemplar,
+		Attachments:  l.attachments,
+	}
+	entry.Seq, entry.SeqSource = l.context.nextSeq()
+	if !l.deadline.IsZero() {
+		remaining := l.deadline.Sub(time.Now()).Milliseconds()
+		entry.DeadlineRemainingMs = &remaining
+	}
+	if l.uptime {
+		uptime := Uptime().Milliseconds()
+		entry.UptimeMs = &uptime
 	}
+	if l.libVersion {
+		entry.LibVersion = Version()
+	}
+	return entry
 }
 
 // LogDataChange logs a data change event.
@@ -271,9 +676,30 @@ func (l *Logger) LogActivity(message string, data ActivityInfo) {
 	l.log(entry)
 }
 
+// LogResult logs the outcome of an operation as an activity event.
+// If err is nil, the entry is logged with status Success at the Logger's priority.
+// Otherwise it is logged with status Failure, the error set, and priority Err, or the
+// priority chosen by the Logger's error priority mapper (see WithErrorPriorityMapper),
+// unless the Logger's priority is already higher, in which case that priority is kept.
+// A nil data is logged as is.
+func (l *Logger) LogResult(message string, err error, data any) {
+	entry := l.newLogEntry(message, data)
+	entry.Type = Activity
+	if err == nil {
+		entry.Status = Success
+	} else {
+		entry.Status = Failure
+		entry.Error = err.Error()
+		if p := l.errorPriorityOf(entry, err); entry.Pri < p {
+			entry.Pri = p
+		}
+	}
+	l.log(entry)
+}
+
 // LogDebug logs a debug event.
 func (l *Logger) LogDebug(message string, data any) {
-	if !l.context.IsDebugMode() {
+	if !l.context.IsDebugMode() && !l.isBoostedToDebug() {
 		return // Skip logging if debugMode is not enabled
 	}
 	debugInfo := DebugInfo{
@@ -282,7 +708,10 @@ func (l *Logger) LogDebug(message string, data any) {
 		Data:    map[string]any{"context": data},
 	}
 
-	debugInfo.FileName, debugInfo.LineNumber, debugInfo.FunctionName, debugInfo.StackTrace = GetDebugInfo(2)
+	if !isDebugCaptureDisabled() {
+		debugInfo.FileName, debugInfo.LineNumber, debugInfo.FunctionName, debugInfo.StackTrace = GetDebugInfo(2)
+		debugInfo.StackTrace = truncateStackTrace(debugInfo.StackTrace, l.maxStackDepth)
+	}
 
 	entry := l.newLogEntry(message, debugInfo)
 	entry.Type = Debug
@@ -290,8 +719,10 @@ func (l *Logger) LogDebug(message string, data any) {
 }
 
 // Log logs a generic message as an activity event.
+// The message is recorded in the entry's message field and the data is left empty.
+// Use LogActivity to attach data to the message.
 func (l *Logger) Log(message string) {
-	l.LogActivity("", message)
+	l.LogActivity(message, nil)
 }
 
 // SetDebugMode sets the debug mode for all loggers sharing this context.
@@ -309,6 +740,24 @@ func (lc *LoggerContext) IsDebugMode() bool {
 	return atomic.LoadInt32(&lc.debugMode) == 1 // Atomically read debugMode
 }
 
+// SetValidationCollector makes all loggers sharing this context record validation
+// failures in c. Passing nil stops recording.
+func (lc *LoggerContext) SetValidationCollector(c *ValidationCollector) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.collector = c
+}
+
+// recordValidationError adds a validation failure to the context's collector, if one is set.
+func (lc *LoggerContext) recordValidationError(entry LogEntry, err error) {
+	lc.mu.Lock()
+	c := lc.collector
+	lc.mu.Unlock()
+	if c != nil {
+		c.add(entry, err)
+	}
+}
+
 // ChangePriority changes the priority level of the Logger.
 func (lc *LoggerContext) ChangeMinLogPriority(minLogPriority LogPriority) {
 	lc.mu.Lock()
@@ -380,3 +829,122 @@ func NewChangeInfo(entity, operation string) *ChangeInfo {
 		Changes: []ChangeDetail{},
 	}
 }
+
+// WithSnapshots sets complete before and after snapshots on a ChangeInfo instance and returns the ChangeInfo.
+// See ChangeInfo for the payload size tradeoff.
+func (ci *ChangeInfo) WithSnapshots(before, after any) *ChangeInfo {
+	ci.Before = before
+	ci.After = after
+	return ci
+}
+
+// WithAffectedCount sets the number of objects affected by a bulk change on a ChangeInfo
+// instance and returns the ChangeInfo.
+func (ci *ChangeInfo) WithAffectedCount(n int) *ChangeInfo {
+	ci.AffectedCount = n
+	return ci
+}
+
+// NewChangeInfoFromDiff creates a new ChangeInfo by comparing the exported fields of two
+// values of the same struct type (or pointers to it). A ChangeDetail is added for every
+// field whose value differs; fields are named by their JSON tag if present.
+// Fields tagged `logharbour:"-"` or `json:"-"` are not compared, and fields tagged
+// `logharbour:"sensitive"` are compared but their values are recorded as RedactedArg.
+// Nested structs that contain sensitive fields are compared field by field, with the
+// changes named by their dotted path, e.g. "db.password", so that only the sensitive
+// fields are redacted. Other values that contain sensitive fields, such as a nil pointer
+// to such a struct or a slice of them, are recorded as RedactedArg as a whole.
+// If includeSnapshots is true, before and after are also stored as complete snapshots,
+// including the excluded and sensitive fields.
+func NewChangeInfoFromDiff(entity, operation string, before, after any, includeSnapshots bool) (*ChangeInfo, error) {
+	bv := reflect.Indirect(reflect.ValueOf(before))
+	av := reflect.Indirect(reflect.ValueOf(after))
+	if bv.Kind() != reflect.Struct || av.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("before and after must be structs, got %T and %T", before, after)
+	}
+	if bv.Type() != av.Type() {
+		return nil, fmt.Errorf("before and after must be of the same type, got %T and %T", before, after)
+	}
+
+	ci := NewChangeInfo(entity, operation)
+	addStructChanges(ci, "", bv, av)
+	if includeSnapshots {
+		ci.WithSnapshots(before, after)
+	}
+	return ci, nil
+}
+
+// addStructChanges adds a ChangeDetail to ci for every field that differs between the
+// structs bv and av, prefixing the field names with prefix.
+func addStructChanges(ci *ChangeInfo, prefix string, bv, av reflect.Value) {
+	t := bv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("logharbour")
+		if !field.IsExported() || tag == "-" || field.Tag.Get("json") == "-" {
+			continue
+		}
+		name := prefix + fieldName(field)
+		oldField, newField := bv.Field(i), av.Field(i)
+		oldVal := oldField.Interface()
+		newVal := newField.Interface()
+		if reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+		switch {
+		case tag == "sensitive":
+			oldVal, newVal = RedactedArg, RedactedArg
+		case hasSensitiveFields(field.Type, nil):
+			if oldField.Kind() == reflect.Pointer && !oldField.IsNil() && !newField.IsNil() {
+				oldField, newField = oldField.Elem(), newField.Elem()
+			}
+			if oldField.Kind() == reflect.Struct {
+				addStructChanges(ci, name+".", oldField, newField)
+				continue
+			}
+			oldVal, newVal = RedactedArg, RedactedArg
+		}
+		ci.AddChange(name, oldVal, newVal)
+	}
+}
+
+// hasSensitiveFields reports whether values of type t may contain a field tagged
+// `logharbour:"sensitive"`, directly or through pointers, slices, arrays and maps.
+// seen holds the struct types being checked, to stop at recursive types.
+func hasSensitiveFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasSensitiveFields(t.Elem(), seen)
+	case reflect.Map:
+		return hasSensitiveFields(t.Key(), seen) || hasSensitiveFields(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return false
+		}
+		if seen == nil {
+			seen = make(map[reflect.Type]bool)
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("logharbour")
+			if !field.IsExported() || tag == "-" || field.Tag.Get("json") == "-" {
+				continue
+			}
+			if tag == "sensitive" || hasSensitiveFields(field.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldName returns the JSON name of a struct field, falling back to the Go field name.
+// As for encoding/json, a field tagged `json:"-,"` is named "-".
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
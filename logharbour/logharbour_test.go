@@ -2,14 +2,31 @@ package logharbour
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/remiges-tech/logharbour/logharbour/logharbourtest"
 )
 
 type FailWriter struct{}
@@ -107,6 +124,14 @@ func TestLog(t *testing.T) {
 	if loggedEntry.Type != Activity {
 		t.Errorf("Expected Type to be '%s'. Got: '%s'", Activity.String(), loggedEntry.Type.String())
 	}
+
+	if loggedEntry.Msg != message {
+		t.Errorf("Expected Msg to be '%s'. Got: '%s'", message, loggedEntry.Msg)
+	}
+
+	if loggedEntry.Data != nil {
+		t.Errorf("Expected Data to be empty. Got: '%v'", loggedEntry.Data)
+	}
 }
 
 func TestErrMethods(t *testing.T) {
@@ -312,3 +337,3348 @@ func Example() {
 	// }
 
 }
+
+func TestWithWhen(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	eventTime := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	logger.WithWhen(eventTime).LogActivity("replayed event", nil)
+
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !loggedEntry.When.Equal(eventTime) {
+		t.Errorf("Expected When to be '%v'. Got: '%v'", eventTime, loggedEntry.When)
+	}
+
+	// The original logger must keep using the current time.
+	buf.Reset()
+	before := time.Now().UTC()
+	logger.LogActivity("live event", nil)
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loggedEntry.When.Before(before.Add(-time.Second)) {
+		t.Errorf("Expected When to be close to now. Got: '%v'", loggedEntry.When)
+	}
+}
+
+func TestActorAndSubjectType(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	// Entries without actor/subject types must not carry the new fields.
+	logger.WithWho("admin1").LogActivity("plain entry", nil)
+	if strings.Contains(buf.String(), "actor_type") || strings.Contains(buf.String(), "subject_type") {
+		t.Errorf("Expected actor_type and subject_type to be omitted. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithWho("user1").WithActorType("user").
+		WithClass("User").WithInstanceId("user1").WithSubjectType("user").
+		LogActivity("user updated own profile", nil)
+
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loggedEntry.ActorType != "user" || loggedEntry.SubjectType != "user" {
+		t.Errorf("Expected actor and subject type 'user'. Got: '%s' and '%s'", loggedEntry.ActorType, loggedEntry.SubjectType)
+	}
+	if !loggedEntry.IsSelfAction() {
+		t.Errorf("Expected entry to be a self action")
+	}
+
+	loggedEntry.Who = "admin1"
+	loggedEntry.ActorType = "admin"
+	if loggedEntry.IsSelfAction() {
+		t.Errorf("Expected entry not to be a self action")
+	}
+}
+
+type pressureWriter struct {
+	bytes.Buffer
+	pressure float64
+}
+
+func (pw *pressureWriter) Pressure() float64 {
+	return pw.pressure
+}
+
+func TestPressure(t *testing.T) {
+	lctx := NewLoggerContext(Info)
+
+	logger := NewLogger(lctx, "testApp", &bytes.Buffer{})
+	if p := logger.Pressure(); p != 0 {
+		t.Errorf("Expected pressure 0 for a synchronous writer. Got: %v", p)
+	}
+
+	pw := &pressureWriter{pressure: 0.75}
+	logger = NewLogger(lctx, "testApp", pw)
+	if p := logger.Pressure(); p != 0.75 {
+		t.Errorf("Expected pressure 0.75. Got: %v", p)
+	}
+
+	logger = NewLoggerWithFallback(lctx, "testApp", NewFallbackWriter(pw, &bytes.Buffer{}))
+	if p := logger.Pressure(); p != 0.75 {
+		t.Errorf("Expected pressure 0.75 through FallbackWriter. Got: %v", p)
+	}
+
+	// A SplitWriter reports how full its queue is.
+	buffered := &gatedWriter{gate: make(chan struct{})}
+	size := 4
+	sw := NewSplitWriter(&bytes.Buffer{}, buffered, SplitWriterConfig{BufferSize: &size})
+	defer sw.Close()
+	logger = NewLogger(lctx, "testApp", sw)
+	for i := 0; i < 3; i++ {
+		logger.LogActivity("queued", nil)
+	}
+	// The goroutine takes the first entry off the queue and blocks on the gate.
+	deadline := time.Now().Add(time.Second)
+	for len(sw.queue) > 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if p := logger.Pressure(); p != 0.5 {
+		t.Errorf("Expected pressure 0.5 for a SplitWriter. Got: %v", p)
+	}
+	close(buffered.gate)
+	if err := sw.Flush(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if p := logger.Pressure(); p != 0 {
+		t.Errorf("Expected pressure 0 once the queue is drained. Got: %v", p)
+	}
+}
+
+func TestDryRunWithValidationCollector(t *testing.T) {
+	lctx := NewLoggerContext(Info)
+	collector := NewValidationCollector()
+	lctx.SetValidationCollector(collector)
+
+	var primary, fallback bytes.Buffer
+	logger := NewLoggerWithFallback(lctx, "testApp", NewFallbackWriter(&primary, &fallback)).WithDryRun(true)
+
+	logger.LogActivity("valid entry", nil)
+	if primary.Len() != 0 || fallback.Len() != 0 {
+		t.Errorf("Expected nothing to be written in dry-run mode. Got: %q, %q", primary.String(), fallback.String())
+	}
+	if failures := collector.Failures(); len(failures) != 0 {
+		t.Errorf("Expected no validation failures. Got: %v", failures)
+	}
+
+	// An actor type without a 'who' is invalid.
+	logger.WithActorType("admin").LogActivity("invalid entry", nil)
+	failures := collector.Failures()
+	if len(failures) != 1 {
+		t.Fatalf("Expected 1 validation failure. Got: %d", len(failures))
+	}
+	if failures[0].Entry.Msg != "invalid entry" {
+		t.Errorf("Expected failure for 'invalid entry'. Got: '%s'", failures[0].Entry.Msg)
+	}
+	if !strings.Contains(fallback.String(), "invalid entry") {
+		t.Errorf("Expected invalid entry to still reach the fallback writer")
+	}
+
+	collector.Reset()
+	if len(collector.Failures()) != 0 {
+		t.Errorf("Expected no failures after Reset")
+	}
+}
+
+func TestWithEscalation(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).
+		WithEscalation(func(e LogEntry) bool { return e.Pri == Err }, 2, time.Minute, Crit)
+
+	var priorities []LogPriority
+	for i := 0; i < 4; i++ {
+		buf.Reset()
+		logger.Err().LogActivity("repeated failure", nil)
+		var loggedEntry LogEntry
+		if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		priorities = append(priorities, loggedEntry.Pri)
+		if loggedEntry.Pri == Crit && loggedEntry.EscalatedFrom != Err {
+			t.Errorf("Expected escalated entry to record original priority Err. Got: %v", loggedEntry.EscalatedFrom)
+		}
+	}
+
+	expected := []LogPriority{Err, Err, Crit, Crit}
+	for i := range expected {
+		if priorities[i] != expected[i] {
+			t.Errorf("Expected priorities %v. Got: %v", expected, priorities)
+			break
+		}
+	}
+
+	// A burst of matching entries keeps only the times needed for the threshold.
+	for i := 0; i < 100; i++ {
+		logger.Err().LogActivity("repeated failure", nil)
+	}
+	if n := len(logger.escalation.seen); n != 3 {
+		t.Errorf("Expected 3 times kept. Got: %d", n)
+	}
+
+	// Entries that don't match the predicate are not escalated.
+	buf.Reset()
+	logger.Warn().LogActivity("warning", nil)
+	if strings.Contains(buf.String(), "escalated_from") {
+		t.Errorf("Expected warning not to be escalated. Got: %s", buf.String())
+	}
+}
+
+func TestNewChangeInfoFromDiff(t *testing.T) {
+	type user struct {
+		Email    string `json:"email"`
+		Username string `json:"username"`
+		Age      int
+		Token    string `json:"-"`
+	}
+	before := user{Email: "old@example.com", Username: "same", Age: 30, Token: "old"}
+	after := user{Email: "new@example.com", Username: "same", Age: 31, Token: "new"}
+
+	ci, err := NewChangeInfoFromDiff("User", "Update", before, &after, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(ci.Changes) != 2 {
+		t.Fatalf("Expected 2 changes. Got: %v", ci.Changes)
+	}
+	if ci.Changes[0].Field != "email" || ci.Changes[1].Field != "Age" {
+		t.Errorf("Expected changes to 'email' and 'Age'. Got: %v", ci.Changes)
+	}
+	if ci.Before != nil || ci.After != nil {
+		t.Errorf("Expected no snapshots by default")
+	}
+
+	data, _ := json.Marshal(ci)
+	if strings.Contains(string(data), "before") {
+		t.Errorf("Expected snapshots to be omitted from output. Got: %s", data)
+	}
+
+	ci, err = NewChangeInfoFromDiff("User", "Update", before, after, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ci.Before == nil || ci.After == nil {
+		t.Errorf("Expected snapshots to be set")
+	}
+
+	if _, err := NewChangeInfoFromDiff("User", "Update", before, "not a struct", false); err == nil {
+		t.Errorf("Expected error for mismatched types")
+	}
+}
+
+func TestWithBoostedLevel(t *testing.T) {
+	var buf bytes.Buffer
+	lctx := NewLoggerContext(Warn)
+	logger := NewLogger(lctx, "testApp", &buf)
+
+	logger.Debug1().LogActivity("suppressed", nil)
+	if buf.Len() != 0 {
+		t.Errorf("Expected Debug1 entry to be suppressed. Got: %s", buf.String())
+	}
+
+	logger.WithBoostedLevel(Debug2, func(l *Logger) {
+		l.Debug1().LogActivity("boosted", nil)
+		l.LogDebug("boosted debug", nil)
+	})
+	if !strings.Contains(buf.String(), "boosted") || !strings.Contains(buf.String(), "boosted debug") {
+		t.Errorf("Expected boosted entries to be logged. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.Debug1().LogActivity("suppressed again", nil)
+	if buf.Len() != 0 {
+		t.Errorf("Expected original logger to be unchanged. Got: %s", buf.String())
+	}
+}
+
+func TestBoostFromRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if logger.BoostFromRequest(req) != logger {
+		t.Errorf("Expected logger to be unchanged without the debug header")
+	}
+
+	req.Header.Set(DebugHeader, "debug1")
+	boosted := logger.BoostFromRequest(req)
+	boosted.Debug1().LogActivity("debug1 entry", nil)
+	boosted.Debug2().LogActivity("debug2 entry", nil)
+	if !strings.Contains(buf.String(), "debug1 entry") {
+		t.Errorf("Expected Debug1 entry to be logged. Got: %s", buf.String())
+	}
+	if strings.Contains(buf.String(), "debug2 entry") {
+		t.Errorf("Expected Debug2 entry to be suppressed. Got: %s", buf.String())
+	}
+}
+
+func TestStats(t *testing.T) {
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &bytes.Buffer{})
+	clone := logger.WithModule("module1")
+
+	logger.LogActivity("info entry", nil)
+	clone.Err().LogActivity("err entry", nil)
+	clone.Err().LogActivity("err entry", nil)
+	logger.Debug1().LogActivity("suppressed entry", nil)
+
+	stats := logger.Stats()
+	if stats[Info] != 1 || stats[Err] != 2 || stats[Debug1] != 0 {
+		t.Errorf("Expected 1 Info and 2 Err entries. Got: %v", stats)
+	}
+
+	window := clone.StatsWindow(time.Minute)
+	if window[Info] != 1 || window[Err] != 2 {
+		t.Errorf("Expected 1 Info and 2 Err entries in the last minute. Got: %v", window)
+	}
+	window = clone.StatsWindow(time.Hour)
+	if window[Info] != 1 || window[Err] != 2 {
+		t.Errorf("Expected 1 Info and 2 Err entries in the last hour. Got: %v", window)
+	}
+}
+
+func TestNewlineMode(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	data := map[string]any{"trace": "line1\nline2", "lines": []any{"a\r\nb"}}
+	logger.LogActivity("multi\nline", data)
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("Expected entry to be a single line. Got: %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithNewlineMode(NewlineReplace).LogActivity("multi\nline", data)
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loggedEntry.Msg != "multi line" {
+		t.Errorf("Expected message 'multi line'. Got: %q", loggedEntry.Msg)
+	}
+	loggedData := loggedEntry.Data.(map[string]any)
+	if loggedData["trace"] != "line1 line2" || loggedData["lines"].([]any)[0] != "a b" {
+		t.Errorf("Expected newlines in data to be replaced. Got: %v", loggedData)
+	}
+	if data["trace"] != "line1\nline2" {
+		t.Errorf("Expected caller's data to be unchanged")
+	}
+
+	buf.Reset()
+	change := ChangeInfo{Entity: "user", Op: "update", Changes: []ChangeDetail{{Field: "bio", OldVal: "old\nbio", NewVal: "new\r\nbio"}}}
+	logger.WithNewlineMode(NewlineReplace).LogDataChange("bio updated", change)
+	if strings.Contains(buf.String(), `\n`) || strings.Contains(buf.String(), `\r`) {
+		t.Errorf("Expected newlines in struct data to be replaced. Got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"old_value":"old bio"`) || !strings.Contains(buf.String(), `"new_value":"new bio"`) {
+		t.Errorf("Expected the change values with spaces. Got: %s", buf.String())
+	}
+}
+
+func TestLogResult(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	tests := []struct {
+		name       string
+		logger     *Logger
+		err        error
+		wantPri    LogPriority
+		wantStatus Status
+		wantError  string
+	}{
+		{"success", logger, nil, Info, Success, ""},
+		{"failure", logger, errors.New("boom"), Err, Failure, "boom"},
+		{"failure keeps higher priority", logger.Crit(), errors.New("boom"), Crit, Failure, "boom"},
+		{"success keeps caller priority", logger.Warn(), nil, Warn, Success, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			tt.logger.LogResult("operation done", tt.err, nil)
+
+			var loggedEntry LogEntry
+			if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if loggedEntry.Pri != tt.wantPri || loggedEntry.Status != tt.wantStatus || loggedEntry.Error != tt.wantError {
+				t.Errorf("Expected (%v, %v, %q). Got: (%v, %v, %q)", tt.wantPri, tt.wantStatus, tt.wantError,
+					loggedEntry.Pri, loggedEntry.Status, loggedEntry.Error)
+			}
+		})
+	}
+}
+
+func TestMoneyField(t *testing.T) {
+	tests := []struct {
+		minorUnits int64
+		currency   string
+		want       string
+	}{
+		{12345, "inr", "123.45"},
+		{5, "USD", "0.05"},
+		{-250, "EUR", "-2.50"},
+		{1000, "JPY", "1000"},
+		{1234, "KWD", "1.234"},
+	}
+	for _, tt := range tests {
+		m, err := NewMoneyField(tt.minorUnits, tt.currency)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var decoded map[string]any
+		json.Unmarshal(data, &decoded)
+		if decoded["amount"] != tt.want || decoded["currency"] != strings.ToUpper(tt.currency) {
+			t.Errorf("Expected amount %s %s. Got: %s", tt.want, tt.currency, data)
+		}
+	}
+
+	if _, err := NewMoneyField(1, "RUPEES"); err == nil {
+		t.Errorf("Expected error for invalid currency code")
+	}
+
+	m, _ := NewMoneyField(100, "INR")
+	info := AttachMoney(map[string]any{"order": "o-1"}, "total", m).(map[string]any)
+	if info["order"] != "o-1" || info["total"] != m {
+		t.Errorf("Expected money to be added to the activity data. Got: %v", info)
+	}
+}
+
+func TestChannelWriter(t *testing.T) {
+	ch := make(chan LogEntry, 1)
+	var buf bytes.Buffer
+	cw := NewChannelWriter(ch, DropNewest)
+	logger := NewLogger(NewLoggerContext(Info), "testApp", io.MultiWriter(&buf, cw))
+
+	logger.LogActivity("first", nil)
+	logger.LogActivity("second", nil)
+
+	entry := <-ch
+	if entry.Msg != "first" || entry.Type != Activity {
+		t.Errorf("Expected first activity entry on the channel. Got: %+v", entry)
+	}
+	if cw.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped entry. Got: %d", cw.Dropped())
+	}
+	if !strings.Contains(buf.String(), "second") {
+		t.Errorf("Expected dropped entry to still reach the other writer")
+	}
+}
+
+func TestWithMaxFieldBytes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	if _, err := logger.WithMaxFieldBytes("nosuchfield", 10); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+	if _, err := logger.WithMaxFieldBytes("msg", 0); err == nil {
+		t.Errorf("Expected error for non-positive size")
+	}
+
+	limited, err := logger.WithMaxFieldBytes("msg", 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	limited, err = limited.WithMaxFieldBytes("data.stackTrace", 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	limited.LogActivity("héllo world", map[string]any{"stackTrace": "abcdefgh", "other": "abcdefgh"})
+
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// "héllo" is 6 bytes; cutting at 5 must not split the two-byte 'é'.
+	if loggedEntry.Msg != "héll"+TruncatedSuffix {
+		t.Errorf("Expected truncated message. Got: %q", loggedEntry.Msg)
+	}
+	data := loggedEntry.Data.(map[string]any)
+	if data["stackTrace"] != "abcd"+TruncatedSuffix || data["other"] != "abcdefgh" {
+		t.Errorf("Expected only stackTrace to be truncated. Got: %v", data)
+	}
+
+	buf.Reset()
+	limited.ClearFieldLimits().LogActivity("héllo world", nil)
+	if !strings.Contains(buf.String(), `"msg":"héllo world"`) {
+		t.Errorf("Expected no truncation after ClearFieldLimits. Got: %s", buf.String())
+	}
+	if len(limited.fieldLimits) != 2 {
+		t.Errorf("Expected original logger to keep its limits")
+	}
+}
+
+type unsortedData struct{}
+
+func (unsortedData) MarshalJSON() ([]byte, error) {
+	return []byte(`{"zeta":1,"alpha":{"y":2,"b":3}}`), nil
+}
+
+func TestStableDataOutput(t *testing.T) {
+	logger := NewLogger(NewLoggerContext(Info), "testApp", nil).WithWhen(time.Unix(0, 0))
+
+	render := func(l *Logger, data any) string {
+		var buf bytes.Buffer
+		l.writer = &buf
+		l.LogActivity("entry", data)
+		return buf.String()
+	}
+
+	data := map[string]any{"zeta": 1, "alpha": map[string]any{"y": 2, "b": 3}, "mid": []any{map[string]any{"d": 1, "c": 2}}}
+	first := render(logger, data)
+	for i := 0; i < 20; i++ {
+		if out := render(logger, data); out != first {
+			t.Fatalf("Expected stable output. Got:\n%s\n%s", first, out)
+		}
+	}
+	if !strings.Contains(first, `"alpha":{"b":3,"y":2}`) {
+		t.Errorf("Expected nested keys to be sorted. Got: %s", first)
+	}
+
+	if out := render(logger, unsortedData{}); !strings.Contains(out, `"data":{"alpha":{"b":3,"y":2},"zeta":1}`) {
+		t.Errorf("Expected custom marshaler output to be canonicalized by default. Got: %s", out)
+	}
+	if out := render(logger.WithCanonicalData(false), unsortedData{}); !strings.Contains(out, `"data":{"zeta":1,"alpha":{"y":2,"b":3}}`) {
+		t.Errorf("Expected custom marshaler output to be kept with canonical mode off. Got: %s", out)
+	}
+}
+
+func TestWithExemplar(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	logger.LogActivity("no exemplar", nil)
+	if strings.Contains(buf.String(), `"exemplar":`) {
+		t.Errorf("Expected exemplar to be omitted. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithExemplar("http_request_duration_seconds", "4bf92f3577b34da6", nil).LogActivity("with exemplar", nil)
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loggedEntry.Exemplar == nil || loggedEntry.Exemplar.Metric != "http_request_duration_seconds" || loggedEntry.Exemplar.TraceID != "4bf92f3577b34da6" {
+		t.Errorf("Expected exemplar to be recorded. Got: %+v", loggedEntry.Exemplar)
+	}
+}
+
+func TestSetLastResortWriter(t *testing.T) {
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+
+	failWriter := FailWriter{}
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "testApp", NewFallbackWriter(&failWriter, &failWriter))
+	logger.LogActivity("test message", nil)
+
+	if !strings.Contains(lastResort.String(), "test message") || !strings.Contains(lastResort.String(), "failed to write") {
+		t.Errorf("Expected entry and error in the last resort writer. Got: %s", lastResort.String())
+	}
+}
+
+func TestTraceSampler(t *testing.T) {
+	sampler := NewTraceSampler(0.5)
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		traceID := fmt.Sprintf("trace-%d", i)
+		decision := sampler.KeepTrace(traceID)
+		// The decision for a trace must be stable.
+		if sampler.KeepTrace(traceID) != decision {
+			t.Fatalf("Expected consistent decision for %s", traceID)
+		}
+		if decision {
+			kept++
+		}
+	}
+	if kept < 400 || kept > 600 {
+		t.Errorf("Expected about half of the traces to be kept. Got: %d", kept)
+	}
+
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).WithTraceSampler(NewTraceSampler(0))
+	logger.WithTraceID("dropped-trace").LogActivity("dropped", nil)
+	logger.WithTraceID("dropped-trace").Crit().LogActivity("critical", nil)
+	logger.LogActivity("untraced", nil)
+	if strings.Contains(buf.String(), `"msg":"dropped"`) {
+		t.Errorf("Expected sampled-out entry to be dropped. Got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "critical") || !strings.Contains(buf.String(), "untraced") {
+		t.Errorf("Expected Crit and untraced entries to be kept. Got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"trace_id":"dropped-trace"`) {
+		t.Errorf("Expected trace ID to be recorded. Got: %s", buf.String())
+	}
+}
+
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	var buf syncBuffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).WithModule("worker1")
+
+	stop := logger.StartHeartbeat(5*time.Millisecond, "still alive")
+	time.Sleep(30 * time.Millisecond)
+	stop()
+	stop()
+
+	out := buf.String()
+	if strings.Count(out, "still alive") < 2 {
+		t.Errorf("Expected several heartbeats. Got: %s", out)
+	}
+	if !strings.Contains(out, `"module":"worker1"`) {
+		t.Errorf("Expected heartbeat to carry the module. Got: %s", out)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if buf.String() != out {
+		t.Errorf("Expected no heartbeats after stop")
+	}
+}
+
+func TestRFC5424Writer(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRFC5424Writer(&buf, RFC5424Config{EnterpriseID: 32473})
+	logger := NewLogger(NewLoggerContext(Info), "testApp", w).
+		WithWhen(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)).
+		WithWho(`bob "the admin"`).
+		WithOp("login")
+
+	logger.Warn().LogActivity("user logged in", nil)
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "<132>1 2024-01-02T03:04:05Z ") {
+		t.Errorf("Expected priority 132 and RFC 3339 timestamp. Got: %s", line)
+	}
+	if !strings.Contains(line, `[logharbour@32473 type="A" pri="Warn"`) {
+		t.Errorf("Expected SD-ELEMENT with enterprise ID. Got: %s", line)
+	}
+	if !strings.Contains(line, `who="bob \"the admin\""`) || !strings.Contains(line, `op="login"`) {
+		t.Errorf("Expected escaped SD-PARAMs. Got: %s", line)
+	}
+	if strings.Contains(line, "remote_ip=") {
+		t.Errorf("Expected empty fields to be omitted. Got: %s", line)
+	}
+	if !strings.HasSuffix(line, "] user logged in\n") {
+		t.Errorf("Expected message after the structured data. Got: %s", line)
+	}
+
+	buf.Reset()
+	w = NewRFC5424Writer(&buf, RFC5424Config{Fields: []string{"who", `bad name="x"`}})
+	NewLogger(NewLoggerContext(Info), strings.Repeat("a", 60), w).WithWho("line1\nline2").LogActivity("first\nsecond", nil)
+	line = buf.String()
+	if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "] first second\n") || !strings.Contains(line, `who="line1 line2"`) {
+		t.Errorf("Expected newlines to be replaced. Got: %q", line)
+	}
+	if !strings.Contains(line, " "+strings.Repeat("a", 48)+" ") {
+		t.Errorf("Expected APP-NAME to be truncated to 48 characters. Got: %s", line)
+	}
+	if w.names[1] != "bad_name__x_" {
+		t.Errorf("Expected SD-NAME to be sanitized. Got: %s", w.names[1])
+	}
+}
+
+func TestSigningWriter(t *testing.T) {
+	var buf bytes.Buffer
+	keys := map[string][]byte{"k1": []byte("secret1"), "k2": []byte("secret2")}
+	logger := NewLogger(NewLoggerContext(Info), "testApp", NewSigningWriter(&buf, "k2", keys["k2"]))
+
+	logger.LogActivity("User logged in", map[string]any{"user": "john"})
+	line := buf.Bytes()
+
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(line, &loggedEntry); err != nil {
+		t.Fatalf("Expected signed line to be valid JSON: %v", err)
+	}
+	if err := VerifyLine(line, keys); err != nil {
+		t.Errorf("Expected signature to verify: %v", err)
+	}
+
+	tampered := bytes.Replace(line, []byte("john"), []byte("jane"), 1)
+	if err := VerifyLine(tampered, keys); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected tampered line to fail verification. Got: %v", err)
+	}
+
+	if err := VerifyLine(line, map[string][]byte{"k1": keys["k1"]}); err == nil {
+		t.Errorf("Expected error for unknown key ID")
+	}
+}
+
+func TestScheduleRules(t *testing.T) {
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	logger := NewLogger(NewLoggerContext(Info), "testApp", nil).WithSchedule([]ScheduleRule{
+		{Days: weekdays, Start: 9 * time.Hour, End: 18 * time.Hour, Location: time.UTC, MinPriority: Debug0},
+		{Days: []time.Weekday{time.Friday}, Start: 22 * time.Hour, End: 6 * time.Hour, Location: time.UTC, MinPriority: Err},
+		{Location: time.UTC, MinPriority: Warn},
+	})
+
+	tests := []struct {
+		when time.Time
+		want LogPriority
+	}{
+		{time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Debug0}, // Monday business hours
+		{time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), Warn},   // Monday evening
+		{time.Date(2024, 1, 5, 23, 0, 0, 0, time.UTC), Err},    // Friday night
+		{time.Date(2024, 1, 6, 3, 0, 0, 0, time.UTC), Err},     // Saturday early morning, wrapped from Friday
+		{time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC), Warn},   // Saturday
+	}
+	for _, tt := range tests {
+		got, ok := logger.schedule.minPriorityAt(tt.when)
+		if !ok || got != tt.want {
+			t.Errorf("At %v expected minimum priority %v. Got: %v (%v)", tt.when, tt.want, got, ok)
+		}
+	}
+
+	// On the day daylight saving time starts, 10:30 is 9.5 hours after midnight.
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No time zone database: %v", err)
+	}
+	logger = logger.WithSchedule([]ScheduleRule{{Start: 10 * time.Hour, End: 11 * time.Hour, Location: newYork, MinPriority: Debug0}})
+	if got, ok := logger.schedule.minPriorityAt(time.Date(2024, 3, 10, 10, 30, 0, 0, newYork)); !ok || got != Debug0 {
+		t.Errorf("Expected the window to follow the wall clock on DST days. Got: %v (%v)", got, ok)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	handler := Middleware(base)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger, ok := FromContext(r.Context())
+		if !ok {
+			t.Errorf("Expected logger in request context")
+			return
+		}
+		logger.LogActivity("handling request", nil)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	requestID := rec.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Errorf("Expected request ID in the response")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 entries. Got: %s", buf.String())
+	}
+	var completion LogEntry
+	if err := json.Unmarshal([]byte(lines[1]), &completion); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if completion.Op != "GET /users" || completion.RemoteIP != "10.0.0.1" || completion.TraceID != requestID {
+		t.Errorf("Expected op, remote IP and request ID to be set. Got: %+v", completion)
+	}
+	if completion.Status != Failure || completion.Pri != Err {
+		t.Errorf("Expected failed request to be logged as Err. Got: %v %v", completion.Status, completion.Pri)
+	}
+	if completion.Data.(map[string]any)["status_code"] != float64(http.StatusServiceUnavailable) {
+		t.Errorf("Expected status code 503. Got: %v", completion.Data)
+	}
+
+	// Streaming handlers can flush through the middleware.
+	streaming := Middleware(base)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Errorf("Expected the ResponseWriter to implement http.Flusher")
+			return
+		}
+		w.Write([]byte("data: event\n\n"))
+		flusher.Flush()
+	}))
+	rec = httptest.NewRecorder()
+	streaming.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if !rec.Flushed {
+		t.Errorf("Expected the response to be flushed")
+	}
+}
+
+func TestMiddlewareDebugHeader(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		logger, _ := FromContext(r.Context())
+		logger.Debug0().LogActivity("debug entry", nil)
+	}
+	trusted := func(r *http.Request) bool { return r.RemoteAddr == "10.0.0.1:5555" }
+
+	for _, tc := range []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		remoteAddr string
+		want       bool
+	}{
+		{"no option", Middleware(base), "10.0.0.1:5555", false},
+		{"untrusted", Middleware(base, WithDebugHeader(trusted)), "10.0.0.2:5555", false},
+		{"trusted", Middleware(base, WithDebugHeader(trusted)), "10.0.0.1:5555", true},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set(DebugHeader, "Debug0")
+		tc.middleware(http.HandlerFunc(handler)).ServeHTTP(httptest.NewRecorder(), req)
+		if got := strings.Contains(buf.String(), "debug entry"); got != tc.want {
+			t.Errorf("%s: expected debug entry logged: %v. Got: %s", tc.name, tc.want, buf.String())
+		}
+	}
+}
+
+func TestWithFieldTracing(t *testing.T) {
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &bytes.Buffer{})
+	if logger.WithModule("m").FieldTrace() != nil {
+		t.Errorf("Expected no trace without field tracing")
+	}
+
+	traced := logger.WithFieldTracing().WithModule("billing").WithWho("alice")
+	branch := traced.WithModule("payments")
+	_ = traced.WithOp("refund")
+
+	trace := branch.FieldTrace()
+	if len(trace) != 3 {
+		t.Fatalf("Expected 3 mutations. Got: %v", trace)
+	}
+	if trace[0].Method != "WithModule" || trace[0].Value != "billing" || trace[2].Value != "payments" {
+		t.Errorf("Unexpected trace: %v", trace)
+	}
+	if !strings.Contains(trace[0].Caller, "logharbour_test.go") {
+		t.Errorf("Expected caller to be the test file. Got: %s", trace[0].Caller)
+	}
+	if len(traced.FieldTrace()) != 2 {
+		t.Errorf("Expected parent trace to be unaffected by clones. Got: %v", traced.FieldTrace())
+	}
+
+	trace = traced.WithTimeZone(time.UTC).WithDryRun(true).FieldTrace()
+	if len(trace) != 4 || trace[2].Method != "WithTimeZone" || trace[3].Method != "WithDryRun" || !strings.Contains(trace[3].Caller, "logharbour_test.go") {
+		t.Errorf("Expected configuration calls to be traced. Got: %v", trace)
+	}
+
+	trace = traced.ClearAttachments().ClearBaggage().ClearDebugData().ClearFieldLimits().ClearRequiredFields().FieldTrace()
+	if len(trace) != 7 || trace[2].Method != "ClearAttachments" || trace[6].Method != "ClearRequiredFields" || !strings.Contains(trace[6].Caller, "logharbour_test.go") {
+		t.Errorf("Expected clear calls to be traced. Got: %v", trace)
+	}
+}
+
+func TestFieldTracingOffDoesNotAllocate(t *testing.T) {
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &bytes.Buffer{})
+	who, op := "alice", "refund"
+	// The only allocation is the clone of the Logger.
+	if n := testing.AllocsPerRun(100, func() { logger.WithWho(who) }); n > 1 {
+		t.Errorf("Expected WithWho to allocate once. Got: %v", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { logger.WithOp(op) }); n > 1 {
+		t.Errorf("Expected WithOp to allocate once. Got: %v", n)
+	}
+}
+
+func TestFollowReader(t *testing.T) {
+	path := t.TempDir() + "/app.log"
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	logger := NewLogger(NewLoggerContext(Info), "testApp", file)
+	logger.LogActivity("existing", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, errs := FollowReader(ctx, path)
+
+	next := func() LogEntry {
+		select {
+		case entry := <-entries:
+			return entry
+		case err := <-errs:
+			t.Fatalf("Unexpected error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for entry")
+		}
+		return LogEntry{}
+	}
+
+	if entry := next(); entry.Msg != "existing" {
+		t.Errorf("Expected 'existing'. Got: %q", entry.Msg)
+	}
+	logger.LogActivity("appended", nil)
+	if entry := next(); entry.Msg != "appended" {
+		t.Errorf("Expected 'appended'. Got: %q", entry.Msg)
+	}
+
+	// Rotate: move the file away and start a new one.
+	os.Rename(path, path+".1")
+	rotated, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer rotated.Close()
+	NewLogger(NewLoggerContext(Info), "testApp", rotated).LogActivity("after rotation", nil)
+	if entry := next(); entry.Msg != "after rotation" {
+		t.Errorf("Expected 'after rotation'. Got: %q", entry.Msg)
+	}
+
+	cancel()
+	for range entries {
+	}
+}
+
+func TestWithAttachment(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	first := Attachment{URI: "s3://uploads/a.pdf", ContentType: "application/pdf", Size: 1024, Checksum: "sha256:abc"}
+	second := Attachment{URI: "s3://uploads/b.png"}
+	withOne := logger.WithAttachment(first)
+	withTwo := withOne.WithAttachment(second)
+
+	withTwo.LogActivity("files uploaded", nil)
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(loggedEntry.Attachments) != 2 || loggedEntry.Attachments[0] != first || loggedEntry.Attachments[1] != second {
+		t.Errorf("Expected both attachments. Got: %+v", loggedEntry.Attachments)
+	}
+	if len(withOne.attachments) != 1 {
+		t.Errorf("Expected parent logger to keep one attachment")
+	}
+
+	buf.Reset()
+	withTwo.ClearAttachments().LogActivity("no files", nil)
+	if strings.Contains(buf.String(), "attachments") {
+		t.Errorf("Expected attachments to be cleared. Got: %s", buf.String())
+	}
+}
+
+func TestPanickingCallbackIsRecovered(t *testing.T) {
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+
+	var buf bytes.Buffer
+	lctx := NewLoggerContext(Info)
+	logger := NewLogger(lctx, "testApp", &buf).
+		WithEscalation(func(e LogEntry) bool { panic("buggy predicate") }, 1, time.Minute, Crit)
+
+	logger.LogActivity("still logged", map[string]any{"card": "4111111111111111"})
+
+	if !strings.Contains(buf.String(), "still logged") {
+		t.Errorf("Expected the original entry to be written. Got: %s", buf.String())
+	}
+	if !strings.Contains(lastResort.String(), "panic in escalation predicate: buggy predicate") {
+		t.Errorf("Expected the panic to be reported. Got: %s", lastResort.String())
+	}
+	if strings.Contains(lastResort.String(), "4111111111111111") {
+		t.Errorf("Expected the report to leave out Data. Got: %s", lastResort.String())
+	}
+
+	lctx.SetPropagatePanics(true)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected the panic to propagate")
+		}
+	}()
+	logger.LogActivity("propagates", nil)
+}
+
+func TestWithDebugData(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Debug2), "testApp", &buf).
+		WithDebugData(map[string]any{"query_plan": "seq scan"})
+
+	logger.Info().LogActivity("info entry", map[string]any{"user": "john"})
+	if strings.Contains(buf.String(), "query_plan") {
+		t.Errorf("Expected debug data to be left out at Info. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.Debug1().LogActivity("debug entry", map[string]any{"user": "john"})
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data := loggedEntry.Data.(map[string]any)
+	if data["query_plan"] != "seq scan" || data["user"] != "john" {
+		t.Errorf("Expected debug data merged with entry data. Got: %v", data)
+	}
+
+	// Struct Data, such as the DebugInfo of LogDebug, gets the keys in its JSON form.
+	buf.Reset()
+	logger.context.SetDebugMode(true)
+	logger.Debug1().LogDebug("debug info", "details")
+	loggedEntry = LogEntry{}
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data = loggedEntry.Data.(map[string]any)
+	if data["query_plan"] != "seq scan" || data["pid"] == nil || data["data"].(map[string]any)["context"] != "details" {
+		t.Errorf("Expected debug data merged with the DebugInfo fields. Got: %v", data)
+	}
+
+	buf.Reset()
+	logger.ClearDebugData().Debug1().LogActivity("debug entry", nil)
+	if strings.Contains(buf.String(), "query_plan") {
+		t.Errorf("Expected debug data to be cleared. Got: %s", buf.String())
+	}
+}
+
+func TestUnknownLogTypeFailsValidation(t *testing.T) {
+	lctx := NewLoggerContext(Info)
+	collector := NewValidationCollector()
+	lctx.SetValidationCollector(collector)
+
+	var primary, fallback bytes.Buffer
+	logger := NewLoggerWithFallback(lctx, "testApp", NewFallbackWriter(&primary, &fallback))
+
+	entry := logger.newLogEntry("bad type", nil)
+	entry.Type = LogType(42)
+	logger.log(entry)
+
+	if primary.Len() != 0 {
+		t.Errorf("Expected entry with unknown type not to reach the primary writer. Got: %s", primary.String())
+	}
+	if !strings.Contains(fallback.String(), "bad type") {
+		t.Errorf("Expected entry with unknown type to be written to the fallback writer")
+	}
+	if len(collector.Failures()) != 1 {
+		t.Errorf("Expected 1 validation failure. Got: %d", len(collector.Failures()))
+	}
+	if LogType(42).IsValid() || !Activity.IsValid() {
+		t.Errorf("Unexpected result from IsValid")
+	}
+}
+
+func TestCompactOutput(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).WithWho("john")
+
+	logger.LogActivity("verbose entry", nil)
+	for _, field := range []string{`"module":`, `"op":`, `"class":`, `"instance":`, `"remote_ip":`} {
+		if !strings.Contains(buf.String(), field) {
+			t.Errorf("Expected %s in verbose output. Got: %s", field, buf.String())
+		}
+	}
+
+	buf.Reset()
+	compact := NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, JSONEncoder{Compact: true})).WithWho("john")
+	compact.WithOp("login").LogActivity("compact entry", nil)
+	for _, field := range []string{`"module":`, `"class":`, `"instance":`, `"remote_ip":`} {
+		if strings.Contains(buf.String(), field) {
+			t.Errorf("Expected %s to be omitted in compact output. Got: %s", field, buf.String())
+		}
+	}
+	for _, field := range []string{`"app":`, `"who":`, `"op":`, `"status":`, `"msg":`, `"data":`} {
+		if !strings.Contains(buf.String(), field) {
+			t.Errorf("Expected %s in compact output. Got: %s", field, buf.String())
+		}
+	}
+
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Failed to read compact entry: %v", err)
+	}
+	if loggedEntry.Op != "login" || loggedEntry.Who != "john" || loggedEntry.Module != "" {
+		t.Errorf("Unexpected entry read from compact output: %+v", loggedEntry)
+	}
+}
+
+func TestAlert(t *testing.T) {
+	var all, alerts bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", io.MultiWriter(&all, NewAlertWriter(&alerts)))
+
+	logger.LogActivity("routine entry", nil)
+	logger.WithWho("admin").LogAlert("suspicious admin action", nil)
+
+	if !strings.Contains(all.String(), "routine entry") || !strings.Contains(all.String(), "suspicious admin action") {
+		t.Errorf("Expected all entries in the main output. Got: %s", all.String())
+	}
+	if strings.Contains(alerts.String(), "routine entry") {
+		t.Errorf("Expected entries without the alert flag not to be forwarded. Got: %s", alerts.String())
+	}
+
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(alerts.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Expected the alert entry to be forwarded: %v", err)
+	}
+	if !loggedEntry.Alert || loggedEntry.Pri != Info {
+		t.Errorf("Expected an Info entry with the alert flag. Got: %+v", loggedEntry)
+	}
+	if strings.Contains(strings.Split(all.String(), "\n")[0], `"alert"`) {
+		t.Errorf("Expected the alert flag to be omitted when not set")
+	}
+}
+
+type flushCloseWriter struct {
+	bytes.Buffer
+	flushed, closed bool
+	closes          int
+}
+
+func (w *flushCloseWriter) Flush() error {
+	w.flushed = true
+	return nil
+}
+
+func (w *flushCloseWriter) Close() error {
+	w.closed = true
+	w.closes++
+	return nil
+}
+
+func TestFlushOnSignal(t *testing.T) {
+	// Our own handler keeps the re-raised signal from terminating the test.
+	received := make(chan os.Signal, 2)
+	signal.Notify(received, os.Interrupt)
+	defer signal.Stop(received)
+
+	primary, fallback := &flushCloseWriter{}, &flushCloseWriter{}
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "testApp", NewFallbackWriter(primary, fallback))
+	stop := FlushOnSignal(logger, os.Interrupt)
+	defer stop()
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("Cannot send signal: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the signal to be raised again after flushing")
+		}
+	}
+
+	stop()
+	if !primary.flushed || !primary.closed || !fallback.flushed || !fallback.closed {
+		t.Errorf("Expected primary and fallback writers to be flushed and closed")
+	}
+}
+
+func TestMultiWriterWithEncoders(t *testing.T) {
+	var console, file bytes.Buffer
+	writer := NewMultiWriter(NewEncodedWriter(&console, ConsoleEncoder{}), &file)
+	logger := NewLogger(NewLoggerContext(Info), "testApp", writer).WithModule("billing").WithOp("send")
+
+	logger.LogActivity("invoice sent", map[string]any{"id": 42})
+
+	line := console.String()
+	for _, part := range []string{"Info", "testApp/billing", "invoice sent", "op=send", `data={"id":42}`} {
+		if !strings.Contains(line, part) {
+			t.Errorf("Expected %q in console output. Got: %s", part, line)
+		}
+	}
+	if strings.Contains(line, "\x1b[") {
+		t.Errorf("Expected no color codes without Color. Got: %q", line)
+	}
+
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(file.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Expected JSON in file output: %v", err)
+	}
+	if loggedEntry.Msg != "invoice sent" || loggedEntry.Op != "send" {
+		t.Errorf("Unexpected entry in file output: %+v", loggedEntry)
+	}
+
+	// Behind a plain io.Writer, the EncodedWriter re-encodes the JSON it receives.
+	console.Reset()
+	logger = NewLoggerWithFallback(NewLoggerContext(Info), "testApp",
+		NewFallbackWriter(NewEncodedWriter(&console, ConsoleEncoder{Color: true}), &bytes.Buffer{}))
+	logger.Warn().LogActivity("disk almost full", nil)
+	if !strings.Contains(console.String(), "\x1b[33mWarn") || !strings.Contains(console.String(), "disk almost full") {
+		t.Errorf("Expected a colored console line. Got: %q", console.String())
+	}
+}
+
+func TestSpanChild(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	span := logger.StartSpan("checkout")
+	child := span.Child("charge-card")
+	child.End(errors.New("card declined"))
+	span.End(nil)
+	span.End(nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 completion entries. Got: %d", len(lines))
+	}
+	var childEntry, parentEntry LogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &childEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &parentEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if parentEntry.TraceID == "" || parentEntry.SpanID == "" || parentEntry.ParentSpanID != "" {
+		t.Errorf("Unexpected IDs on the root span: %+v", parentEntry)
+	}
+	if childEntry.TraceID != parentEntry.TraceID {
+		t.Errorf("Expected the child to inherit trace ID %s. Got: %s", parentEntry.TraceID, childEntry.TraceID)
+	}
+	if childEntry.ParentSpanID != parentEntry.SpanID || childEntry.SpanID == parentEntry.SpanID {
+		t.Errorf("Expected the child to record its parent's span ID %s. Got: %+v", parentEntry.SpanID, childEntry)
+	}
+	if childEntry.Op != "charge-card" || childEntry.Status != Failure || parentEntry.Status != Success {
+		t.Errorf("Unexpected completion entries: %+v, %+v", childEntry, parentEntry)
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	t.Setenv(EnvVar, "staging")
+	lctx := NewLoggerContext(Info)
+	var primary, fallback bytes.Buffer
+	logger := NewLoggerWithFallback(lctx, "testApp", NewFallbackWriter(&primary, &fallback))
+
+	logger.LogActivity("detected env", nil)
+	if !strings.Contains(primary.String(), `"env":"staging"`) {
+		t.Errorf("Expected env detected from %s. Got: %s", EnvVar, primary.String())
+	}
+
+	lctx.SetAllowedEnvs("dev", "staging", "prod")
+	primary.Reset()
+	logger.WithEnv("qa").LogActivity("unknown env", nil)
+	if primary.Len() != 0 || !strings.Contains(fallback.String(), "unknown env") {
+		t.Errorf("Expected entry with a disallowed env to go to the fallback writer. Got: %q, %q", primary.String(), fallback.String())
+	}
+
+	logger.WithEnv("prod").LogActivity("allowed env", nil)
+	if !strings.Contains(primary.String(), `"env":"prod"`) {
+		t.Errorf("Expected entry with an allowed env. Got: %s", primary.String())
+	}
+
+	primary.Reset()
+	logger.WithEnv("").LogActivity("no env", nil)
+	if strings.Contains(primary.String(), `"env"`) {
+		t.Errorf("Expected env to be omitted when empty. Got: %s", primary.String())
+	}
+}
+
+func TestOTLPJSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, OTLPJSONEncoder{})).
+		WithWho("john").WithTraceID("4bf92f3577b34da6a3ce929d0e0e4736")
+
+	logger.Warn().LogActivity("quota exceeded", map[string]any{"used": 120, "ratio": 1.2})
+
+	var record struct {
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		SeverityNumber int            `json:"severityNumber"`
+		SeverityText   string         `json:"severityText"`
+		Body           map[string]any `json:"body"`
+		TraceID        string         `json:"traceId"`
+		Attributes     []struct {
+			Key   string         `json:"key"`
+			Value map[string]any `json:"value"`
+		} `json:"attributes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode OTLP record: %v", err)
+	}
+	if record.SeverityNumber != 13 || record.SeverityText != "Warn" || record.Body["stringValue"] != "quota exceeded" {
+		t.Errorf("Unexpected OTLP record: %+v", record)
+	}
+	if record.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || record.TimeUnixNano == "" {
+		t.Errorf("Unexpected OTLP record: %+v", record)
+	}
+	attributes := map[string]map[string]any{}
+	for _, kv := range record.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	if attributes["who"]["stringValue"] != "john" || attributes["app"]["stringValue"] != "testApp" {
+		t.Errorf("Expected who and app attributes. Got: %v", attributes)
+	}
+	if _, ok := attributes["op"]; ok {
+		t.Errorf("Expected empty fields to be left out. Got: %v", attributes)
+	}
+	if !strings.Contains(buf.String(), `{"key":"used","value":{"intValue":"120"}}`) {
+		t.Errorf("Expected data as a kvlist attribute. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger = NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, OTLPJSONEncoder{Envelope: true}))
+	logger.LogActivity("wrapped", nil)
+	if !strings.HasPrefix(buf.String(), `{"resourceLogs":[`) || !strings.Contains(buf.String(), `"service.name"`) {
+		t.Errorf("Expected an export request envelope. Got: %s", buf.String())
+	}
+}
+
+func TestWithTimeZone(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	logger.LogActivity("utc entry", nil)
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, offset := loggedEntry.When.Zone(); offset != 0 || !strings.Contains(buf.String(), `Z","who"`) {
+		t.Errorf("Expected UTC by default. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	ist := time.FixedZone("IST", 5*3600+1800)
+	when := time.Date(2024, 1, 2, 9, 34, 5, 0, time.UTC)
+	logger.WithTimeZone(ist).WithWhen(when).LogActivity("local entry", nil)
+	if !strings.Contains(buf.String(), `"when":"2024-01-02T15:04:05+05:30"`) {
+		t.Errorf("Expected the time with its offset. Got: %s", buf.String())
+	}
+	loggedEntry = LogEntry{}
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !loggedEntry.When.Equal(when) {
+		t.Errorf("Expected %v when read back. Got: %v", when, loggedEntry.When)
+	}
+}
+
+func TestChangeAggregator(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+	agg := NewChangeAggregator(logger, 0)
+
+	for i := 0; i < 3; i++ {
+		agg.LogDataChange("Product", *NewChangeInfo("Product", "Update").AddChange("price", i, i+1))
+	}
+	agg.LogDataChange("Product", *NewChangeInfo("Product", "Create").AddChange("price", nil, 5).AddChange("name", nil, "pen"))
+	agg.LogDataChange("Customer", *NewChangeInfo("Customer", "Update").AddChange("email", "a", "b"))
+	if buf.Len() != 0 {
+		t.Fatalf("Expected nothing to be logged before Flush. Got: %s", buf.String())
+	}
+
+	agg.Close()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one summary per class. Got: %d", len(lines))
+	}
+
+	var loggedEntry struct {
+		Type  string        `json:"type"`
+		Class string        `json:"class"`
+		Data  ChangeSummary `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	summary := loggedEntry.Data
+	if loggedEntry.Type != LogTypeChange || loggedEntry.Class != "Product" || summary.Records != 4 {
+		t.Errorf("Unexpected summary entry: %s", lines[1])
+	}
+	if summary.Ops["Update"] != 3 || summary.Ops["Create"] != 1 || summary.Fields["price"] != 4 || summary.Fields["name"] != 1 {
+		t.Errorf("Unexpected summary counts: %+v", summary)
+	}
+
+	buf.Reset()
+	agg.Flush()
+	if buf.Len() != 0 {
+		t.Errorf("Expected no summaries after the changes were flushed. Got: %s", buf.String())
+	}
+}
+
+type testCreditCard struct {
+	Number string
+}
+
+func (c testCreditCard) LogValue() any {
+	return map[string]any{"last4": c.Number[len(c.Number)-4:]}
+}
+
+type testPanickingValue struct {
+	Secret string
+}
+
+func (testPanickingValue) LogValue() any {
+	panic("cannot redact")
+}
+
+func TestLogMarshaler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	card := testCreditCard{Number: "4111111111111234"}
+	logger.LogActivity("payment", map[string]any{
+		"card":    card,
+		"backup":  &card,
+		"history": []any{card, "cash"},
+		"amount":  100,
+	})
+	if strings.Contains(buf.String(), "4111") {
+		t.Errorf("Expected card numbers to be redacted. Got: %s", buf.String())
+	}
+	for _, part := range []string{`"card":{"last4":"1234"}`, `"backup":{"last4":"1234"}`, `"history":[{"last4":"1234"},"cash"]`, `"amount":100`} {
+		if !strings.Contains(buf.String(), part) {
+			t.Errorf("Expected %s in output. Got: %s", part, buf.String())
+		}
+	}
+
+	buf.Reset()
+	change := NewChangeInfo("Card", "Update").AddChange("card", nil, card).WithSnapshots(nil, map[string]any{"card": &card})
+	logger.LogDataChange("card updated", *change)
+	if strings.Contains(buf.String(), "4111") || strings.Count(buf.String(), `{"last4":"1234"}`) != 2 {
+		t.Errorf("Expected card numbers in changes to be redacted. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	var deep any = card
+	for i := 0; i < 2*maxLogValueDepth; i++ {
+		deep = []any{deep}
+	}
+	logger.LogActivity("deep value", deep)
+	if strings.Contains(buf.String(), "4111") || !strings.Contains(buf.String(), RedactedArg) {
+		t.Errorf("Expected values beyond the maximum depth to be redacted. Got: %s", buf.String())
+	}
+
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+	buf.Reset()
+	logger.LogActivity("panicking value", map[string]any{"value": testPanickingValue{Secret: "s3cr3t"}})
+	if !strings.Contains(buf.String(), `"data":null`) || strings.Contains(buf.String()+lastResort.String(), "s3cr3t") {
+		t.Errorf("Expected Data to be dropped when LogValue panics. Got: %s, %s", buf.String(), lastResort.String())
+	}
+}
+
+func TestMaxLineBytes(t *testing.T) {
+	var buf bytes.Buffer
+	const maxLineBytes = 400
+	logger := NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, JSONEncoder{MaxLineBytes: maxLineBytes})).WithWho("john")
+
+	logger.LogActivity("small entry", map[string]any{"id": 1})
+	if strings.Contains(buf.String(), `"truncated"`) {
+		t.Errorf("Expected an entry under the cap to be unchanged. Got: %s", buf.String())
+	}
+
+	checkLine := func(want ...string) {
+		t.Helper()
+		line := strings.TrimSuffix(buf.String(), "\n")
+		if len(line) > maxLineBytes {
+			t.Errorf("Expected at most %d bytes. Got: %d", maxLineBytes, len(line))
+		}
+		var loggedEntry LogEntry
+		if err := json.Unmarshal([]byte(line), &loggedEntry); err != nil {
+			t.Fatalf("Expected valid JSON: %v", err)
+		}
+		if !loggedEntry.Truncated || loggedEntry.Who != "john" {
+			t.Errorf("Expected a truncated entry keeping top-level fields. Got: %s", line)
+		}
+		for _, part := range want {
+			if !strings.Contains(line, part) {
+				t.Errorf("Expected %s in output. Got: %s", part, line)
+			}
+		}
+	}
+
+	buf.Reset()
+	logger.LogActivity("large data", map[string]any{"id": 1, "body": strings.Repeat("x", 1000)})
+	checkLine(`"data":{"body":"...(truncated)","id":1}`)
+
+	buf.Reset()
+	logger.LogActivity(strings.Repeat("m", 1000), strings.Repeat("y", 1000))
+	checkLine(`"data":"...(truncated)"`, `...(truncated)","data"`)
+}
+
+func TestLogStartup(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).Debug0()
+
+	logger.LogStartup(BuildInfo{Version: "v1.4.2", Commit: "abc123"})
+
+	var loggedEntry struct {
+		Pri  string    `json:"pri"`
+		Msg  string    `json:"msg"`
+		Data BuildInfo `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loggedEntry.Pri != LogPriorityInfo || loggedEntry.Msg != "testApp version v1.4.2 started" {
+		t.Errorf("Unexpected startup entry: %s", buf.String())
+	}
+	info := loggedEntry.Data
+	if info.Commit != "abc123" || info.GoVersion != runtime.Version() || info.StartTime.IsZero() {
+		t.Errorf("Unexpected build info: %+v", info)
+	}
+}
+
+func TestShardingWriter(t *testing.T) {
+	shards := map[string][]*flushCloseWriter{}
+	factory := func(key string) (io.Writer, error) {
+		w := &flushCloseWriter{}
+		shards[key] = append(shards[key], w)
+		return w, nil
+	}
+	writer := NewShardingWriter(func(e LogEntry) string { return e.Who }, factory, WithMaxOpenShards(2))
+	logger := NewLogger(NewLoggerContext(Info), "testApp", writer)
+
+	logger.WithWho("tenantA").LogActivity("a1", nil)
+	logger.WithWho("tenantB").LogActivity("b1", nil)
+	logger.WithWho("tenantA").LogActivity("a2", nil)
+	if len(shards["tenantA"]) != 1 || !strings.Contains(shards["tenantA"][0].String(), "a2") {
+		t.Errorf("Expected the tenantA writer to be reused")
+	}
+	if strings.Contains(shards["tenantA"][0].String(), "b1") {
+		t.Errorf("Expected entries of tenantB not to reach tenantA")
+	}
+
+	// tenantB is the least recently used shard and is evicted.
+	logger.WithWho("tenantC").LogActivity("c1", nil)
+	if !shards["tenantB"][0].closed || shards["tenantA"][0].closed {
+		t.Errorf("Expected the least recently used shard to be closed")
+	}
+	logger.WithWho("tenantB").LogActivity("b2", nil)
+	if len(shards["tenantB"]) != 2 || !strings.Contains(shards["tenantB"][1].String(), "b2") {
+		t.Errorf("Expected an evicted shard to be created again")
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !shards["tenantB"][1].closed || !shards["tenantC"][0].closed {
+		t.Errorf("Expected Close to close all open shards")
+	}
+
+	idle := NewShardingWriter(func(e LogEntry) string { return e.Who }, factory, WithShardIdleTimeout(time.Millisecond))
+	logger = NewLogger(NewLoggerContext(Info), "testApp", idle)
+	logger.WithWho("tenantD").LogActivity("d1", nil)
+	time.Sleep(5 * time.Millisecond)
+	logger.WithWho("tenantE").LogActivity("e1", nil)
+	if !shards["tenantD"][0].closed || shards["tenantE"][0].closed {
+		t.Errorf("Expected the idle shard to be closed")
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+	worker := logger.WithModule("worker")
+
+	logger.Pause()
+	worker.LogActivity("suppressed info", nil)
+	worker.Err().LogActivity("suppressed error", nil)
+	worker.Crit().LogActivity("critical entry", nil)
+	logger.Resume()
+	logger.Resume()
+	worker.LogActivity("info after resume", nil)
+
+	out := buf.String()
+	if strings.Contains(out, "suppressed") {
+		t.Errorf("Expected entries below Crit to be suppressed while paused. Got: %s", out)
+	}
+	for _, msg := range []string{"logging paused", "critical entry", "logging resumed", "info after resume"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("Expected %q in output. Got: %s", msg, out)
+		}
+	}
+	if strings.Count(out, "logging resumed") != 1 {
+		t.Errorf("Expected a single resume entry. Got: %s", out)
+	}
+
+	buf.Reset()
+	worker.PauseBelow(Warn)
+	logger.Warn().LogActivity("warning while paused", nil)
+	logger.LogActivity("info while paused", nil)
+	logger.Resume()
+	if !strings.Contains(buf.String(), "warning while paused") || strings.Contains(buf.String(), "info while paused") {
+		t.Errorf("Expected the pause priority to be configurable. Got: %s", buf.String())
+	}
+
+	// The markers are written even when Info entries are filtered out.
+	buf.Reset()
+	quiet := NewLogger(NewLoggerContext(Err), "testApp", &buf)
+	quiet.Pause()
+	quiet.Resume()
+	if !strings.Contains(buf.String(), "logging paused") || !strings.Contains(buf.String(), "logging resumed") {
+		t.Errorf("Expected the markers above the minimum priority. Got: %s", buf.String())
+	}
+
+	// Concurrent calls log one marker per transition.
+	buf.Reset()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.PauseBelow(Warn)
+		}()
+	}
+	wg.Wait()
+	logger.Resume()
+	if strings.Count(buf.String(), "logging paused") != 1 {
+		t.Errorf("Expected a single pause entry. Got: %s", buf.String())
+	}
+}
+
+func TestLogQuery(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	logger.LogQuery("UPDATE users SET email = $1 WHERE id = $2", []any{"john@example.com", 42}, 15*time.Millisecond, 1, nil)
+	var loggedEntry struct {
+		Pri    string    `json:"pri"`
+		Status int       `json:"status"`
+		Data   QueryInfo `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	query := loggedEntry.Data
+	if loggedEntry.Pri != LogPriorityInfo || query.DurationMs != 15 || query.RowsAffected != 1 || query.Args[0] != "john@example.com" {
+		t.Errorf("Unexpected query entry: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithQueryArgRedaction(true).LogQuery("SELECT * FROM users WHERE email = $1", []any{"john@example.com"}, time.Millisecond, 0, errors.New("connection reset"))
+	if strings.Contains(buf.String(), "john@example.com") || !strings.Contains(buf.String(), `"args":["[redacted]"]`) {
+		t.Errorf("Expected query arguments to be redacted. Got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"pri":"Err"`) || !strings.Contains(buf.String(), `"error":"connection reset"`) {
+		t.Errorf("Expected a failed query to be logged as an error. Got: %s", buf.String())
+	}
+}
+
+func TestWithBaggage(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).
+		WithBaggage(map[string]string{"tenant_id": "t1"}).
+		WithBaggage(map[string]string{"plan": "gold"})
+
+	logger.LogActivity("with baggage", map[string]any{"order": 7})
+	if !strings.Contains(buf.String(), `"baggage":{"plan":"gold","tenant_id":"t1"}`) || !strings.Contains(buf.String(), `"data":{"order":7}`) {
+		t.Errorf("Expected baggage in its own field and Data unchanged. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.LogDataChange("change with baggage", *NewChangeInfo("User", "update").AddChange("name", "a", "b"))
+	if !strings.Contains(buf.String(), `"data":{"changes":[{"field":"name","new_value":"b","old_value":"a"}],"entity":"User","op":"update"}`) {
+		t.Errorf("Expected the Change schema of Data to be kept. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.ClearBaggage().LogActivity("cleared", nil)
+	if strings.Contains(buf.String(), "baggage") {
+		t.Errorf("Expected baggage to be cleared. Got: %s", buf.String())
+	}
+}
+
+func TestWithValidationPolicy(t *testing.T) {
+	lctx := NewLoggerContext(Info)
+	collector := NewValidationCollector()
+	lctx.SetValidationCollector(collector)
+	var primary, fallback bytes.Buffer
+	logger := NewLoggerWithFallback(lctx, "testApp", NewFallbackWriter(&primary, &fallback))
+
+	// Without a policy, all failures are hard.
+	logger.WithActorType("admin").LogActivity("hard failure", nil)
+	if primary.Len() != 0 || len(collector.Failures()) != 1 {
+		t.Fatalf("Expected the entry to fail validation. Got: %s", primary.String())
+	}
+
+	logger = logger.WithValidationPolicy(ValidationPolicy{
+		"Who": func(e *LogEntry) { e.Who = "unknown" },
+	})
+	logger.WithActorType("admin").LogActivity("soft failure", nil)
+	if !strings.Contains(primary.String(), `"who":"unknown"`) {
+		t.Errorf("Expected the entry to be fixed and logged. Got: %s", primary.String())
+	}
+	if len(collector.Failures()) != 1 {
+		t.Errorf("Expected no failure to be recorded for a fixed entry. Got: %d", len(collector.Failures()))
+	}
+
+	// A hard failure alongside a soft one is not fixed.
+	primary.Reset()
+	logger.WithActorType("admin").WithSubjectType("user").LogActivity("mixed failures", nil)
+	if primary.Len() != 0 || !strings.Contains(fallback.String(), "mixed failures") {
+		t.Errorf("Expected the entry to be handled as invalid. Got: %s", primary.String())
+	}
+
+	// A panicking fix leaves the entry invalid.
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+	primary.Reset()
+	logger.WithValidationPolicy(ValidationPolicy{
+		"Who": func(e *LogEntry) { panic("boom") },
+	}).WithActorType("admin").LogActivity("panicking fix", nil)
+	if primary.Len() != 0 || !strings.Contains(fallback.String(), "panicking fix") || !strings.Contains(lastResort.String(), "panic in validation policy: boom") {
+		t.Errorf("Expected the panic to be reported and the entry handled as invalid. Got: %s, %s", primary.String(), lastResort.String())
+	}
+}
+
+func TestLogMetric(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	logger.LogMetric("orders_placed", 1, map[string]string{"region": "eu"})
+
+	var loggedEntry struct {
+		Type string     `json:"type"`
+		Pri  string     `json:"pri"`
+		Msg  string     `json:"msg"`
+		Data MetricInfo `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loggedEntry.Type != LogTypeMetric || loggedEntry.Pri != LogPriorityInfo || loggedEntry.Msg != "orders_placed" {
+		t.Errorf("Unexpected metric entry: %s", buf.String())
+	}
+	if loggedEntry.Data.Value != 1 || loggedEntry.Data.Labels["region"] != "eu" {
+		t.Errorf("Unexpected metric data: %+v", loggedEntry.Data)
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil || entry.Type != Metric {
+		t.Errorf("Expected the metric type to be read back. Got: %v, %v", entry.Type, err)
+	}
+}
+
+func TestEmptyData(t *testing.T) {
+	var nilMap map[string]any
+	tests := []struct {
+		name string
+		data any
+		want map[EmptyDataMode]string
+	}{
+		{"nil", nil, map[EmptyDataMode]string{EmptyDataAsIs: `"data":null`, EmptyDataOmit: "", EmptyDataObject: `"data":{}`}},
+		{"nil map", nilMap, map[EmptyDataMode]string{EmptyDataAsIs: `"data":null`, EmptyDataOmit: "", EmptyDataObject: `"data":{}`}},
+		{"empty struct", struct{}{}, map[EmptyDataMode]string{EmptyDataAsIs: `"data":{}`, EmptyDataOmit: "", EmptyDataObject: `"data":{}`}},
+		{"empty map", map[string]any{}, map[EmptyDataMode]string{EmptyDataAsIs: `"data":{}`, EmptyDataOmit: "", EmptyDataObject: `"data":{}`}},
+		{"populated", map[string]any{"id": 1}, map[EmptyDataMode]string{EmptyDataAsIs: `"data":{"id":1}`, EmptyDataOmit: `"data":{"id":1}`, EmptyDataObject: `"data":{"id":1}`}},
+	}
+	for _, tt := range tests {
+		for mode, want := range tt.want {
+			var buf bytes.Buffer
+			NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, JSONEncoder{EmptyData: mode})).LogActivity("entry", tt.data)
+
+			if want == "" {
+				if strings.Contains(buf.String(), `"data"`) {
+					t.Errorf("%s, mode %d: expected data to be omitted. Got: %s", tt.name, mode, buf.String())
+				}
+			} else if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s, mode %d: expected %s. Got: %s", tt.name, mode, want, buf.String())
+			}
+			var loggedEntry LogEntry
+			if err := json.Unmarshal(buf.Bytes(), &loggedEntry); err != nil {
+				t.Errorf("%s, mode %d: invalid JSON: %v", tt.name, mode, err)
+			}
+		}
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	var tertiary bytes.Buffer
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+
+	chain := NewFallbackChain(&FailWriter{}, &FailWriter{}, &tertiary)
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "testApp", chain)
+
+	logger.LogActivity("reaches tertiary", nil)
+	if !strings.Contains(tertiary.String(), "reaches tertiary") {
+		t.Errorf("Expected the entry to reach the third writer. Got: %s", tertiary.String())
+	}
+
+	// Invalid entries skip the primary writer but use the rest of the chain.
+	logger.WithActorType("admin").LogActivity("invalid entry", nil)
+	if !strings.Contains(tertiary.String(), "invalid entry") {
+		t.Errorf("Expected the invalid entry to go through the chain. Got: %s", tertiary.String())
+	}
+
+	failing := NewFallbackChain(&FailWriter{}, &FailWriter{}, &FailWriter{})
+	NewLoggerWithFallback(NewLoggerContext(Info), "testApp", failing).LogActivity("lost entry", nil)
+	for _, level := range []string{"writer 1:", "writer 2:", "writer 3:"} {
+		if !strings.Contains(lastResort.String(), level) {
+			t.Errorf("Expected the error of %s in the last resort output. Got: %s", level, lastResort.String())
+		}
+	}
+}
+
+func TestMultiWriterSchemaMigration(t *testing.T) {
+	var oldSink, newSink bytes.Buffer
+	encodeOldSchema := func(entry LogEntry) ([]byte, error) {
+		return json.Marshal(map[string]any{"application": entry.App, "message": entry.Msg, "level": entry.Pri.String()})
+	}
+	writer := NewMultiWriter(
+		NewEncodedWriter(&oldSink, EncoderFunc(encodeOldSchema)),
+		NewEncodedWriter(&newSink, JSONEncoder{}),
+	)
+	NewLogger(NewLoggerContext(Info), "testApp", writer).LogActivity("migrated", nil)
+
+	if oldSink.String() != `{"application":"testApp","level":"Info","message":"migrated"}` {
+		t.Errorf("Unexpected old schema output: %s", oldSink.String())
+	}
+	var loggedEntry LogEntry
+	if err := json.Unmarshal(newSink.Bytes(), &loggedEntry); err != nil || loggedEntry.Msg != "migrated" {
+		t.Errorf("Unexpected new schema output: %s", newSink.String())
+	}
+}
+
+func TestLogFlagEvaluation(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).WithWho("john")
+
+	logger.LogFlagEvaluation("new-checkout", "on", "targeting_match")
+
+	if !strings.Contains(buf.String(), `"who":"john"`) || !strings.Contains(buf.String(), `"msg":"flag new-checkout evaluated to on"`) {
+		t.Errorf("Unexpected flag evaluation entry: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"data":{"flag":"new-checkout","reason":"targeting_match","variant":"on"}`) {
+		t.Errorf("Unexpected flag evaluation data: %s", buf.String())
+	}
+}
+
+func TestEntryFraming(t *testing.T) {
+	for _, framing := range []Framing{NewlineFraming, SeparatorFraming(""), SeparatorFraming("\x1e"), LengthPrefixFraming{}} {
+		var buf bytes.Buffer
+		logger := NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, JSONEncoder{Framing: framing}))
+		logger.LogActivity("first", nil)
+		logger.LogActivity("second\nline", map[string]any{"sep": "\x1e"})
+
+		reader := NewEntryReader(&buf, framing)
+		var msgs []string
+		for {
+			entry, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error with framing %q: %v", framing, err)
+			}
+			msgs = append(msgs, entry.Msg)
+		}
+		if len(msgs) != 2 || msgs[0] != "first" || msgs[1] != "second\nline" {
+			t.Errorf("Unexpected entries with framing %q: %q", framing, msgs)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(LengthPrefixFraming{}.Frame([]byte(`{"msg":"cut"}`))[:8])
+	if _, err := NewEntryReader(&buf, LengthPrefixFraming{}).Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a truncated entry. Got: %v", err)
+	}
+}
+
+func TestRetryLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+	retrier := NewRetryLogger(logger, 3, time.Millisecond)
+
+	calls := 0
+	err := retrier.Do(context.Background(), "publish", func() error {
+		if calls++; calls < 2 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var entries []LogEntry
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry LogEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries. Got: %d", len(entries))
+	}
+	if entries[0].Pri != Warn || entries[0].Status != Failure || entries[0].Error != "broker unavailable" {
+		t.Errorf("Unexpected failed attempt entry: %+v", entries[0])
+	}
+	if entries[1].Status != Success || entries[1].Msg != "publish succeeded on attempt 2" {
+		t.Errorf("Unexpected outcome entry: %+v", entries[1])
+	}
+	first := entries[0].Data.(map[string]any)
+	last := entries[1].Data.(map[string]any)
+	if first["retry_id"] == "" || first["retry_id"] != last["retry_id"] || first["next_delay_ms"] != float64(1) {
+		t.Errorf("Unexpected retry data: %v, %v", first, last)
+	}
+
+	buf.Reset()
+	err = retrier.Do(context.Background(), "publish", func() error { return errors.New("broker unavailable") })
+	if err == nil || !strings.Contains(buf.String(), `"msg":"publish failed after 3 attempts"`) || !strings.Contains(buf.String(), `"pri":"Err"`) {
+		t.Errorf("Expected the exhausted retries to be logged with priority Err. Got: %s", buf.String())
+	}
+
+	errUnavailable := errors.New("broker unavailable")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = NewRetryLogger(logger, 3, time.Hour).Do(ctx, "publish", func() error { return errUnavailable })
+	if !errors.Is(err, errUnavailable) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the last error and the cancellation. Got: %v", err)
+	}
+}
+
+func TestDisableDebugCapture(t *testing.T) {
+	var buf bytes.Buffer
+	lctx := NewLoggerContext(Debug0)
+	lctx.SetDebugMode(true)
+	logger := NewLogger(lctx, "testApp", &buf)
+
+	DisableDebugCapture()
+	defer EnableDebugCapture()
+	logger.LogDebug("no capture", nil)
+	if !strings.Contains(buf.String(), `"file":""`) || !strings.Contains(buf.String(), `"stackTrace":""`) {
+		t.Errorf("Expected empty debug info with capture disabled. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	EnableDebugCapture()
+	logger.LogDebug("capture", nil)
+	if !strings.Contains(buf.String(), "TestDisableDebugCapture") {
+		t.Errorf("Expected the caller in the debug info with capture enabled. Got: %s", buf.String())
+	}
+}
+
+func TestLogKV(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	logger.LogKV("cache miss", "key", "user:42", "ttl", 30)
+	if !strings.Contains(buf.String(), `"data":{"key":"user:42","ttl":30}`) {
+		t.Errorf("Unexpected LogKV data: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.LogKV("bad pairs", 7, "x", 1, "dangling")
+	if !strings.Contains(buf.String(), `"data":{"!BADKEY":7,"!BADKEY2":"dangling","x":1}`) {
+		t.Errorf("Expected invalid keys to be reported under !BADKEY. Got: %s", buf.String())
+	}
+}
+
+type hangingWriter struct {
+	bytes.Buffer
+	release chan struct{}
+}
+
+func (w *hangingWriter) Flush() error {
+	<-w.release
+	return nil
+}
+
+func TestCloseWithTimeout(t *testing.T) {
+	closer, hanging := &flushCloseWriter{}, &hangingWriter{release: make(chan struct{})}
+	defer close(hanging.release)
+	logger := NewLogger(NewLoggerContext(Info), "testApp", NewMultiWriter(NewEncodedWriter(closer, JSONEncoder{}), hanging))
+
+	start := time.Now()
+	err := logger.CloseWithTimeout(50 * time.Millisecond)
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("Expected CloseWithTimeout to give up after the timeout")
+	}
+	if err == nil || !strings.Contains(err.Error(), "writer 1 (*logharbour.hangingWriter) did not finish") {
+		t.Errorf("Expected the hanging writer to be reported. Got: %v", err)
+	}
+	if !closer.flushed || !closer.closed {
+		t.Errorf("Expected the other writer to be flushed and closed")
+	}
+
+	if err := NewLogger(NewLoggerContext(Info), "testApp", &flushCloseWriter{}).CloseWithTimeout(time.Second); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Shared writers are closed once, the standard streams are left open, and closing
+	// again, as after FlushOnSignal, is not an error.
+	shared := &flushCloseWriter{}
+	file, err := os.Create(t.TempDir() + "/app.log")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	logger = NewLoggerWithFallback(NewLoggerContext(Info), "testApp",
+		NewFallbackWriter(NewMultiWriter(shared, file, os.Stderr), NewEncodedWriter(shared, ConsoleEncoder{})))
+	for i := 0; i < 2; i++ {
+		if err := logger.CloseWithTimeout(time.Second); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if shared.closes != 2 {
+		t.Errorf("Expected the shared writer to be closed once per call. Got: %d", shared.closes)
+	}
+	if _, err := os.Stderr.Write(nil); err != nil {
+		t.Errorf("Expected stderr to be left open. Got: %v", err)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var gotRequestID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRequestID = r.Header.Get(RequestIDHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	base := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+	transport := Transport(base, nil)
+	transport.LogHeaders = true
+	transport.RedactHeaders = append(transport.RedactHeaders, "X-Vault-Token")
+	client := &http.Client{Transport: transport}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/orders?access_token=tok123&id=7", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("X-Vault-Token", "vault")
+	req.Header.Set("Accept", "application/json")
+	req = req.WithContext(NewContext(req.Context(), base.WithTraceID("trace-1")))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotRequestID != "trace-1" {
+		t.Errorf("Expected the trace ID to be propagated. Got: %q", gotRequestID)
+	}
+	if req.Header.Get(RequestIDHeader) != "" {
+		t.Errorf("Expected the caller's request to be left unchanged")
+	}
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.TraceID != "trace-1" || entry.Status != Failure || entry.Pri != Err || entry.Op != "GET "+req.URL.Host {
+		t.Errorf("Unexpected outbound request entry: %+v", entry)
+	}
+	for _, secret := range []string{"secret", `"key"`, "vault", "tok123"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("Expected %s to be redacted. Got: %s", secret, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), `/orders?access_token=[redacted]&id=[redacted]"`) {
+		t.Errorf("Expected the query parameters to be redacted. Got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"Accept":["application/json"]`) || !strings.Contains(buf.String(), `"status_code":502`) {
+		t.Errorf("Expected headers and status code in the entry. Got: %s", buf.String())
+	}
+
+	// By default, headers are not logged.
+	buf.Reset()
+	resp, err = (&http.Client{Transport: Transport(base, nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if strings.Contains(buf.String(), `"headers"`) {
+		t.Errorf("Expected no headers by default. Got: %s", buf.String())
+	}
+}
+
+func TestLogSelfConfig(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewFallbackWriter(NewEncodedWriter(&buf, JSONEncoder{}), &bytes.Buffer{})
+	logger := NewLoggerWithFallback(NewLoggerContext(Warn), "testApp", writer).WithModule("billing").WithTraceSampler(NewTraceSampler(1))
+
+	logger.LogSelfConfig()
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected the configuration to be logged despite the Warn minimum. Got: %s", buf.String())
+	}
+	if entry.Pri != Warn {
+		t.Errorf("Expected the entry to be raised to the minimum priority. Got: %v", entry.Pri)
+	}
+	config := entry.Data.(map[string]any)
+	if config["min_priority"] != "Warn" || config["module"] != "billing" || config["sample_rate"] != float64(1) {
+		t.Errorf("Unexpected configuration: %v", config)
+	}
+	if config["writer"] != "*logharbour.FallbackWriter(*logharbour.EncodedWriter[logharbour.JSONEncoder](*bytes.Buffer), *bytes.Buffer)" {
+		t.Errorf("Unexpected writer description: %v", config["writer"])
+	}
+}
+
+func TestTenantWriter(t *testing.T) {
+	sinks := map[string]*bytes.Buffer{"acme": {}, "globex": {}}
+	factory := func(tenant string) (io.Writer, error) {
+		if sink, ok := sinks[tenant]; ok {
+			return sink, nil
+		}
+		return nil, fmt.Errorf("unknown tenant %q", tenant)
+	}
+	var untagged bytes.Buffer
+	writer := NewFallbackWriter(NewTenantWriter(factory), &untagged)
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "testApp", writer)
+
+	logger.WithTenant("acme").LogActivity("acme entry", nil)
+	logger.WithTenant("globex").LogActivity("globex entry", nil)
+	logger.LogActivity("untagged entry", nil)
+	logger.WithTenant("initech").LogActivity("unknown tenant entry", nil)
+
+	if !strings.Contains(sinks["acme"].String(), `"tenant":"acme"`) || strings.Contains(sinks["acme"].String(), "globex") {
+		t.Errorf("Unexpected acme sink: %s", sinks["acme"].String())
+	}
+	if !strings.Contains(sinks["globex"].String(), "globex entry") || strings.Contains(sinks["globex"].String(), "acme") {
+		t.Errorf("Unexpected globex sink: %s", sinks["globex"].String())
+	}
+	if !strings.Contains(untagged.String(), "untagged entry") || !strings.Contains(untagged.String(), "unknown tenant entry") {
+		t.Errorf("Expected untagged and unknown tenant entries in the fallback. Got: %s", untagged.String())
+	}
+	if err := NewTenantWriter(factory).writeEntry(LogEntry{Msg: "x"}); err != ErrMissingTenant {
+		t.Errorf("Expected ErrMissingTenant. Got: %v", err)
+	}
+}
+
+func TestCausedBy(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	id := logger.LogActivityWithID("order placed", nil)
+	changeID := logger.WithCausedBy(id).LogDataChangeWithID("stock reserved", *NewChangeInfo("Stock", "Update").AddChange("qty", 5, 4))
+	logger.WithCausedBy(changeID).LogActivity("shipment scheduled", nil)
+
+	if len(id) != 32 || changeID == id {
+		t.Fatalf("Expected distinct generated IDs. Got: %q, %q", id, changeID)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 entries. Got: %s", buf.String())
+	}
+	var entries [3]LogEntry
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &entries[i]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if entries[0].ID != id || entries[0].CausedBy != "" {
+		t.Errorf("Unexpected cause entry: %+v", entries[0])
+	}
+	if entries[1].ID != changeID || entries[1].CausedBy != id || entries[1].Type != Change {
+		t.Errorf("Unexpected change entry: %+v", entries[1])
+	}
+	if entries[2].ID != "" || entries[2].CausedBy != changeID {
+		t.Errorf("Unexpected effect entry: %+v", entries[2])
+	}
+}
+
+func TestSequencing(t *testing.T) {
+	var buf bytes.Buffer
+	lctx := NewLoggerContext(Info)
+	logger := NewLogger(lctx, "testApp", &buf)
+	logger.LogActivity("unsequenced", nil)
+	if strings.Contains(buf.String(), `"seq"`) {
+		t.Errorf("Expected no sequence number by default. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	lctx.SetSequencing(true)
+	logger.LogActivity("first", nil)
+	logger.WithModule("other").LogActivity("second", nil)
+	if !strings.Contains(buf.String(), `"seq":1`) || !strings.Contains(buf.String(), `"seq":2`) {
+		t.Errorf("Expected sequence numbers shared by clones. Got: %s", buf.String())
+	}
+
+	var first, second LogEntry
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.SeqSource == "" || first.SeqSource != second.SeqSource {
+		t.Errorf("Expected the sequence ID shared by clones. Got: %q and %q", first.SeqSource, second.SeqSource)
+	}
+
+	buf.Reset()
+	other := NewLoggerContext(Info)
+	other.SetSequencing(true)
+	NewLogger(other, "testApp", &buf).LogActivity("restarted", nil)
+	var restarted LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &restarted); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if restarted.Seq != 1 || restarted.SeqSource == "" || restarted.SeqSource == first.SeqSource {
+		t.Errorf("Expected a new sequence for another context. Got: %s", buf.String())
+	}
+}
+
+func TestReorderer(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// a and b are two runs of the same process, so only SeqSource tells their sequences apart.
+	at := func(source string, seq uint64, offset time.Duration, msg string) LogEntry {
+		return LogEntry{System: "host", App: "app", Seq: seq, SeqSource: source, When: base.Add(offset), Msg: msg}
+	}
+	r := NewReorderer(time.Second)
+	var released []LogEntry
+	for _, entry := range []LogEntry{
+		at("a", 1, 0, "a1"),
+		at("b", 1, 500*time.Millisecond, "b1"),
+		at("a", 3, 200*time.Millisecond, "a3"),
+		at("a", 2, 300*time.Millisecond, "a2"), // the clock of a went backwards
+		at("b", 2, 2*time.Second, "b2"),
+	} {
+		released = append(released, r.Add(entry)...)
+	}
+	var msgs []string
+	for _, entry := range released {
+		msgs = append(msgs, entry.Msg)
+	}
+	if strings.Join(msgs, " ") != "a1 a2 a3 b1" {
+		t.Errorf("Unexpected released entries: %v", msgs)
+	}
+	if rest := r.Flush(); len(rest) != 1 || rest[0].Msg != "b2" {
+		t.Errorf("Expected b2 to be flushed. Got: %v", rest)
+	}
+}
+
+func TestWithErrorPriorityMapper(t *testing.T) {
+	errNotFound := errors.New("not found")
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).WithErrorPriorityMapper(func(err error) LogPriority {
+		var netErr *net.OpError
+		switch {
+		case errors.Is(err, errNotFound):
+			return Info
+		case errors.As(err, &netErr):
+			return Crit
+		default:
+			return Err
+		}
+	})
+
+	for _, tc := range []struct {
+		logger *Logger
+		err    error
+		want   LogPriority
+	}{
+		{logger, fmt.Errorf("loading user: %w", errNotFound), Info},
+		{logger.WithModule("db"), fmt.Errorf("query: %w", &net.OpError{Op: "dial", Err: errors.New("refused")}), Crit},
+		{logger, errors.New("other"), Err},
+		{logger.Warn(), errNotFound, Warn},
+		{logger.WithErrorPriorityMapper(nil), errNotFound, Err},
+	} {
+		buf.Reset()
+		tc.logger.LogResult("operation", tc.err, nil)
+		var entry LogEntry
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if entry.Pri != tc.want {
+			t.Errorf("Expected priority %v for %v. Got: %v", tc.want, tc.err, entry.Pri)
+		}
+	}
+
+	// A panicking mapper is reported and the default priority used.
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+	buf.Reset()
+	logger.WithErrorPriorityMapper(func(error) LogPriority { panic("boom") }).LogResult("operation", errNotFound, map[string]any{"card": "4111111111111111"})
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.Pri != Err {
+		t.Errorf("Expected priority %v for a panicking mapper. Got: %v", Err, entry.Pri)
+	}
+	if !strings.Contains(lastResort.String(), "panic in error priority mapper: boom") {
+		t.Errorf("Expected the panic to be reported. Got: %s", lastResort.String())
+	}
+	if strings.Contains(lastResort.String(), "4111111111111111") {
+		t.Errorf("Expected the report to leave out Data. Got: %s", lastResort.String())
+	}
+}
+
+func TestFallbackWriterWithFaultyWriter(t *testing.T) {
+	var primaryBuf, fallback bytes.Buffer
+	primary := logharbourtest.NewFaultyWriter(&primaryBuf)
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "testApp", NewFallbackWriter(primary, &fallback))
+
+	primary.FailNext(2)
+	for i := 0; i < 3; i++ {
+		logger.LogActivity(fmt.Sprintf("entry %d", i), nil)
+	}
+	if primary.Writes() != 3 || primary.Failures() != 2 {
+		t.Errorf("Expected 3 writes with 2 failures. Got: %d, %d", primary.Writes(), primary.Failures())
+	}
+	if strings.Count(fallback.String(), "\n") != 2 || !strings.Contains(primaryBuf.String(), "entry 2") {
+		t.Errorf("Expected the failed entries in the fallback. Got: %s | %s", fallback.String(), primaryBuf.String())
+	}
+
+	primary.FailRatio(0.5, 1)
+	for i := 0; i < 100; i++ {
+		logger.LogActivity("random", nil)
+	}
+	if f := primary.Failures() - 2; f < 25 || f > 75 {
+		t.Errorf("Expected about half of the writes to fail. Got: %d", f)
+	}
+}
+
+func TestWithRedactor(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+	notDev := func(e LogEntry) bool { return e.Env != "dev" }
+	data := map[string]any{"email": "john@example.com", "order": map[string]any{"phone": "555-0100", "id": 7}}
+
+	base.WithEnv("prod").WithRedactor(RedactDataKeys("email", "phone"), notDev).LogActivity("order placed", data)
+	if strings.Contains(buf.String(), "john@example.com") || strings.Contains(buf.String(), "555-0100") {
+		t.Errorf("Expected personal data to be redacted in prod. Got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"data":{"email":"[redacted]","order":{"id":7,"phone":"[redacted]"}}`) {
+		t.Errorf("Unexpected redacted data: %s", buf.String())
+	}
+	if data["email"] != "john@example.com" {
+		t.Errorf("Expected the caller's data to be left unchanged")
+	}
+
+	buf.Reset()
+	base.WithEnv("dev").WithRedactor(RedactDataKeys("email"), notDev).LogActivity("order placed", data)
+	if !strings.Contains(buf.String(), "john@example.com") {
+		t.Errorf("Expected raw values in dev. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	base.WithEnv("dev").WithRedactor(RedactDataKeys("email"), nil).LogActivity("order placed", data)
+	if strings.Contains(buf.String(), "john@example.com") {
+		t.Errorf("Expected redaction without a predicate. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	panicking := RedactorFunc(func(*LogEntry) { panic("boom") })
+	base.WithRedactor(panicking, nil).LogActivity("order placed", data)
+	if buf.Len() != 0 {
+		t.Errorf("Expected the entry to be dropped when the redactor panics. Got: %s", buf.String())
+	}
+}
+
+func TestLogConfigChange(t *testing.T) {
+	type Config struct {
+		Port     int    `json:"port"`
+		Host     string `json:"host"`
+		DBPass   string `json:"db_pass" logharbour:"sensitive"`
+		Internal string `logharbour:"-"`
+	}
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	oldConfig := Config{Port: 80, Host: "a", DBPass: "old-secret", Internal: "x"}
+	newConfig := Config{Port: 8080, Host: "a", DBPass: "new-secret", Internal: "y"}
+	if err := logger.LogConfigChange(oldConfig, &newConfig); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.Type != Change || entry.Msg != "configuration Config reloaded with 2 changes" {
+		t.Errorf("Unexpected config change entry: %+v", entry)
+	}
+	if strings.Contains(buf.String(), "secret") || strings.Contains(buf.String(), "Internal") {
+		t.Errorf("Expected sensitive and excluded fields to be hidden. Got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `{"field":"port","new_value":8080,"old_value":80}`) ||
+		!strings.Contains(buf.String(), `{"field":"db_pass","new_value":"[redacted]","old_value":"[redacted]"}`) {
+		t.Errorf("Unexpected changes: %s", buf.String())
+	}
+
+	if err := logger.LogConfigChange(nil, newConfig); err == nil {
+		t.Errorf("Expected an error for a nil configuration")
+	}
+
+	// Sensitive fields of nested structs are redacted too.
+	type DB struct {
+		Host     string `json:"host"`
+		Password string `json:"password" logharbour:"sensitive"`
+	}
+	type Nested struct {
+		DB      DB            `json:"db"`
+		Replica *DB           `json:"replica"`
+		Shards  []DB          `json:"shards"`
+		Timeout time.Duration `json:"timeout"`
+	}
+	buf.Reset()
+	oldNested := Nested{DB: DB{"a", "old-secret"}, Shards: []DB{{"s1", "old-secret"}}, Timeout: time.Second}
+	newNested := Nested{DB: DB{"b", "new-secret"}, Replica: &DB{"r", "new-secret"}, Shards: []DB{{"s1", "new-secret"}}, Timeout: time.Minute}
+	if err := logger.LogConfigChange(oldNested, newNested); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("Expected nested sensitive fields to be hidden. Got: %s", buf.String())
+	}
+	for _, change := range []string{
+		`{"field":"db.host","new_value":"b","old_value":"a"}`,
+		`{"field":"db.password","new_value":"[redacted]","old_value":"[redacted]"}`,
+		`{"field":"replica","new_value":"[redacted]","old_value":"[redacted]"}`,
+		`{"field":"shards","new_value":"[redacted]","old_value":"[redacted]"}`,
+		`{"field":"timeout","new_value":60000000000,"old_value":1000000000}`,
+	} {
+		if !strings.Contains(buf.String(), change) {
+			t.Errorf("Expected change %s. Got: %s", change, buf.String())
+		}
+	}
+}
+
+func TestNewTeeLogger(t *testing.T) {
+	var file, console bytes.Buffer
+	logger := NewTeeLogger(NewLoggerContext(Info), "testApp", &file, NewEncodedWriter(&console, ConsoleEncoder{}), Warn)
+
+	logger.LogActivity("routine", nil)
+	logger.Warn().LogActivity("disk almost full", nil)
+	logger.WithPriority(Debug0).LogActivity("below the logger level", nil)
+
+	if strings.Count(file.String(), "\n") != 2 || !strings.Contains(file.String(), "routine") {
+		t.Errorf("Expected Info and Warn entries in the file. Got: %s", file.String())
+	}
+	if strings.Contains(console.String(), "routine") || !strings.Contains(console.String(), "disk almost full") {
+		t.Errorf("Expected only the Warn entry on the console. Got: %s", console.String())
+	}
+	if describeWriter(logger.writer) != "*logharbour.MultiWriter(*bytes.Buffer, *logharbour.PriorityWriter[Warn](*logharbour.EncodedWriter[logharbour.ConsoleEncoder](*bytes.Buffer)))" {
+		t.Errorf("Unexpected writer description: %s", describeWriter(logger.writer))
+	}
+}
+
+func TestLogAuthz(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf).WithWho("u-17").WithRemoteIP("10.0.0.1")
+
+	logger.LogAuthz("u-17", "read", "invoice/42", false, "owner-only")
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.Pri != Sec || entry.Status != Failure || entry.Who != "u-17" || entry.RemoteIP != "10.0.0.1" {
+		t.Errorf("Unexpected authz entry: %+v", entry)
+	}
+	if entry.Msg != "authz deny u-17 read invoice/42" {
+		t.Errorf("Unexpected authz message: %s", entry.Msg)
+	}
+	if !strings.Contains(buf.String(), `"data":{"action":"read","decision":"deny","policy":"owner-only","resource":"invoice/42","subject":"u-17"}`) {
+		t.Errorf("Unexpected authz data: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.LogAuthz("u-17", "read", "invoice/7", true, "owner-only")
+	if !strings.Contains(buf.String(), `"decision":"allow"`) || !strings.Contains(buf.String(), `"pri":"Sec"`) {
+		t.Errorf("Unexpected allowed authz entry: %s", buf.String())
+	}
+}
+
+func TestWithRequiredFields(t *testing.T) {
+	var primary, fallback bytes.Buffer
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "testApp", NewFallbackWriter(&primary, &fallback))
+	logger, err := logger.WithRequiredFields(Change, "who", "instance")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logger, err = logger.WithRequiredFields(Activity, "op"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := logger.WithRequiredFields(Activity, "nope"); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+
+	logger.WithOp("send").LogActivity("complete activity", nil)
+	logger.WithWho("john").WithInstanceId("42").LogDataChange("complete change", *NewChangeInfo("User", "Update"))
+	logger.WithWho("john").LogDataChange("incomplete change", *NewChangeInfo("User", "Update"))
+	if !strings.Contains(primary.String(), "complete activity") || !strings.Contains(primary.String(), "complete change") {
+		t.Errorf("Expected complete entries to be written. Got: %s", primary.String())
+	}
+	if strings.Contains(primary.String(), "incomplete change") || !strings.Contains(fallback.String(), "incomplete change") {
+		t.Errorf("Expected the incomplete change in the fallback. Got: %s", fallback.String())
+	}
+
+	err = checkRequiredFields(LogEntry{Type: Change, Who: "john"}, logger.requiredFields[Change])
+	if err == nil || err.Error() != "log entry of type C is missing required fields: instance" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	logger.ClearRequiredFields().LogActivity("no op needed", nil)
+	if !strings.Contains(primary.String(), "no op needed") {
+		t.Errorf("Expected ClearRequiredFields to drop the requirements")
+	}
+}
+
+func TestDictionaryEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, NewDictionaryEncoder(3, nil)))
+	for i := 0; i < 4; i++ {
+		logger.LogActivity("request", map[string]any{"request_id": i, "nested": map[string]any{"duration_ms": 12, "~tilde": true}})
+	}
+	logger.LogActivity("no data", nil)
+
+	if strings.Contains(buf.String(), `"request_id":`) || strings.Count(buf.String(), `{"lh_dict":`) != 2 {
+		t.Errorf("Expected coded keys and two dictionary records. Got: %s", buf.String())
+	}
+	reader := NewEntryReader(&buf, nil)
+	for i := 0; i < 4; i++ {
+		entry, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data := entry.Data.(map[string]any)
+		nested := data["nested"].(map[string]any)
+		if data["request_id"] != float64(i) || nested["duration_ms"] != float64(12) || nested["~tilde"] != true {
+			t.Errorf("Unexpected decoded data: %v", data)
+		}
+	}
+	if entry, err := reader.Next(); err != nil || entry.Msg != "no data" || entry.Data != nil {
+		t.Errorf("Unexpected last entry: %+v, %v", entry, err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF. Got: %v", err)
+	}
+
+	// Keys added for an entry that fails to encode are written with the next entry using them.
+	de := NewDictionaryEncoder(100, nil)
+	de.json.Marshal = func(v any) ([]byte, error) { return nil, errors.New("marshal failed") }
+	if _, err := de.Encode(LogEntry{Data: map[string]any{"order_id": 1}}); err == nil {
+		t.Fatalf("Expected the encoding error")
+	}
+	de.json.Marshal = nil
+	out, err := de.Encode(LogEntry{Data: map[string]any{"order_id": 2}})
+	if err != nil || !strings.HasPrefix(string(out), `{"lh_dict":{"~0":"order_id"}}`) {
+		t.Errorf("Expected the dictionary record before the entry. Got: %s, %v", out, err)
+	}
+}
+
+func BenchmarkDictionaryEncoder(b *testing.B) {
+	entry := LogEntry{App: "api", Type: Activity, Pri: Info, When: time.Now(), Msg: "request completed", Data: map[string]any{
+		"request_id": "c9f1e2", "duration_ms": 12, "status_code": 200, "response_bytes": 5120, "upstream_service": "billing",
+	}}
+	de := NewDictionaryEncoder(0, nil)
+	var plainSize, dictSize int
+	for i := 0; i < b.N; i++ {
+		plain, _ := JSONEncoder{}.Encode(entry)
+		coded, _ := de.Encode(entry)
+		plainSize += len(plain)
+		dictSize += len(coded)
+	}
+	b.ReportMetric(float64(dictSize)/float64(plainSize), "size-ratio")
+}
+
+func TestChangeInfoAffectedCount(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	logger.LogDataChange("prices updated", *NewChangeInfo("Product", "Update").AddChange("price", 10, 12).WithAffectedCount(250))
+	logger.LogDataChange("price updated", *NewChangeInfo("Product", "Update").AddChange("price", 10, 12))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.Contains(lines[0], `"affected_count":250`) || strings.Contains(lines[1], "affected_count") {
+		t.Errorf("Expected affected_count on the bulk change only. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	agg := NewChangeAggregator(logger, 0)
+	agg.LogDataChange("Product", *NewChangeInfo("Product", "Update").WithAffectedCount(250))
+	agg.LogDataChange("Product", *NewChangeInfo("Product", "Update"))
+	agg.Close()
+	if !strings.Contains(buf.String(), `"affected":251`) || !strings.Contains(buf.String(), `"records":2`) {
+		t.Errorf("Expected the aggregator to sum affected counts. Got: %s", buf.String())
+	}
+}
+
+func TestInterner(t *testing.T) {
+	in := NewInterner(2)
+	a := in.Intern(strings.Repeat("x", 8))
+	b := in.Intern(strings.Repeat("x", 8))
+	if unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Errorf("Expected identical strings to share their storage")
+	}
+	in.Intern("y")
+	c, d := in.Intern(strings.Repeat("z", 8)), in.Intern(strings.Repeat("z", 8))
+	if unsafe.StringData(c) == unsafe.StringData(d) {
+		t.Errorf("Expected a full Interner to return values as they are")
+	}
+
+	logger := NewLogger(NewLoggerContext(Info), "testApp", io.Discard).WithInterner(NewInterner(0))
+	op1 := logger.WithOp("GET " + strings.Repeat("/users", 2)).op
+	op2 := logger.WithModule("api").WithOp("GET " + strings.Repeat("/users", 2)).op
+	if unsafe.StringData(op1) != unsafe.StringData(op2) {
+		t.Errorf("Expected the ops of derived Loggers to be interned")
+	}
+}
+
+// BenchmarkInterner measures the memory retained by per-request Loggers whose op is
+// built for each request from a few distinct routes.
+func BenchmarkInterner(b *testing.B) {
+	routes := []string{"/users", "/orders", "/invoices", "/products"}
+	for _, interned := range []bool{false, true} {
+		b.Run(fmt.Sprintf("interned=%v", interned), func(b *testing.B) {
+			base := NewLogger(NewLoggerContext(Info), "testApp", io.Discard)
+			if interned {
+				base = base.WithInterner(NewInterner(0))
+			}
+			loggers := make([]*Logger, 0, b.N)
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			for i := 0; i < b.N; i++ {
+				loggers = append(loggers, base.WithOp("GET "+routes[i%len(routes)]+"/list?page=size"))
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(b.N), "retained-B/logger")
+			runtime.KeepAlive(loggers)
+		})
+	}
+}
+
+func TestInheritanceTracking(t *testing.T) {
+	var warnings bytes.Buffer
+	SetLastResortWriter(&warnings)
+	defer SetLastResortWriter(nil)
+
+	base := NewLogger(NewLoggerContext(Info), "testApp", io.Discard).WithInheritanceTracking(true)
+	handler := Middleware(base)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		NewLogger(NewLoggerContext(Info), "testApp", io.Discard).LogActivity("context lost", nil)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	NewLogger(NewLoggerContext(Info), "testApp", io.Discard)
+
+	if !inheritanceTrackingBuild {
+		if warnings.Len() != 0 {
+			t.Errorf("Expected no tracking without the logharbourdebug tag. Got: %s", warnings.String())
+		}
+		return
+	}
+	if strings.Count(warnings.String(), "new Logger created while handling request req-1") != 1 {
+		t.Errorf("Expected one warning for the Logger created in the handler. Got: %s", warnings.String())
+	}
+}
+
+func TestJSONEncoderTimePrecision(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	when := time.Date(2024, 1, 2, 15, 4, 5, 123456789, loc)
+	for _, tc := range []struct {
+		precision time.Duration
+		want      string
+	}{
+		{0, `"when":"2024-01-02T15:04:05.123456789+05:30"`},
+		{time.Microsecond, `"when":"2024-01-02T15:04:05.123456+05:30"`},
+		{time.Millisecond, `"when":"2024-01-02T15:04:05.123+05:30"`},
+		{time.Second, `"when":"2024-01-02T15:04:05+05:30"`},
+	} {
+		var buf bytes.Buffer
+		logger := NewLogger(NewLoggerContext(Info), "testApp", NewEncodedWriter(&buf, JSONEncoder{TimePrecision: tc.precision})).WithTimeZone(loc).WithWhen(when)
+		logger.LogActivity("tick", nil)
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("Expected %s with precision %v. Got: %s", tc.want, tc.precision, buf.String())
+		}
+		entry, err := NewEntryReader(&buf, nil).Next()
+		if err != nil || !entry.When.Equal(when.Truncate(tc.precision)) {
+			t.Errorf("Expected the entry to be read back with precision %v. Got: %v, %v", tc.precision, entry.When, err)
+		}
+	}
+}
+
+func TestWithDeadline(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "testApp", &buf)
+
+	logger.WithDeadline(context.Background()).LogActivity("no deadline", nil)
+	if strings.Contains(buf.String(), "deadline_remaining_ms") {
+		t.Errorf("Expected no deadline field without a deadline. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	logger.WithDeadline(ctx).LogActivity("with deadline", nil)
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.DeadlineRemainingMs == nil || *entry.DeadlineRemainingMs <= 50000 || *entry.DeadlineRemainingMs > 60000 {
+		t.Errorf("Expected about a minute left. Got: %v", entry.DeadlineRemainingMs)
+	}
+
+	buf.Reset()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	logger.WithDeadline(expired).LogActivity("late", nil)
+	if !strings.Contains(buf.String(), `"deadline_remaining_ms":-`) {
+		t.Errorf("Expected a negative remaining time past the deadline. Got: %s", buf.String())
+	}
+}
+
+func TestCloudLoggingEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewEncodedWriter(&buf, CloudLoggingEncoder{ProjectID: "my-project"})
+	logger := NewLogger(NewLoggerContext(Info), "billing", writer).WithTraceID("4bf92f3577b34da6a3ce929d0e0e4736")
+
+	logger.Warn().LogActivity("quota low", map[string]any{"remaining": 3})
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if line["severity"] != "WARNING" || line["message"] != "quota low" || line["time"] == nil {
+		t.Errorf("Unexpected special fields: %v", line)
+	}
+	if line[CloudLoggingTraceKey] != "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected trace link: %v", line[CloudLoggingTraceKey])
+	}
+	for _, name := range []string{"msg", "pri", "when", "trace_id"} {
+		if _, ok := line[name]; ok {
+			t.Errorf("Expected %q to be replaced by its Cloud Logging field", name)
+		}
+	}
+	if line["app"] != "billing" || line["data"].(map[string]any)["remaining"] != float64(3) {
+		t.Errorf("Expected the other fields in the payload. Got: %v", line)
+	}
+	if cloudLoggingSeverity(Debug1) != "DEBUG" || cloudLoggingSeverity(Sec) != "ALERT" {
+		t.Errorf("Unexpected severity mapping")
+	}
+}
+
+func TestWithUptime(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "uptime", &buf)
+
+	logger.LogActivity("without uptime", nil)
+	if strings.Contains(buf.String(), "uptime_ms") {
+		t.Errorf("Expected no uptime field by default. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithUptime().LogActivity("with uptime", nil)
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.UptimeMs == nil || *entry.UptimeMs < 0 || *entry.UptimeMs > Uptime().Milliseconds() {
+		t.Errorf("Unexpected uptime: %v", entry.UptimeMs)
+	}
+	if ProcessStart().After(time.Now()) {
+		t.Errorf("Expected the process start time in the past")
+	}
+
+	buf.Reset()
+	logger.LogUptime()
+	if !strings.Contains(buf.String(), `"uptime_ms":`) || !strings.Contains(buf.String(), `"started":`) {
+		t.Errorf("Expected LogUptime to record the uptime and start time. Got: %s", buf.String())
+	}
+}
+
+// gatedWriter blocks writes until its gate is closed.
+type gatedWriter struct {
+	flushCloseWriter
+	gate chan struct{}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	return w.flushCloseWriter.Write(p)
+}
+
+func TestSplitWriter(t *testing.T) {
+	durable := &flushCloseWriter{}
+	buffered := &gatedWriter{gate: make(chan struct{})}
+	size := 1
+	w := NewSplitWriter(durable, buffered, SplitWriterConfig{BufferSize: &size})
+	logger := NewLogger(NewLoggerContext(Debug2), "split", w)
+
+	// The first entry blocks the background goroutine, the second fills the queue.
+	logger.LogActivity("first", nil)
+	logger.LogActivity("second", nil)
+	logger.Err().Error(errors.New("boom")).LogActivity("failed", nil)
+	if !strings.Contains(durable.String(), `"msg":"failed"`) {
+		t.Errorf("Expected the error entry written synchronously. Got: %q", durable.String())
+	}
+	if strings.Contains(durable.String(), "first") {
+		t.Errorf("Expected lower priority entries to stay off the durable writer")
+	}
+
+	// Wait until the first entry is taken off the queue, so the queue holds the second.
+	deadline := time.Now().Add(time.Second)
+	for len(w.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	logger.LogActivity("second", nil)
+	logger.LogActivity("dropped", nil)
+	if w.Dropped() == 0 {
+		t.Errorf("Expected entries dropped with a full queue")
+	}
+
+	close(buffered.gate)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(buffered.String(), "first") || !strings.Contains(buffered.String(), "second") {
+		t.Errorf("Expected Flush to drain the queue. Got: %q", buffered.String())
+	}
+	if !durable.flushed || !buffered.flushed {
+		t.Errorf("Expected Flush to flush both writers")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !durable.closed || !buffered.closed {
+		t.Errorf("Expected Close to close both writers")
+	}
+	if err := w.writeEntry(LogEntry{Pri: Crit}); !errors.Is(err, ErrSplitWriterClosed) {
+		t.Errorf("Expected ErrSplitWriterClosed after Close. Got: %v", err)
+	}
+	if got := describeWriter(w); !strings.Contains(got, "SplitWriter[Err]") {
+		t.Errorf("Unexpected description: %s", got)
+	}
+}
+
+func TestSplitWriterCloseRace(t *testing.T) {
+	SetLastResortWriter(io.Discard)
+	defer SetLastResortWriter(nil)
+	for i := 0; i < 20; i++ {
+		w := NewSplitWriter(io.Discard, io.Discard, SplitWriterConfig{})
+		var acks, writes atomic.Int64
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					writes.Add(1)
+					w.writeEntryAck(LogEntry{Pri: Info}, func(error) { acks.Add(1) })
+				}
+			}()
+		}
+		w.Close()
+		wg.Wait()
+		// Every entry is either written or rejected, and acknowledged either way.
+		if acks.Load() != writes.Load() {
+			t.Fatalf("Expected %d acknowledgments. Got: %d", writes.Load(), acks.Load())
+		}
+	}
+}
+
+func TestRotatingFileWriter(t *testing.T) {
+	path := t.TempDir() + "/app.log"
+	maxBytes, maxBackups := int64(200), 2
+	w, err := NewRotatingFileWriter(path, RotatingFileConfig{MaxBytes: &maxBytes, MaxBackups: &maxBackups})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	logger := NewLogger(NewLoggerContext(Info), "testApp", w)
+	for i := 0; i < 5; i++ {
+		logger.LogActivity(fmt.Sprintf("entry %d", i), nil)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	current, _ := os.ReadFile(path)
+	if strings.Count(string(current), "\n") != 1 || !strings.Contains(string(current), "entry 4") {
+		t.Errorf("Expected the last entry alone in the file. Got: %s", current)
+	}
+	backups, _ := w.backups()
+	if len(backups) != maxBackups {
+		t.Fatalf("Expected %d rotated files. Got: %v", maxBackups, backups)
+	}
+	if oldest, _ := os.ReadFile(backups[0]); !strings.Contains(string(oldest), "entry 2") {
+		t.Errorf("Expected the oldest rotated files to be deleted. Got: %s", oldest)
+	}
+	if _, err := w.Write([]byte("{}\n")); !errors.Is(err, ErrRotatingFileWriterClosed) {
+		t.Errorf("Expected ErrRotatingFileWriterClosed after Close. Got: %v", err)
+	}
+}
+
+func TestRotatingFileWriterCompression(t *testing.T) {
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+	path := t.TempDir() + "/app.log"
+	maxBytes, maxBackups, compressions := int64(200), 3, 2
+	w, err := NewRotatingFileWriter(path, RotatingFileConfig{MaxBytes: &maxBytes, MaxBackups: &maxBackups, Compress: true, MaxCompressions: &compressions})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	logger := NewLogger(NewLoggerContext(Info), "testApp", w)
+	for i := 0; i < 20; i++ {
+		logger.LogActivity(fmt.Sprintf("entry %d", i), nil)
+	}
+	// Close waits for the compressions in progress.
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lastResort.Len() > 0 {
+		t.Errorf("Unexpected errors: %s", lastResort.String())
+	}
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != maxBackups {
+		t.Fatalf("Expected %d rotated files. Got: %v", maxBackups, matches)
+	}
+	for i, name := range matches {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("Expected %s to be compressed", name)
+			continue
+		}
+		file, _ := os.Open(name)
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		content, _ := io.ReadAll(zr)
+		file.Close()
+		if want := fmt.Sprintf("entry %d", 16+i); !strings.Contains(string(content), want) {
+			t.Errorf("Expected %s to hold %q. Got: %s", name, want, content)
+		}
+	}
+}
+
+func TestRegisterLogType(t *testing.T) {
+	audit, err := RegisterLogType("Audit")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again := MustRegisterLogType("audit"); again != audit {
+		t.Errorf("Expected the same type when registering again. Got %v and %v", audit, again)
+	}
+	for _, name := range []string{"", "9lives", "with space", "Change", "c"} {
+		if _, err := RegisterLogType(name); err == nil {
+			t.Errorf("Expected an error registering %q", name)
+		}
+	}
+	if audit.String() != "Audit" || !audit.IsValid() || Change.String() != LogTypeChange {
+		t.Errorf("Unexpected names: %s, %s", audit, Change)
+	}
+	if parsed, err := ParseLogType("AUDIT"); err != nil || parsed != audit {
+		t.Errorf("Expected ParseLogType to find the registered type. Got %v, %v", parsed, err)
+	}
+
+	var buf, fallback bytes.Buffer
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "types", NewFallbackWriter(&buf, &fallback))
+	logger.WithType("Audit").Log("export approved")
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.Type != audit || !strings.Contains(buf.String(), `"type":"Audit"`) {
+		t.Errorf("Expected an Audit entry. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithType("Change").Log("built-in type")
+	if !strings.Contains(buf.String(), `"type":"C"`) || fallback.Len() != 0 {
+		t.Errorf("Expected a Change entry. Got: %q, %q", buf.String(), fallback.String())
+	}
+
+	buf.Reset()
+	logger.WithType("Unregistered").Log("rejected")
+	if buf.Len() != 0 || !strings.Contains(fallback.String(), "rejected") {
+		t.Errorf("Expected entries of unregistered types written to the fallback writer. Got: %q, %q", buf.String(), fallback.String())
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "instrument", &buf)
+
+	boom := errors.New("boom")
+	if err := Instrument(logger, "sync", func() error { return boom }); err != boom {
+		t.Errorf("Expected the error of the function. Got: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"msg":"sync started"`) {
+		t.Fatalf("Expected a start and a completion entry. Got: %q", buf.String())
+	}
+	var end LogEntry
+	if err := json.Unmarshal([]byte(lines[1]), &end); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if end.Msg != "sync failed" || end.Status != Failure || end.Error != "boom" || end.Pri != Err || end.Op != "sync" {
+		t.Errorf("Unexpected completion entry: %s", lines[1])
+	}
+	if _, ok := end.Data.(map[string]any)["duration_ms"]; !ok {
+		t.Errorf("Expected the duration in the completion entry: %s", lines[1])
+	}
+
+	buf.Reset()
+	n, err := InstrumentR(logger, "count", func() (int, error) { return 42, nil })
+	if n != 42 || err != nil || !strings.Contains(buf.String(), `"msg":"count completed"`) {
+		t.Errorf("Unexpected result %d, %v, entries %q", n, err, buf.String())
+	}
+
+	buf.Reset()
+	defer func() {
+		if r := recover(); r != "kaboom" {
+			t.Errorf("Expected the panic raised again. Got: %v", r)
+		}
+		if !strings.Contains(buf.String(), `"error":"panic: kaboom"`) || !strings.Contains(buf.String(), `"panicked":true`) {
+			t.Errorf("Expected the panic logged. Got: %q", buf.String())
+		}
+	}()
+	Instrument(logger, "explode", func() error { panic("kaboom") })
+}
+
+func TestFieldCipher(t *testing.T) {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fc := NewFieldCipher("2024-01", aead)
+
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "crypto", &buf).WithRedactor(fc.EncryptDataKeys("pan"), nil)
+	data := map[string]any{"pan": "4111111111111111", "card": map[string]any{"pan": 42}, "amount": 10}
+	logger.LogActivity("payment", data)
+
+	if strings.Contains(buf.String(), "4111111111111111") || strings.Count(buf.String(), `"`+EncryptedPrefix+`2024-01:`) != 2 {
+		t.Fatalf("Expected the values encrypted. Got: %s", buf.String())
+	}
+	if data["pan"] != "4111111111111111" {
+		t.Errorf("Expected the caller's data unchanged")
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := DecryptEntry(&entry, NewFieldCipher("other", aead)); !errors.Is(err, ErrUnknownFieldKey) {
+		t.Errorf("Expected ErrUnknownFieldKey. Got: %v", err)
+	}
+	if err := DecryptEntry(&entry, fc); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	decrypted := entry.Data.(map[string]any)
+	if decrypted["pan"] != "4111111111111111" || decrypted["card"].(map[string]any)["pan"] != json.Number("42") {
+		t.Errorf("Unexpected decrypted data: %v", decrypted)
+	}
+
+	// A value moved to another field does not decrypt.
+	blob := fc.encrypt("pan", "4111111111111111")
+	moved := LogEntry{Data: map[string]any{"note": blob}}
+	if err := DecryptEntry(&moved, fc); err == nil {
+		t.Errorf("Expected an error decrypting a value under another field")
+	}
+
+	// Chained with a redactor, some fields are redacted and others encrypted.
+	buf.Reset()
+	logger = logger.WithRedactor(ChainRedactors(RedactDataKeys("email"), nil, fc.EncryptDataKeys("pan")), nil)
+	logger.LogActivity("payment", map[string]any{"email": "john@example.com", "pan": "4111111111111111"})
+	if !strings.Contains(buf.String(), `"email":"`+RedactedArg+`"`) || !strings.Contains(buf.String(), `"pan":"`+EncryptedPrefix) {
+		t.Errorf("Expected the email redacted and the card number encrypted. Got: %s", buf.String())
+	}
+}
+
+func TestValidationErrorsOnFallback(t *testing.T) {
+	var primary, fallback bytes.Buffer
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "validation", NewFallbackWriter(&primary, &fallback))
+
+	logger.WithType("NoSuchType").WithSubjectType("user").Log("invalid")
+	var entry LogEntry
+	if err := json.Unmarshal(fallback.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	failed := make(map[string]FieldValidationError)
+	for _, f := range entry.ValidationErrors {
+		failed[f.Field] = f
+	}
+	if failed["InstanceId"].Tag != "required_with" || failed["Type"].Tag != "logtype" || failed["Type"].Value != LogTypeUnknown {
+		t.Errorf("Unexpected validation errors: %+v", entry.ValidationErrors)
+	}
+
+	logger.Log("valid")
+	if strings.Contains(primary.String(), "validation_errors") {
+		t.Errorf("Expected no validation errors on valid entries. Got: %s", primary.String())
+	}
+}
+
+func TestLogActivityAck(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "ack", &buf)
+	var acks []error
+	ack := func(err error) { acks = append(acks, err) }
+
+	// Synchronous writers acknowledge before LogActivityAck returns.
+	logger.LogActivityAck("written", nil, ack)
+	logger.Debug0().LogActivityAck("filtered", nil, ack)
+	logger.WithType("NoSuchType").LogActivityAck("invalid", nil, ack)
+	logger.WithDryRun(true).LogActivityAck("dry run", nil, ack)
+	NewLogger(NewLoggerContext(Info), "ack", &FailWriter{}).LogActivityAck("failed", nil, ack)
+	if len(acks) != 5 || acks[0] != nil || acks[1] != ErrNotWritten || acks[2] == nil || acks[3] != ErrNotWritten || acks[4] == nil {
+		t.Errorf("Unexpected acknowledgments: %v", acks)
+	}
+
+	// A SplitWriter acknowledges queued entries once they are written.
+	buffered := &gatedWriter{gate: make(chan struct{})}
+	w := NewSplitWriter(&flushCloseWriter{}, buffered, SplitWriterConfig{})
+	defer w.Close()
+	done := make(chan error, 1)
+	NewLogger(NewLoggerContext(Info), "ack", w).LogActivityAck("queued", nil, func(err error) { done <- err })
+	select {
+	case <-done:
+		t.Fatalf("Expected no acknowledgment before the entry is written")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(buffered.gate)
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// An acknowledgment may log with the same Logger, also when the writer calls it
+	// before writeEntryAck returns.
+	var durable bytes.Buffer
+	syncPriority := Info
+	syncWriter := NewSplitWriter(&durable, io.Discard, SplitWriterConfig{SyncPriority: &syncPriority})
+	defer syncWriter.Close()
+	syncLogger := NewLogger(NewLoggerContext(Info), "ack", syncWriter)
+	logged := make(chan struct{})
+	go func() {
+		syncLogger.LogActivityAck("written", nil, func(error) { syncLogger.LogActivity("acknowledged", nil) })
+		close(logged)
+	}()
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an acknowledgment that logs not to deadlock")
+	}
+	if !strings.Contains(durable.String(), "acknowledged") {
+		t.Errorf("Expected the entry logged by the acknowledgment. Got: %s", durable.String())
+	}
+
+	// A panicking acknowledgment is recovered, also on the writer's goroutine.
+	var lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+	asyncWriter := NewSplitWriter(io.Discard, io.Discard, SplitWriterConfig{})
+	defer asyncWriter.Close()
+	NewLogger(NewLoggerContext(Info), "ack", asyncWriter).LogActivityAck("queued", nil, func(error) { panic("boom") })
+	logger.LogActivityAck("written", nil, func(error) { panic("boom") })
+	if err := asyncWriter.Flush(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if strings.Count(lastResort.String(), "panic in acknowledgment callback: boom") != 2 {
+		t.Errorf("Expected both panics reported. Got: %s", lastResort.String())
+	}
+}
+
+func TestWithLibraryVersion(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "version", &buf)
+
+	logger.LogActivity("without version", nil)
+	if strings.Contains(buf.String(), "lh_version") {
+		t.Errorf("Expected no version by default. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.WithLibraryVersion().LogActivity("with version", nil)
+	if Version() == "" || !strings.Contains(buf.String(), `"lh_version":"`+Version()+`"`) {
+		t.Errorf("Expected the version %q in the entry. Got: %s", Version(), buf.String())
+	}
+}
+
+func TestLogJob(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "worker", &buf)
+
+	depth, inFlight := 340, 8
+	logger.LogJob("job done", JobInfo{Queue: "emails", JobID: "j-981", Attempt: 2, QueueDepth: &depth, InFlight: &inFlight}, Failure)
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entry.Type != Activity || entry.Status != Failure {
+		t.Errorf("Unexpected entry: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"data":{"attempt":2,"in_flight":8,"job_id":"j-981","queue":"emails","queue_depth":340}`) {
+		t.Errorf("Unexpected data: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.LogJob("job done", JobInfo{Queue: "emails"}, Success)
+	if !strings.Contains(buf.String(), `"data":{"queue":"emails"}`) {
+		t.Errorf("Expected empty fields omitted: %s", buf.String())
+	}
+
+	buf.Reset()
+	empty := 0
+	logger.LogJob("job done", JobInfo{Queue: "emails", QueueDepth: &empty}, Success)
+	if !strings.Contains(buf.String(), `"data":{"queue":"emails","queue_depth":0}`) {
+		t.Errorf("Expected a reported depth of zero to be written: %s", buf.String())
+	}
+}
+
+func TestFallbackPredicate(t *testing.T) {
+	var fallback, lastResort bytes.Buffer
+	SetLastResortWriter(&lastResort)
+	defer SetLastResortWriter(nil)
+
+	fw := NewFallbackWriter(&FailWriter{}, &fallback)
+	logger := NewLoggerWithFallback(NewLoggerContext(Info), "predicate", fw)
+	fw.SetFallbackPredicate(func(err error) bool { return errors.Is(err, os.ErrDeadlineExceeded) })
+
+	logger.LogActivity("not transient", nil)
+	if fallback.Len() != 0 || !strings.Contains(lastResort.String(), "not transient") {
+		t.Errorf("Expected other errors written to the last resort writer. Got fallback %q, last resort %q", fallback.String(), lastResort.String())
+	}
+
+	fw.SetFallbackPredicate(nil)
+	logger.LogActivity("any error", nil)
+	if !strings.Contains(fallback.String(), "any error") {
+		t.Errorf("Expected every error to fall back by default. Got: %q", fallback.String())
+	}
+
+	fw.SetFallbackPredicate(func(err error) bool { panic("boom") })
+	logger.LogActivity("panicking predicate", nil)
+	if !strings.Contains(fallback.String(), "panicking predicate") || !strings.Contains(lastResort.String(), "panic in fallback predicate: boom") {
+		t.Errorf("Expected a panicking predicate to fall back and be reported. Got fallback %q, last resort %q", fallback.String(), lastResort.String())
+	}
+}
+
+func TestWithHeuristicRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "heuristic", &buf).
+		WithRedactor(RedactDataKeys("email"), nil).
+		WithHeuristicRedaction()
+
+	logger.LogActivity("login", map[string]any{
+		"user":     "alice",
+		"email":    "alice@example.com",
+		"Password": "hunter2",
+		"request":  map[string]any{"headers": map[string]any{"Authorization": "Bearer abc", "X-Api-Key": "k"}},
+		"tokens":   []any{map[string]any{"refresh_token": "r"}},
+	})
+	for _, secret := range []string{"alice@example.com", "hunter2", "Bearer abc", `"k"`, `"r"`} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("Expected %s redacted. Got: %s", secret, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), `"user":"alice"`) {
+		t.Errorf("Expected other keys kept. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger = NewLogger(NewLoggerContext(Info), "heuristic", &buf).WithHeuristicRedaction(regexp.MustCompile(`^pin$`))
+	logger.LogActivity("custom", map[string]any{"pin": "1234", "password": "kept"})
+	if strings.Contains(buf.String(), "1234") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("Expected only the given patterns used. Got: %s", buf.String())
+	}
+
+	// Changes to sensitive fields have their values redacted, by the heuristic and by keys.
+	for _, changes := range []*Logger{
+		NewLogger(NewLoggerContext(Info), "heuristic", &buf).WithHeuristicRedaction(),
+		NewLogger(NewLoggerContext(Info), "heuristic", &buf).WithRedactor(RedactDataKeys("password"), nil),
+	} {
+		buf.Reset()
+		changes.LogDataChange("user updated", ChangeInfo{Entity: "user", Op: "update", Changes: []ChangeDetail{
+			{Field: "password", OldVal: "hunter2", NewVal: "hunter3"},
+			{Field: "name", OldVal: "Alice", NewVal: "Alicia"},
+		}})
+		if strings.Contains(buf.String(), "hunter") || !strings.Contains(buf.String(), `"new_value":"Alicia"`) {
+			t.Errorf("Expected only the password change redacted. Got: %s", buf.String())
+		}
+	}
+
+	// A redactor set afterwards, or removed, leaves the heuristic in place.
+	for _, later := range []*Logger{
+		logger.WithRedactor(RedactDataKeys("email"), nil),
+		logger.WithRedactor(nil, nil),
+	} {
+		buf.Reset()
+		later.LogActivity("later", map[string]any{"pin": "1234", "email": "alice@example.com"})
+		if strings.Contains(buf.String(), "1234") {
+			t.Errorf("Expected the heuristic kept after WithRedactor. Got: %s", buf.String())
+		}
+	}
+}
+
+func TestSummarizer(t *testing.T) {
+	var summaries, entries bytes.Buffer
+	lctx := NewLoggerContext(Info)
+	s := NewSummarizer(NewLogger(lctx, "summary", &summaries), 0)
+	logger := NewLogger(lctx, "poller", &entries).WithSummarizer(s, false)
+
+	for i := 0; i < 3; i++ {
+		logger.WithOp("poll").LogActivity("polled", nil)
+	}
+	logger.WithOp("fetch").Warn().LogResult("fetch failed", errors.New("timeout"), nil)
+	logger.Debug0().LogActivity("filtered", nil)
+	if entries.Len() != 0 {
+		t.Errorf("Expected entries only counted. Got: %s", entries.String())
+	}
+
+	s.Close()
+	var entry LogEntry
+	if err := json.Unmarshal(summaries.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var summary LogSummary
+	data, _ := json.Marshal(entry.Data)
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Entries != 4 || summary.ByPriority["Info"] != 3 || summary.ByPriority["Err"] != 1 || summary.ByStatus["Failure"] != 1 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if len(summary.TopOps) != 2 || summary.TopOps[0] != (OpCount{Op: "poll", Count: 3}) {
+		t.Errorf("Unexpected top operations: %+v", summary.TopOps)
+	}
+
+	// With a sampler, the sampled entries are written and all are counted.
+	entries.Reset()
+	s = NewSummarizer(NewLogger(lctx, "summary", io.Discard), 0)
+	logger = NewLogger(lctx, "poller", &entries).WithTraceSampler(NewTraceSampler(0)).WithSummarizer(s, true)
+	logger.WithTraceID("t1").LogActivity("sampled out", nil)
+	logger.LogActivity("kept", nil)
+	if strings.Contains(entries.String(), "sampled out") || !strings.Contains(entries.String(), "kept") || s.summary.Entries != 2 {
+		t.Errorf("Expected samples written and all entries counted. Got %d counted, entries %q", s.summary.Entries, entries.String())
+	}
+}
+
+func TestJSONEncoderHTMLEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLoggerContext(Info), "escaping", &buf)
+	logger.LogActivity("see https://example.com/?a=1&b=<2>", map[string]any{"url": "/x?y=1&z=2"})
+	if !strings.Contains(buf.String(), `"msg":"see https://example.com/?a=1&b=<2>"`) || !strings.Contains(buf.String(), `"url":"/x?y=1&z=2"`) {
+		t.Errorf("Expected no HTML escaping by default. Got: %s", buf.String())
+	}
+
+	encoded, err := JSONEncoder{EscapeHTML: true}.Encode(LogEntry{Msg: "a&b"})
+	if err != nil || !strings.Contains(string(encoded), `"msg":"a\u0026b"`) || !bytes.HasSuffix(encoded, []byte("}\n")) {
+		t.Errorf("Expected HTML escaping with EscapeHTML. Got: %q, %v", encoded, err)
+	}
+
+	var marshalled any
+	encoded, err = JSONEncoder{Marshal: func(v any) ([]byte, error) {
+		marshalled = v
+		return []byte(`{"custom":true}`), nil
+	}}.Encode(LogEntry{Msg: "custom"})
+	if err != nil || string(encoded) != "{\"custom\":true}\n" || marshalled == nil {
+		t.Errorf("Expected the custom marshal function used. Got: %q, %v", encoded, err)
+	}
+}
@@ -0,0 +1,110 @@
+// Package logharbourtest provides utilities for testing code that uses logharbour,
+// such as writers that fail on command.
+package logharbourtest
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is the error returned by a FaultyWriter for the writes it fails.
+var ErrInjected = errors.New("logharbourtest: injected write failure")
+
+// FaultyWriter is an io.Writer that fails or slows down writes on command, to test the
+// handling of writer failures, for instance by a FallbackWriter, without a real network
+// sink. Writes that do not fail are passed to the wrapped writer.
+//
+//	primary := logharbourtest.NewFaultyWriter(&buf)
+//	primary.FailNext(3)
+//	logger := logharbour.NewLoggerWithFallback(lctx, "app", logharbour.NewFallbackWriter(primary, &fallback))
+//
+// Faults combine: a write first waits for the latency, then fails if it is among the
+// next writes to fail, or else with the failure ratio. A FaultyWriter is safe for
+// concurrent use.
+type FaultyWriter struct {
+	w        io.Writer
+	failNext int
+	ratio    float64
+	rand     *rand.Rand
+	latency  time.Duration
+	writes   int
+	failures int
+	mu       sync.Mutex
+}
+
+// NewFaultyWriter creates a FaultyWriter that passes the writes it does not fail to w,
+// or discards them if w is nil. It does not fail any write until told to.
+func NewFaultyWriter(w io.Writer) *FaultyWriter {
+	if w == nil {
+		w = io.Discard
+	}
+	return &FaultyWriter{w: w}
+}
+
+// FailNext makes the next n writes fail.
+func (fw *FaultyWriter) FailNext(n int) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.failNext = n
+}
+
+// FailRatio makes writes fail at random with the given probability, between 0 and 1.
+// The random choices are drawn from a source initialized with seed, so that a test
+// sees the same sequence of failures on every run. A ratio of 0 stops random failures.
+func (fw *FaultyWriter) FailRatio(ratio float64, seed int64) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.ratio = ratio
+	fw.rand = rand.New(rand.NewSource(seed))
+}
+
+// SetLatency makes every write wait for d before completing or failing.
+func (fw *FaultyWriter) SetLatency(d time.Duration) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.latency = d
+}
+
+// Write writes p to the wrapped writer, or fails with ErrInjected. It implements io.Writer.
+func (fw *FaultyWriter) Write(p []byte) (n int, err error) {
+	fw.mu.Lock()
+	latency := fw.latency
+	fw.writes++
+	fail := fw.failNext > 0
+	if fail {
+		fw.failNext--
+	} else if fw.ratio > 0 {
+		fail = fw.rand.Float64() < fw.ratio
+	}
+	if fail {
+		fw.failures++
+	}
+	fw.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if fail {
+		return 0, ErrInjected
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.w.Write(p)
+}
+
+// Writes returns the number of writes attempted so far.
+func (fw *FaultyWriter) Writes() int {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.writes
+}
+
+// Failures returns the number of writes failed so far.
+func (fw *FaultyWriter) Failures() int {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.failures
+}
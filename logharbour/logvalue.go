@@ -0,0 +1,117 @@
+package logharbour
+
+import "reflect"
+
+// maxLogValueDepth is the maximum nesting depth at which LogMarshaler values in Data are resolved.
+const maxLogValueDepth = 10
+
+// LogMarshaler is implemented by types that control their own representation in log
+// entries, typically to redact sensitive content. For example, a credit card type can
+// log only the last four digits of its number:
+//
+//	func (c CreditCard) LogValue() any {
+//		return map[string]any{"last4": c.Number[len(c.Number)-4:]}
+//	}
+//
+// Before an entry is written, Data is replaced by its LogValue if it implements
+// LogMarshaler, and so are the elements of maps, slices and arrays in Data, and the
+// values pointed to by pointers, at any depth up to 10. The result of LogValue is itself
+// resolved, so it may contain other LogMarshaler values. The fields of structs are not
+// visited, except the values of a ChangeInfo and its ChangeDetails, so a struct that
+// holds sensitive values should implement LogMarshaler itself. The depth limit also
+// stops cycles, such as a map that contains itself; values beyond it are replaced by
+// RedactedArg, since they could hold LogMarshaler values that were not resolved.
+//
+// If a LogValue method panics, the panic is handled like that of other callbacks (see
+// LoggerContext.SetPropagatePanics) and the entry is written without its Data, so that
+// values that should have been redacted are never written as they are.
+type LogMarshaler interface {
+	LogValue() any
+}
+
+// resolveLogValues returns v with LogMarshaler values replaced by their LogValue.
+// Containers are copied only if one of their elements changed.
+func resolveLogValues(v any, depth int) (result any, changed bool) {
+	if v == nil {
+		return v, false
+	}
+	if depth > maxLogValueDepth {
+		return RedactedArg, true // fail closed
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return v, false
+	}
+	if m, ok := v.(LogMarshaler); ok {
+		resolved, _ := resolveLogValues(m.LogValue(), depth+1)
+		return resolved, true
+	}
+
+	switch d := v.(type) {
+	case ChangeDetail:
+		if resolveFields(depth+1, &d.OldVal, &d.NewVal) {
+			return d, true
+		}
+		return v, false
+	case ChangeInfo:
+		changes := append([]ChangeDetail(nil), d.Changes...)
+		anyChanged := resolveFields(depth+1, &d.Before, &d.After)
+		for i := range changes {
+			anyChanged = resolveFields(depth+2, &changes[i].OldVal, &changes[i].NewVal) || anyChanged
+		}
+		if anyChanged {
+			d.Changes = changes
+			return d, true
+		}
+		return v, false
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if resolved, changed := resolveLogValues(rv.Elem().Interface(), depth+1); changed {
+			return resolved, true
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v, false
+		}
+		resolvedMap := make(map[string]any, rv.Len())
+		anyChanged := false
+		iter := rv.MapRange()
+		for iter.Next() {
+			resolved, changed := resolveLogValues(iter.Value().Interface(), depth+1)
+			resolvedMap[iter.Key().String()] = resolved
+			anyChanged = anyChanged || changed
+		}
+		if anyChanged {
+			return resolvedMap, true
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v, false // []byte is written as base64
+		}
+		resolvedSlice := make([]any, rv.Len())
+		anyChanged := false
+		for i := range resolvedSlice {
+			resolved, changed := resolveLogValues(rv.Index(i).Interface(), depth+1)
+			resolvedSlice[i] = resolved
+			anyChanged = anyChanged || changed
+		}
+		if anyChanged {
+			return resolvedSlice, true
+		}
+	}
+	return v, false
+}
+
+// resolveFields resolves the values of struct fields in place, at the given depth, and
+// reports whether any of them changed.
+func resolveFields(depth int, fields ...*any) (changed bool) {
+	for _, field := range fields {
+		if resolved, fieldChanged := resolveLogValues(*field, depth); fieldChanged {
+			*field = resolved
+			changed = true
+		}
+	}
+	return changed
+}
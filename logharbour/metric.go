@@ -0,0 +1,25 @@
+package logharbour
+
+// MetricInfo is the Data of a metric entry logged by LogMetric.
+type MetricInfo struct {
+	Name   string            `json:"name"`             // Name of the metric, e.g. "orders_placed".
+	Value  float64           `json:"value"`            // Value of the event, e.g. 1 for a counter increment.
+	Labels map[string]string `json:"labels,omitempty"` // Labels of the metric, if any.
+}
+
+// LogMetric logs a metric event for pipelines that derive metrics from logs, such as
+// counting orders from their log entries. The entry has type Metric ("M"), priority Info,
+// the metric name as its message and a MetricInfo as its data:
+//
+//	{..., "type": "M", "pri": "Info", ..., "msg": "orders_placed",
+//	 "data": {"name": "orders_placed", "value": 1, "labels": {"region": "eu"}}}
+//
+// The other fields of the entry are set from the Logger as usual; with JSONEncoder.Compact
+// the empty ones are left out. Consumers can select metric entries by their type alone.
+// This is meant for teams that only have a log pipeline; it does not record the metric
+// anywhere else. Keep the label values few, as each combination makes a separate series.
+func (l *Logger) LogMetric(name string, value float64, labels map[string]string) {
+	entry := l.Info().newLogEntry(name, MetricInfo{Name: name, Value: value, Labels: labels})
+	entry.Type = Metric
+	l.log(entry)
+}
@@ -0,0 +1,143 @@
+package logharbour
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader is the HTTP header used to read and return the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware returns net/http middleware that logs every request handled by the wrapped handler.
+//
+// For each request it derives a Logger from base with:
+//   - remoteIP set to the IP address of the client,
+//   - op set to the request method and path, e.g. "GET /users",
+//   - the trace ID set to the request ID, taken from the X-Request-ID header or generated,
+//   - the priority boost requested by the DebugHeader, if the request is trusted by the
+//     predicate given with WithDebugHeader (see BoostFromRequest).
+//
+// The Logger is stored in the request context, where handlers can retrieve it with
+// FromContext. The request ID is also returned in the X-Request-ID response header.
+// When the handler returns, an activity entry is logged with the response status code
+// and the duration of the request. Responses with a 5xx status are logged with status
+// Failure at priority Err; all others with status Success.
+func Middleware(base *Logger, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	var cfg middlewareConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			logger := base
+			if cfg.trustDebugHeader != nil && cfg.trustDebugHeader(r) {
+				logger = logger.BoostFromRequest(r)
+			}
+			logger = logger.
+				WithRemoteIP(remoteHost(r.RemoteAddr)).
+				WithOp(r.Method + " " + r.URL.Path).
+				WithTraceID(requestID)
+
+			if inheritanceTrackingBuild && logger.trackInheritance {
+				defer enterRequestScope(requestID)()
+			}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(NewContext(r.Context(), logger)))
+
+			data := map[string]any{
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"status_code": sw.status,
+				"duration_ms": time.Since(start).Milliseconds(),
+				"request_id":  requestID,
+			}
+			if sw.status >= http.StatusInternalServerError {
+				logger.WithStatus(Failure).Err().LogActivity("request completed", data)
+			} else {
+				logger.WithStatus(Success).LogActivity("request completed", data)
+			}
+		})
+	}
+}
+
+// MiddlewareOption configures the middleware returned by Middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+// middlewareConfig holds the options of Middleware.
+type middlewareConfig struct {
+	trustDebugHeader func(r *http.Request) bool
+}
+
+// WithDebugHeader lets the requests for which trusted returns true boost the priority of
+// their Logger with the DebugHeader. The header is set by the client, so it is ignored by
+// default; trusted should only accept requests from trusted callers, for instance
+// authenticated operators:
+//
+//	logharbour.Middleware(logger, logharbour.WithDebugHeader(func(r *http.Request) bool {
+//		return isOperator(r)
+//	}))
+func WithDebugHeader(trusted func(r *http.Request) bool) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.trustDebugHeader = trusted
+	}
+}
+
+// statusWriter wraps an http.ResponseWriter to capture the response status code.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code and passes it on.
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.status = code
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Write marks the header as written with the default status and passes the data on.
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush marks the header as written with the default status and flushes the underlying
+// ResponseWriter, if it supports it, so that handlers that stream responses, such as
+// server-sent events, keep working behind the middleware.
+func (sw *statusWriter) Flush() {
+	sw.wroteHeader = true
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// remoteHost returns the host part of a "host:port" address, or the address itself.
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// newRequestID generates a random 128-bit request ID encoded as hex.
+func newRequestID() string {
+	return newRandomID(16)
+}
@@ -0,0 +1,106 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// currencyExponents lists ISO 4217 currencies whose minor unit is not 1/100.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MoneyField records a monetary amount in a standard form for activity logs.
+// The amount is held as an integer number of minor units (e.g. paise or cents) to
+// avoid floating point precision loss, together with an ISO 4217 currency code.
+//
+// A MoneyField serializes as
+//
+//	{"minor_units": 12345, "currency": "INR", "amount": "123.45"}
+//
+// where amount is the decimal representation of the value as a string.
+type MoneyField struct {
+	MinorUnits int64  `json:"minor_units"`
+	Currency   string `json:"currency"`
+}
+
+// NewMoneyField creates a MoneyField after checking that currency is a three-letter code.
+// The currency code is converted to upper case.
+func NewMoneyField(minorUnits int64, currency string) (MoneyField, error) {
+	currency = strings.ToUpper(currency)
+	if len(currency) != 3 {
+		return MoneyField{}, fmt.Errorf("invalid currency code %q", currency)
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return MoneyField{}, fmt.Errorf("invalid currency code %q", currency)
+		}
+	}
+	return MoneyField{MinorUnits: minorUnits, Currency: currency}, nil
+}
+
+// String returns the amount as a decimal string followed by the currency, e.g. "123.45 INR".
+func (m MoneyField) String() string {
+	return m.amount() + " " + m.Currency
+}
+
+// amount formats the minor units as a decimal string using the currency's exponent.
+func (m MoneyField) amount() string {
+	exp, ok := currencyExponents[m.Currency]
+	if !ok {
+		exp = 2
+	}
+	sign := ""
+	units := m.MinorUnits
+	if units < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(uint64(abs64(units)), 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// abs64 returns the absolute value of n as an unsigned integer, handling math.MinInt64.
+func abs64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// MarshalJSON is required by the encoding/json package.
+// It adds the decimal amount as a string alongside the minor units and currency.
+func (m MoneyField) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		MinorUnits int64  `json:"minor_units"`
+		Currency   string `json:"currency"`
+		Amount     string `json:"amount"`
+	}{m.MinorUnits, m.Currency, m.amount()})
+}
+
+// AttachMoney adds a MoneyField under key to the activity data and returns the result.
+// If info is nil or a map[string]any, the field is added to a copy of the map.
+// Otherwise info is kept under the "data" key of a new map holding the field.
+func AttachMoney(info ActivityInfo, key string, m MoneyField) ActivityInfo {
+	result := map[string]any{}
+	switch data := info.(type) {
+	case nil:
+	case map[string]any:
+		for k, v := range data {
+			result[k] = v
+		}
+	default:
+		result["data"] = data
+	}
+	result[key] = m
+	return result
+}
@@ -0,0 +1,71 @@
+package logharbour
+
+import "strings"
+
+// NewlineMode controls how newlines embedded in string fields of a log entry are handled.
+type NewlineMode int
+
+const (
+	// NewlineEscape leaves newlines in place. The JSON encoder escapes them as \n,
+	// so every entry is still written as a single physical line. This is the default.
+	NewlineEscape NewlineMode = iota
+	// NewlineReplace replaces every \r\n, \n and \r with a single space before encoding,
+	// for consumers that split decoded values on newlines as well.
+	NewlineReplace
+)
+
+// newlineReplacer replaces all newline sequences with a space.
+var newlineReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// WithNewlineMode returns a new Logger that handles newlines in string fields according to mode.
+// NewlineReplace applies to the message, the error and every string value in Data. Data of
+// other types than string, map[string]any and []any, such as ChangeInfo or a struct of the
+// application, is converted to its JSON form first to reach the strings it holds.
+func (l *Logger) WithNewlineMode(mode NewlineMode) *Logger {
+	newLogger := l.clone()
+	newLogger.newlineMode = mode
+	if newLogger.tracing {
+		newLogger.traceMutation("WithNewlineMode", mode)
+	}
+	return newLogger
+}
+
+// normalizeNewlines applies the newline mode to the string fields of entry.
+func normalizeNewlines(entry *LogEntry, mode NewlineMode) {
+	if mode != NewlineReplace {
+		return
+	}
+	entry.Msg = newlineReplacer.Replace(entry.Msg)
+	entry.Error = newlineReplacer.Replace(entry.Error)
+	switch entry.Data.(type) {
+	case nil, string, map[string]any, []any:
+	default:
+		if data, err := genericData(entry.Data); err == nil {
+			entry.Data = data
+		}
+	}
+	entry.Data = replaceNewlinesInValue(entry.Data)
+}
+
+// replaceNewlinesInValue returns v with newlines replaced in strings, walking maps and slices.
+// Maps and slices are copied so that the caller's data is not modified.
+func replaceNewlinesInValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		return newlineReplacer.Replace(val)
+	case map[string]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			m[k] = replaceNewlinesInValue(item)
+		}
+		return m
+	case []any:
+		s := make([]any, len(val))
+		for i, item := range val {
+			s[i] = replaceNewlinesInValue(item)
+		}
+		return s
+	default:
+		return v
+	}
+}
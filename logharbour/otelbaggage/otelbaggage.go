@@ -0,0 +1,37 @@
+// Package otelbaggage adds OpenTelemetry baggage propagated with a request to the
+// entries of a logharbour Logger.
+//
+// It lives in its own package so that applications which do not use OpenTelemetry
+// do not need to depend on it.
+package otelbaggage
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/baggage"
+
+	"github.com/remiges-tech/logharbour/logharbour"
+)
+
+// WithBaggage returns a new Logger whose entries carry the members of the baggage in
+// ctx named by keys, in their 'baggage' field (see Logger.WithBaggage).
+// Only the listed keys are promoted, which keeps arbitrary baggage, and the cardinality
+// it would add to log indexes, out of the logs. Keys absent from the baggage are skipped;
+// if none is present, logger is returned unchanged.
+//
+// Example:
+//
+//	logger := otelbaggage.WithBaggage(r.Context(), logger, "tenant_id", "plan")
+func WithBaggage(ctx context.Context, logger *logharbour.Logger, keys ...string) *logharbour.Logger {
+	bag := baggage.FromContext(ctx)
+	members := make(map[string]string)
+	for _, key := range keys {
+		if member := bag.Member(key); member.Key() != "" {
+			members[key] = member.Value()
+		}
+	}
+	if len(members) == 0 {
+		return logger
+	}
+	return logger.WithBaggage(members)
+}
@@ -0,0 +1,175 @@
+package logharbour
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// OTLPJSONEncoder encodes entries in the OpenTelemetry OTLP/JSON log format, one JSON
+// object per line, so that OTLP files can be written without a live exporter.
+//
+// Each entry becomes an OTLP LogRecord: When is timeUnixNano, the message is the string
+// body, the priority gives severityNumber and severityText, and the other non-empty
+// fields, including Data, become attributes named after their JSON names. TraceID and
+// SpanID are set as traceId and spanId if they are valid hex IDs of the OTLP length.
+//
+// If Envelope is set, each record is wrapped in a complete export request
+// ({"resourceLogs":[{"resource":...,"scopeLogs":[{"logRecords":[...]}]}]}) with the
+// entry's app as service.name, which is the form the OpenTelemetry Collector's
+// otlpjsonfile receiver reads.
+type OTLPJSONEncoder struct {
+	Envelope bool
+}
+
+// otlpKeyValue is an OTLP attribute or kvlist element.
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// otlpLogRecord is the OTLP/JSON representation of a LogRecord.
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 map[string]any `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+// Encode implements Encoder.
+func (oe OTLPJSONEncoder) Encode(entry LogEntry) ([]byte, error) {
+	when := strconv.FormatInt(entry.When.UnixNano(), 10)
+	record := otlpLogRecord{
+		TimeUnixNano:         when,
+		ObservedTimeUnixNano: when,
+		SeverityNumber:       otlpSeverity(entry.Pri),
+		SeverityText:         entry.Pri.String(),
+		Body:                 map[string]any{"stringValue": entry.Msg},
+	}
+	if isHexID(entry.TraceID, 16) {
+		record.TraceID = entry.TraceID
+	}
+	if isHexID(entry.SpanID, 8) {
+		record.SpanID = entry.SpanID
+	}
+
+	// The attributes are the entry's fields other than those mapped above.
+	fields, err := genericData(entry)
+	if err != nil {
+		return nil, err
+	}
+	if fieldMap, ok := fields.(map[string]any); ok {
+		for _, name := range []string{"when", "pri", "msg", "trace_id", "span_id"} {
+			delete(fieldMap, name)
+		}
+		record.Attributes = otlpKeyValues(fieldMap)
+	}
+
+	var v any = record
+	if oe.Envelope {
+		v = map[string]any{
+			"resourceLogs": []any{map[string]any{
+				"resource": map[string]any{
+					"attributes": []otlpKeyValue{{Key: "service.name", Value: map[string]any{"stringValue": entry.App}}},
+				},
+				"scopeLogs": []any{map[string]any{
+					"scope":      map[string]any{"name": "logharbour"},
+					"logRecords": []otlpLogRecord{record},
+				}},
+			}},
+		}
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(encoded, '\n'), nil
+}
+
+// otlpSeverity maps a LogPriority to an OTLP severity number.
+func otlpSeverity(p LogPriority) int {
+	switch p {
+	case Debug2:
+		return 5 // DEBUG
+	case Debug1:
+		return 6 // DEBUG2
+	case Debug0:
+		return 7 // DEBUG3
+	case Info:
+		return 9 // INFO
+	case Warn:
+		return 13 // WARN
+	case Err:
+		return 17 // ERROR
+	case Crit:
+		return 21 // FATAL
+	case Sec:
+		return 22 // FATAL2
+	default:
+		return 0 // UNSPECIFIED
+	}
+}
+
+// otlpValue converts a generic JSON value (see genericData) to an OTLP AnyValue.
+// It returns nil for null and empty values, which are left out of attributes.
+func otlpValue(v any) map[string]any {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			// OTLP/JSON encodes 64-bit integers as strings.
+			return map[string]any{"intValue": strconv.FormatInt(i, 10)}
+		}
+		f, _ := v.Float64()
+		return map[string]any{"doubleValue": f}
+	case []any:
+		values := make([]map[string]any, 0, len(v))
+		for _, elem := range v {
+			if value := otlpValue(elem); value != nil {
+				values = append(values, value)
+			}
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}
+	case map[string]any:
+		return map[string]any{"kvlistValue": map[string]any{"values": otlpKeyValues(v)}}
+	default:
+		return nil
+	}
+}
+
+// otlpKeyValues converts a JSON object to OTLP key-value pairs, sorted by key.
+// Null and empty values are left out.
+func otlpKeyValues(m map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		if value := otlpValue(m[k]); value != nil {
+			kvs = append(kvs, otlpKeyValue{Key: k, Value: value})
+		}
+	}
+	return kvs
+}
+
+// isHexID reports whether id is the hex encoding of an n-byte ID.
+func isHexID(id string, n int) bool {
+	if len(id) != 2*n {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,83 @@
+package logharbour
+
+import "sync"
+
+// pauseState records whether logging is paused. It is shared by all clones of a Logger.
+type pauseState struct {
+	paused bool
+	below  LogPriority // entries below this priority are suppressed while paused
+	mu     sync.Mutex
+	// transitionMu serializes pausing and resuming with their markers, so that concurrent
+	// calls log one marker per transition, in order.
+	transitionMu sync.Mutex
+}
+
+// suppresses reports whether an entry with priority p is suppressed by the pause.
+func (ps *pauseState) suppresses(p LogPriority) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.paused && p < ps.below
+}
+
+// Pause suppresses all entries below Crit until Resume is called, for instance during a
+// noisy maintenance window. See PauseBelow.
+func (l *Logger) Pause() {
+	l.PauseBelow(Crit)
+}
+
+// PauseBelow suppresses all entries with a priority below pri until Resume is called.
+// The pause applies to l and all Loggers cloned from the same Logger returned by
+// NewLogger or NewLoggerWithFallback, whatever their own priority settings, and takes
+// precedence over them. An entry "logging paused" marks the start of the pause; calling
+// PauseBelow while paused only changes the priority. The markers are logged at Info,
+// raised to the minimum priority in effect if it is higher, so that they are not
+// filtered out.
+func (l *Logger) PauseBelow(pri LogPriority) {
+	if l.pause == nil {
+		return
+	}
+	l.pause.transitionMu.Lock()
+	defer l.pause.transitionMu.Unlock()
+	l.pause.mu.Lock()
+	wasPaused := l.pause.paused
+	l.pause.mu.Unlock()
+	if !wasPaused {
+		l.logPauseMarker("logging paused", map[string]any{"below": pri})
+	}
+	l.pause.mu.Lock()
+	l.pause.paused, l.pause.below = true, pri
+	l.pause.mu.Unlock()
+}
+
+// Resume ends a pause started with Pause or PauseBelow. An entry "logging resumed" marks
+// the end of the pause. Calling Resume when logging is not paused does nothing.
+func (l *Logger) Resume() {
+	if l.pause == nil {
+		return
+	}
+	l.pause.transitionMu.Lock()
+	defer l.pause.transitionMu.Unlock()
+	l.pause.mu.Lock()
+	wasPaused := l.pause.paused
+	l.pause.paused = false
+	l.pause.mu.Unlock()
+	if wasPaused {
+		l.logPauseMarker("logging resumed", nil)
+	}
+}
+
+// logPauseMarker logs a pause marker at Info, raised to the minimum priority in effect if
+// it is higher, as LogSelfConfig does.
+func (l *Logger) logPauseMarker(message string, data any) {
+	minPriority := l.baseMinPriority()
+	if l.minPriority != 0 && l.minPriority < minPriority {
+		minPriority = l.minPriority
+	}
+	entry := l.newLogEntry(message, data)
+	entry.Type = Activity
+	entry.Pri = Info
+	if entry.Pri < minPriority {
+		entry.Pri = minPriority
+	}
+	l.log(entry)
+}
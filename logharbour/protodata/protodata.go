@@ -0,0 +1,37 @@
+// Package protodata converts protobuf messages into a form suitable for the Data
+// field of a logharbour log entry.
+//
+// It lives in its own package so that applications which do not log protobuf
+// messages do not need to depend on the protobuf runtime.
+package protodata
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// marshalOptions keeps the field names from the .proto definition and writes enums as strings.
+var marshalOptions = protojson.MarshalOptions{
+	UseProtoNames: true,
+}
+
+// ProtoData returns the protojson representation of m for use as the Data of a log entry.
+// The result is embedded in the entry as a JSON object rather than as an escaped string.
+// If m cannot be marshaled, ProtoData returns a map holding the marshaling error so that
+// the entry is still logged.
+//
+// Example:
+//
+//	logger.LogActivity("order received", protodata.ProtoData(order))
+func ProtoData(m proto.Message) any {
+	if m == nil {
+		return nil
+	}
+	b, err := marshalOptions.Marshal(m)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return json.RawMessage(b)
+}
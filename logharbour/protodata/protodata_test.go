@@ -0,0 +1,88 @@
+package protodata
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/remiges-tech/logharbour/logharbour"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/typepb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtoData(t *testing.T) {
+	nested, err := structpb.NewStruct(map[string]any{
+		"order": map[string]any{"id": "o-1", "items": []any{map[string]any{"sku": "a", "qty": 2}}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		msg  proto.Message
+		want string // JSON form of the Data, or "" for nil
+	}{
+		{"nil message", nil, ""},
+		{"typed nil message", (*apipb.Api)(nil), `{}`},
+		{"empty message", &apipb.Api{}, `{}`},
+		{"wrapper", wrapperspb.String("hello"), `"hello"`},
+		{"nested struct", nested, `{"order":{"id":"o-1","items":[{"qty":2,"sku":"a"}]}}`},
+		{
+			"nested messages with proto names and enums",
+			&apipb.Api{
+				Name:    "users.Users",
+				Syntax:  typepb.Syntax_SYNTAX_PROTO3,
+				Methods: []*apipb.Method{{Name: "Get", RequestTypeUrl: "type.googleapis.com/users.GetRequest"}},
+			},
+			`{"name":"users.Users","methods":[{"name":"Get","request_type_url":"type.googleapis.com/users.GetRequest"}],"syntax":"SYNTAX_PROTO3"}`,
+		},
+	}
+	for _, tt := range tests {
+		data := ProtoData(tt.msg)
+		if tt.want == "" {
+			if data != nil {
+				t.Errorf("%s: expected nil. Got: %v", tt.name, data)
+			}
+			continue
+		}
+		raw, ok := data.(json.RawMessage)
+		if !ok {
+			t.Errorf("%s: expected a json.RawMessage. Got: %T", tt.name, data)
+			continue
+		}
+		var got, want any
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatalf("%s: invalid expectation: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %s. Got: %s", tt.name, tt.want, raw)
+		}
+	}
+}
+
+func TestProtoDataError(t *testing.T) {
+	data := ProtoData(wrapperspb.String("\xff"))
+	m, ok := data.(map[string]any)
+	if !ok || !strings.Contains(m["error"].(string), "UTF-8") {
+		t.Errorf("Expected the marshaling error in Data. Got: %v", data)
+	}
+}
+
+func TestProtoDataInEntry(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logharbour.NewLogger(logharbour.NewLoggerContext(logharbour.Info), "proto", &buf)
+	logger.LogActivity("api registered", ProtoData(&apipb.Api{Name: "users.Users", Version: "v1"}))
+	if !strings.Contains(buf.String(), `"data":{"name":"users.Users","version":"v1"}`) {
+		t.Errorf("Expected the message embedded as a JSON object. Got: %s", buf.String())
+	}
+}
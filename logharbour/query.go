@@ -0,0 +1,46 @@
+package logharbour
+
+import "time"
+
+// RedactedArg replaces the arguments of queries logged by a Logger with query argument
+// redaction enabled.
+const RedactedArg = "[redacted]"
+
+// QueryInfo is the Data of an entry logged by LogQuery.
+type QueryInfo struct {
+	Statement    string `json:"statement"`     // The SQL statement, with placeholders.
+	Args         []any  `json:"args"`          // The arguments of the statement, possibly redacted.
+	DurationMs   int64  `json:"duration_ms"`   // How long the query took, in milliseconds.
+	RowsAffected int64  `json:"rows_affected"` // Number of rows returned or affected.
+}
+
+// WithQueryArgRedaction returns a new Logger whose LogQuery entries have each query
+// argument replaced by RedactedArg if redact is true. The number of arguments is kept.
+func (l *Logger) WithQueryArgRedaction(redact bool) *Logger {
+	newLogger := l.clone()
+	newLogger.redactQueryArgs = redact
+	if newLogger.tracing {
+		newLogger.traceMutation("WithQueryArgRedaction", redact)
+	}
+	return newLogger
+}
+
+// LogQuery logs the execution of a database query as an activity entry in a standard
+// shape, with a QueryInfo as its data and the statement as its message. As with
+// LogResult, a nil err logs the entry with status Success, while a non-nil err logs it
+// with status Failure, the error set, and at least priority Err.
+func (l *Logger) LogQuery(query string, args []any, d time.Duration, rowsAffected int64, err error) {
+	if l.redactQueryArgs {
+		redacted := make([]any, len(args))
+		for i := range redacted {
+			redacted[i] = RedactedArg
+		}
+		args = redacted
+	}
+	l.LogResult(query, err, QueryInfo{
+		Statement:    query,
+		Args:         args,
+		DurationMs:   d.Milliseconds(),
+		RowsAffected: rowsAffected,
+	})
+}
@@ -0,0 +1,37 @@
+package logharbour
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SetPropagatePanics controls what happens when a user-supplied callback, such as an
+// escalation predicate, panics while an entry is being logged.
+// By default the panic is recovered: it is reported to the last resort writer together
+// with the entry, and logging continues as if the callback had not been set.
+// Passing true makes the panic propagate to the caller after it has been reported,
+// which can be useful in tests.
+func (lc *LoggerContext) SetPropagatePanics(propagate bool) {
+	var val int32
+	if propagate {
+		val = 1
+	}
+	atomic.StoreInt32(&lc.propagatePanics, val)
+}
+
+// callSafely runs a user-supplied callback named name, recovering any panic.
+// If fn panics, the panic is reported to the last resort writer along with entry and
+// false is returned; if the context is set to propagate panics, the panic is re-raised.
+func (l *Logger) callSafely(name string, entry LogEntry, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			writeLastResort(fmt.Errorf("panic in %s: %v", name, r), entry)
+			if atomic.LoadInt32(&l.context.propagatePanics) == 1 {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
@@ -0,0 +1,188 @@
+package logharbour
+
+import "regexp"
+
+// Redactor removes sensitive content, such as personal data, from log entries before
+// they are written.
+type Redactor interface {
+	Redact(entry *LogEntry)
+}
+
+// RedactorFunc adapts an ordinary function to the Redactor interface.
+type RedactorFunc func(entry *LogEntry)
+
+// Redact implements Redactor by calling f.
+func (f RedactorFunc) Redact(entry *LogEntry) {
+	f(entry)
+}
+
+// ChainRedactors returns a Redactor that applies redactors in order, skipping nil ones.
+// It lets a single Logger combine redactors, since WithRedactor holds only one, for
+// instance to redact personal data and encrypt other fields:
+//
+//	logger = logger.WithRedactor(logharbour.ChainRedactors(
+//		logharbour.RedactDataKeys("email", "phone"),
+//		fc.EncryptDataKeys("pan"),
+//	), nil)
+func ChainRedactors(redactors ...Redactor) Redactor {
+	return RedactorFunc(func(entry *LogEntry) {
+		for _, r := range redactors {
+			if r != nil {
+				r.Redact(entry)
+			}
+		}
+	})
+}
+
+// redaction is a Redactor with the predicate saying when it applies.
+type redaction struct {
+	redactor Redactor
+	onlyWhen func(LogEntry) bool
+}
+
+// WithRedactor returns a new Logger that applies redactor to its entries for which
+// onlyWhen returns true, or to all of them if onlyWhen is nil. This lets a single
+// configuration redact personal data in production while showing the raw values in
+// local development:
+//
+//	logger = logger.WithRedactor(logharbour.RedactDataKeys("email", "phone"),
+//		func(e logharbour.LogEntry) bool { return e.Env != "dev" })
+//
+// Entries are redacted after LogValue resolution and before validation, so invalid
+// entries written to the fallback writer are redacted too. If the redactor or the
+// predicate panics, the entry is dropped rather than written unredacted.
+//
+// The predicate decides whether sensitive data is written, so it should fail closed:
+// test for the environments where raw values are allowed, such as Env != "dev", rather
+// than for those where they are not, such as Env == "prod", so that an entry with a
+// missing or misspelled environment is redacted. Pass a nil redactor to remove redaction;
+// the redaction of WithHeuristicRedaction, if any, is kept either way.
+func (l *Logger) WithRedactor(redactor Redactor, onlyWhen func(LogEntry) bool) *Logger {
+	newLogger := l.clone()
+	newLogger.redactor = nil
+	if redactor != nil {
+		newLogger.redactor = &redaction{redactor: redactor, onlyWhen: onlyWhen}
+	}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithRedactor", redactor)
+	}
+	return newLogger
+}
+
+// apply redacts entry if the predicate allows it. It returns false if the redactor or
+// the predicate panicked. report is the entry used in the panic report.
+func (r *redaction) apply(l *Logger, entry *LogEntry, report LogEntry) bool {
+	return l.callSafely("redactor", report, func() {
+		if r.onlyWhen == nil || r.onlyWhen(*entry) {
+			r.redactor.Redact(entry)
+		}
+	})
+}
+
+// RedactDataKeys returns a Redactor that replaces with RedactedArg the values of the
+// given keys in the Data of entries, at any depth. The changes of a ChangeInfo to a field
+// named after one of the keys have their old and new values replaced too. Data that is not
+// made of maps and slices, such as a struct, is first converted to its JSON form; the
+// caller's data is never modified. Data that cannot be converted is replaced as a whole.
+func RedactDataKeys(keys ...string) Redactor {
+	redacted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		redacted[key] = true
+	}
+	return redactDataKeysIf(func(key string) bool { return redacted[key] })
+}
+
+// redactDataKeysIf returns a Redactor that replaces with RedactedArg the values of the
+// keys in the Data of entries for which redacted returns true, as RedactDataKeys does.
+func redactDataKeysIf(redacted func(key string) bool) Redactor {
+	return RedactorFunc(func(entry *LogEntry) {
+		if entry.Data == nil {
+			return
+		}
+		data, err := genericData(entry.Data)
+		if err != nil {
+			entry.Data = RedactedArg // fail closed
+			return
+		}
+		entry.Data = redactKeys(data, redacted)
+	})
+}
+
+// redactKeys replaces the values of the redacted keys in the maps of v, and the old and
+// new values of the changes to redacted fields in the ChangeDetail objects of v.
+func redactKeys(v any, redacted func(key string) bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if field, ok := v["field"].(string); ok && redacted(field) {
+			for _, key := range []string{"old_value", "new_value"} {
+				if _, ok := v[key]; ok {
+					v[key] = RedactedArg
+				}
+			}
+		}
+		for key, value := range v {
+			if redacted(key) {
+				v[key] = RedactedArg
+			} else {
+				v[key] = redactKeys(value, redacted)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactKeys(value, redacted)
+		}
+	}
+	return v
+}
+
+// DefaultSensitiveKeyPatterns are the patterns used by WithHeuristicRedaction when none
+// are given. They match, case-insensitively, keys naming passwords, secrets, tokens, API
+// keys, private keys, authorization headers, social security numbers and payment card
+// data, such as "password", "db_passwd", "clientSecret", "access_token", "X-Api-Key",
+// "ssn" or "card_number". They are meant to catch most sensitive keys at the cost of
+// some false positives, such as "token_count".
+var DefaultSensitiveKeyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)passw(or)?d|passphrase|^pwd$`),
+	regexp.MustCompile(`(?i)secret`),
+	regexp.MustCompile(`(?i)token`),
+	regexp.MustCompile(`(?i)api[-_]?key`),
+	regexp.MustCompile(`(?i)private[-_]?key`),
+	regexp.MustCompile(`(?i)^(proxy[-_]?)?authorization$|^cookie$`),
+	regexp.MustCompile(`(?i)^ssn$|social[-_]?security`),
+	regexp.MustCompile(`(?i)credit[-_]?card|card[-_]?num(ber)?|^pan$|^cvv$|^cvc$`),
+}
+
+// WithHeuristicRedaction returns a new Logger that replaces with RedactedArg the values of
+// the keys of Data, at any depth, whose names match any of patterns, or any of
+// DefaultSensitiveKeyPatterns if no patterns are given, as well as the old and new values
+// of the changes of a ChangeInfo to fields whose names match. Unlike RedactDataKeys, it
+// catches sensitive keys that developers add without updating the redaction configuration.
+//
+// It is applied to all entries, after the redactor set with WithRedactor, if any, and
+// independently of it: setting or removing a redactor with WithRedactor, before or after,
+// keeps the heuristic. Calling WithHeuristicRedaction again replaces the patterns.
+//
+// The walk is as costly as RedactDataKeys: Data that is not made of maps and slices is
+// converted to its JSON form, a JSON round trip of the whole Data, and every key is then
+// matched against the patterns, so the cost grows with the size of Data times the number
+// of patterns. Loggers with large or deeply nested Data on hot paths should prefer the
+// exact keys of RedactDataKeys.
+func (l *Logger) WithHeuristicRedaction(patterns ...*regexp.Regexp) *Logger {
+	if len(patterns) == 0 {
+		patterns = DefaultSensitiveKeyPatterns
+	}
+	heuristic := redactDataKeysIf(func(key string) bool {
+		for _, pattern := range patterns {
+			if pattern.MatchString(key) {
+				return true
+			}
+		}
+		return false
+	})
+	newLogger := l.clone()
+	newLogger.heuristic = &redaction{redactor: heuristic}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithHeuristicRedaction", patterns)
+	}
+	return newLogger
+}
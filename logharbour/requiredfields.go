@@ -0,0 +1,62 @@
+package logharbour
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithRequiredFields returns a new Logger that requires entries of type logType to have
+// the named fields set, in addition to the usual validation. This enforces an audit
+// completeness policy where entries are created, for instance:
+//
+//	logger, _ = logger.WithRequiredFields(logharbour.Change, "who", "instance")
+//	logger, _ = logger.WithRequiredFields(logharbour.Activity, "op")
+//
+// Fields are named by their JSON name, among the string fields of LogEntry such as "who",
+// "op", "class", "instance" or "remote_ip". Calls accumulate: fields required for a type by
+// earlier calls stay required. Entries missing a required field fail validation and are
+// handled like other invalid entries, with an error naming the entry type and the
+// missing fields. An error is returned if a field name is unknown.
+func (l *Logger) WithRequiredFields(logType LogType, fields ...string) (*Logger, error) {
+	for _, field := range fields {
+		if _, ok := limitableFields[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	newLogger := l.clone()
+	newLogger.requiredFields = make(map[LogType][]string, len(l.requiredFields)+1)
+	for k, v := range l.requiredFields {
+		newLogger.requiredFields[k] = v
+	}
+	newLogger.requiredFields[logType] = append(append([]string(nil), l.requiredFields[logType]...), fields...)
+	if newLogger.tracing {
+		newLogger.traceMutation("WithRequiredFields", []any{logType, fields})
+	}
+	return newLogger, nil
+}
+
+// ClearRequiredFields returns a new Logger without any of the required fields set with
+// WithRequiredFields.
+func (l *Logger) ClearRequiredFields() *Logger {
+	newLogger := l.clone()
+	newLogger.requiredFields = nil
+	if newLogger.tracing {
+		newLogger.traceMutation("ClearRequiredFields", nil)
+	}
+	return newLogger
+}
+
+// checkRequiredFields returns an error naming the fields that are empty in entry.
+func checkRequiredFields(entry LogEntry, fields []string) error {
+	var missing []string
+	for _, field := range fields {
+		if *limitableFields[field](&entry) == "" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("log entry of type %s is missing required fields: %s", entry.Type, strings.Join(missing, ", "))
+}
@@ -0,0 +1,88 @@
+package logharbour
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryAttempt is the Data of the entries logged by a RetryLogger.
+type RetryAttempt struct {
+	RetryID     string `json:"retry_id"`                // Correlates the entries of one operation.
+	Attempt     int    `json:"attempt"`                 // Number of the attempt, starting at 1.
+	MaxAttempts int    `json:"max_attempts"`            // Maximum number of attempts.
+	NextDelayMs int64  `json:"next_delay_ms,omitempty"` // Delay before the next attempt, in milliseconds.
+}
+
+// RetryLogger runs operations with retries and logs every attempt in a standard shape,
+// instead of each retry loop logging in its own way. A failed attempt that will be retried
+// is logged with status Failure, its error, at least priority Warn and the delay before
+// the next attempt. The final outcome is logged as with LogResult: status Success, or
+// status Failure with priority Err once the attempts are exhausted. All the entries of an
+// operation carry a RetryAttempt with the same generated RetryID.
+//
+//	retrier := logharbour.NewRetryLogger(logger, 5, 100*time.Millisecond)
+//	err := retrier.Do(ctx, "publish order", func() error {
+//		return publisher.Publish(order)
+//	})
+type RetryLogger struct {
+	logger      *Logger
+	maxAttempts int
+	delay       time.Duration
+	maxDelay    time.Duration
+}
+
+// NewRetryLogger creates a RetryLogger that makes up to maxAttempts attempts, waiting
+// delay before the first retry and doubling the delay after each retry, up to one minute.
+// It logs with logger.
+func NewRetryLogger(logger *Logger, maxAttempts int, delay time.Duration) *RetryLogger {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &RetryLogger{logger: logger, maxAttempts: maxAttempts, delay: delay, maxDelay: time.Minute}
+}
+
+// Do calls fn until it succeeds, the attempts are exhausted or ctx is done, and returns
+// the last error of fn, joined with the error of ctx if ctx is done, so that errors.Is
+// matches both. message describes the operation and prefixes the messages of the entries.
+func (rl *RetryLogger) Do(ctx context.Context, message string, fn func() error) error {
+	retryID := newRandomID(8)
+	delay := rl.delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		data := RetryAttempt{RetryID: retryID, Attempt: attempt, MaxAttempts: rl.maxAttempts}
+		if err == nil {
+			rl.logger.LogResult(fmt.Sprintf("%s succeeded on attempt %d", message, attempt), nil, data)
+			return nil
+		}
+		if attempt == rl.maxAttempts {
+			rl.logger.LogResult(fmt.Sprintf("%s failed after %d attempts", message, attempt), err, data)
+			return err
+		}
+
+		data.NextDelayMs = delay.Milliseconds()
+		entry := rl.logger.newLogEntry(fmt.Sprintf("%s attempt %d failed, retrying", message, attempt), data)
+		entry.Type = Activity
+		entry.Status = Failure
+		entry.Error = err.Error()
+		if entry.Pri < Warn {
+			entry.Pri = Warn
+		}
+		rl.logger.log(entry)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			data.NextDelayMs = 0
+			err = errors.Join(err, ctx.Err())
+			rl.logger.LogResult(fmt.Sprintf("%s abandoned after %d attempts", message, attempt), err, data)
+			return err
+		}
+		if delay *= 2; delay > rl.maxDelay {
+			delay = rl.maxDelay
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSDFields lists the LogEntry fields rendered as SD-PARAMs by default, by JSON name.
+var defaultSDFields = []string{"module", "type", "pri", "who", "op", "class", "instance", "status", "remote_ip", "trace_id"}
+
+// rfc5424Facility is the syslog facility used by RFC5424Writer if none is configured (local0).
+const rfc5424Facility = 16
+
+// Maximum lengths of the RFC 5424 header fields and SD-NAMEs, in characters.
+const (
+	rfc5424MaxHostname = 255
+	rfc5424MaxAppName  = 48
+	rfc5424MaxSDName   = 32
+)
+
+// RFC5424Config holds the configuration for an RFC5424Writer.
+type RFC5424Config struct {
+	EnterpriseID int      // Private enterprise number used in the SD-ID, e.g. logharbour@32473
+	Facility     *int     // Syslog facility; defaults to local0 (16)
+	Fields       []string // JSON names of the LogEntry fields to render; defaults to defaultSDFields
+}
+
+// RFC5424Writer is an io.Writer that converts log entries to RFC 5424 syslog messages and
+// writes them to the next writer, typically a connection to a syslog server or SIEM.
+//
+// Each entry becomes one line of the form
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID - [logharbour@EID field="value" ...] MSG
+//
+// The selected LogEntry fields are rendered as SD-PARAMs of a single SD-ELEMENT whose
+// SD-ID is "logharbour@" followed by the enterprise ID. Each SD-PARAM is named after the
+// JSON name of the field (for example "who", "remote_ip" or "trace_id"), and its value is
+// the field's JSON value without quotes. Empty fields are omitted. As required by RFC 5424,
+// the characters '"', '\' and ']' in values are escaped with a backslash.
+// The message of the entry becomes MSG; the Data payload is not included.
+//
+// Every message is kept on a single line, for the non-transparent framing of syslog over
+// TCP: newlines in the message and in SD-PARAM values are replaced with a space. The
+// system and the app, written as HOSTNAME and APP-NAME, and the field names, written as
+// SD-NAMEs, are restricted to the printable ASCII characters RFC 5424 allows, others
+// being replaced with '_', and are truncated to the RFC limits of 255, 48 and 32
+// characters.
+//
+// The syslog severity is derived from the entry priority: Debug2-Debug0 map to debug (7),
+// Info to informational (6), Warn to warning (4), Err to error (3), Crit to critical (2)
+// and Sec to alert (1).
+type RFC5424Writer struct {
+	next     io.Writer
+	sdID     string
+	facility int
+	fields   []string
+	names    []string // SD-NAMEs of fields
+	procID   string
+}
+
+// NewRFC5424Writer creates an RFC5424Writer that writes to next.
+func NewRFC5424Writer(next io.Writer, cfg RFC5424Config) *RFC5424Writer {
+	w := &RFC5424Writer{
+		next:     next,
+		sdID:     "logharbour@" + strconv.Itoa(cfg.EnterpriseID),
+		facility: rfc5424Facility,
+		fields:   defaultSDFields,
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+	if cfg.Facility != nil {
+		w.facility = *cfg.Facility
+	}
+	if len(cfg.Fields) > 0 {
+		w.fields = cfg.Fields
+	}
+	w.names = make([]string, len(w.fields))
+	for i, field := range w.fields {
+		w.names[i] = headerValue(field, rfc5424MaxSDName, `= ]"`)
+	}
+	return w
+}
+
+// Write converts a serialized log entry to an RFC 5424 message and writes it to the next writer.
+// It implements io.Writer.
+func (w *RFC5424Writer) Write(p []byte) (n int, err error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return 0, err
+	}
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return 0, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<%d>1 %s %s %s %s - ",
+		w.facility*8+syslogSeverity(entry.Pri),
+		entry.When.UTC().Format(time.RFC3339Nano),
+		headerValue(entry.System, rfc5424MaxHostname, ""),
+		headerValue(entry.App, rfc5424MaxAppName, ""),
+		w.procID)
+
+	sb.WriteString("[" + w.sdID)
+	for i, field := range w.fields {
+		value := sdValue(fields[field])
+		if value == "" {
+			continue
+		}
+		sb.WriteString(" " + w.names[i] + `="` + escapeSDValue(newlineReplacer.Replace(value)) + `"`)
+	}
+	sb.WriteString("]")
+	if entry.Msg != "" {
+		sb.WriteString(" " + newlineReplacer.Replace(entry.Msg))
+	}
+	sb.WriteString("\n")
+
+	if _, err := io.WriteString(w.next, sb.String()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// syslogSeverity maps a LogPriority to a syslog severity level.
+func syslogSeverity(p LogPriority) int {
+	switch {
+	case p <= Debug0:
+		return 7
+	case p == Info:
+		return 6
+	case p == Warn:
+		return 4
+	case p == Err:
+		return 3
+	case p == Crit:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// sdValue returns the JSON value raw as a plain string: strings are unquoted,
+// other values are kept as written and null becomes empty.
+func sdValue(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// escapeSDValue escapes the characters that RFC 5424 requires to be escaped in PARAM-VALUE.
+func escapeSDValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// headerValue returns s as an RFC 5424 header field or SD-NAME of at most max characters:
+// characters other than printable ASCII, and those of excluded, are replaced with '_',
+// and an empty s becomes the NILVALUE "-".
+func headerValue(s string, max int, excluded string) string {
+	if s == "" {
+		return "-"
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if sb.Len() == max {
+			break
+		}
+		if r < 33 || r > 126 || strings.ContainsRune(excluded, r) {
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
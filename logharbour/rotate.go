@@ -0,0 +1,280 @@
+package logharbour
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of RotatingFileConfig.
+const (
+	defaultRotateMaxBytes     = 100 << 20
+	defaultRotateMaxBackups   = 5
+	defaultRotateCompressions = 1
+)
+
+// rotatedFileTime is the layout of the time suffix of rotated files, which sorts in time order.
+const rotatedFileTime = "20060102T150405.000000000"
+
+// ErrRotatingFileWriterClosed is returned by a RotatingFileWriter for the entries written after Close.
+var ErrRotatingFileWriterClosed = errors.New("rotating file writer closed")
+
+// RotatingFileConfig holds the configuration for a RotatingFileWriter.
+// Optional fields are pointers, which allows us to distinguish between a field that is not set and a field set with its zero value.
+type RotatingFileConfig struct {
+	MaxBytes        *int64 // Size beyond which the file is rotated; defaults to 100 MiB
+	MaxBackups      *int   // Number of rotated files kept, compressed or not; defaults to 5
+	Compress        bool   // Whether rotated files are gzipped in the background
+	MaxCompressions *int   // Maximum number of files compressed at the same time; defaults to 1
+}
+
+// RotatingFileWriter is an io.Writer that writes log entries to a file and rotates it
+// once it would grow beyond a maximum size: the file is renamed with the time of the
+// rotation as suffix, for example app.log.20240101T120000.000000000, and a new file is
+// started. Each entry is written whole to one file. Only the newest rotated files are
+// kept; older ones are deleted at every rotation.
+//
+// If Compress is set, rotated files are gzipped by background goroutines, at most
+// MaxCompressions at a time, so that Write is not slowed down: app.log.<time> becomes
+// app.log.<time>.gz once compressed. A compression that fails leaves the rotated file
+// as is and is reported to the last resort writer, since the Logger has moved on by
+// then. A rotated file deleted by the retention while it is being compressed is not
+// brought back by the compression.
+//
+// Close closes the file and waits for the compressions in progress to finish; entries
+// written after Close are rejected with ErrRotatingFileWriterClosed.
+//
+//	w, err := logharbour.NewRotatingFileWriter("/var/log/app.log", logharbour.RotatingFileConfig{Compress: true})
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//	logger := logharbour.NewLogger(lctx, "payments", w)
+type RotatingFileWriter struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	compress   bool
+
+	file        *os.File
+	size        int64
+	closed      bool
+	compressing map[string]bool // Rotated files being compressed; false once deleted by the retention
+	slots       chan struct{}   // Limits the compressions in progress
+	wg          sync.WaitGroup
+	mu          sync.Mutex // guards the fields above, and the rotated files
+}
+
+// NewRotatingFileWriter creates a RotatingFileWriter that writes to path, appending to the
+// file if it exists.
+func NewRotatingFileWriter(path string, cfg RotatingFileConfig) (*RotatingFileWriter, error) {
+	rw := &RotatingFileWriter{
+		path:        path,
+		maxBytes:    defaultRotateMaxBytes,
+		maxBackups:  defaultRotateMaxBackups,
+		compress:    cfg.Compress,
+		compressing: make(map[string]bool),
+	}
+	if cfg.MaxBytes != nil {
+		rw.maxBytes = *cfg.MaxBytes
+	}
+	if cfg.MaxBackups != nil {
+		rw.maxBackups = *cfg.MaxBackups
+	}
+	compressions := defaultRotateCompressions
+	if cfg.MaxCompressions != nil && *cfg.MaxCompressions > 0 {
+		compressions = *cfg.MaxCompressions
+	}
+	rw.slots = make(chan struct{}, compressions)
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// Write writes an encoded log entry to the file, rotating it first if the entry would
+// take it beyond the maximum size. It implements io.Writer.
+func (rw *RotatingFileWriter) Write(p []byte) (n int, err error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.closed {
+		return 0, ErrRotatingFileWriterClosed
+	}
+	if rw.size > 0 && rw.size+int64(len(p)) > rw.maxBytes {
+		if err := rw.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err = rw.file.Write(p)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Close closes the file and waits for the compressions in progress to finish.
+// Closing the writer again does nothing.
+func (rw *RotatingFileWriter) Close() error {
+	rw.mu.Lock()
+	if rw.closed {
+		rw.mu.Unlock()
+		return nil
+	}
+	rw.closed = true
+	err := rw.file.Close()
+	rw.mu.Unlock()
+	rw.wg.Wait()
+	return err
+}
+
+// open opens the file for appending and records its size.
+func (rw *RotatingFileWriter) open() error {
+	file, err := os.OpenFile(rw.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rw.file, rw.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the file with the current time as suffix, starts a new file, deletes
+// the rotated files beyond the retention and starts compressing the rotated file.
+// It must be called with rw.mu held.
+func (rw *RotatingFileWriter) rotate() error {
+	if err := rw.file.Close(); err != nil {
+		return err
+	}
+	rotated := rw.path + "." + time.Now().UTC().Format(rotatedFileTime)
+	renameErr := os.Rename(rw.path, rotated)
+	// The file is reopened even if the rename failed, so that the writer keeps working.
+	if err := rw.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	if rw.compress {
+		rw.compressing[rotated] = true
+		rw.wg.Add(1)
+		go rw.compressFile(rotated)
+	}
+	rw.removeOldBackups()
+	return nil
+}
+
+// removeOldBackups deletes the rotated files beyond the retention, compressed or not,
+// and marks the ones being compressed as deleted. It must be called with rw.mu held.
+func (rw *RotatingFileWriter) removeOldBackups() {
+	backups, err := rw.backups()
+	if err != nil {
+		writeLastResort(fmt.Errorf("listing rotated log files of %s: %w", rw.path, err), LogEntry{})
+		return
+	}
+	if len(backups) <= rw.maxBackups {
+		return
+	}
+	for _, backup := range backups[:len(backups)-rw.maxBackups] {
+		if _, ok := rw.compressing[backup]; ok {
+			rw.compressing[backup] = false
+		}
+		for _, name := range []string{backup, backup + ".gz"} {
+			if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+				writeLastResort(fmt.Errorf("removing rotated log file: %w", err), LogEntry{})
+			}
+		}
+	}
+}
+
+// backups returns the paths of the rotated files, without the .gz suffix of the
+// compressed ones, from the oldest to the newest.
+func (rw *RotatingFileWriter) backups() ([]string, error) {
+	dir, base := filepath.Split(rw.path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var backups []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), base+".")
+		if !ok {
+			continue
+		}
+		suffix = strings.TrimSuffix(suffix, ".gz")
+		if _, err := time.Parse(rotatedFileTime, suffix); err != nil {
+			continue
+		}
+		backup := rw.path + "." + suffix
+		if !seen[backup] {
+			seen[backup] = true
+			backups = append(backups, backup)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// compressFile gzips the rotated file name to name.gz and removes name, once a
+// compression slot is free, unless the retention deletes the file first.
+func (rw *RotatingFileWriter) compressFile(name string) {
+	defer rw.wg.Done()
+	rw.slots <- struct{}{}
+	defer func() { <-rw.slots }()
+
+	// The file is opened with rw.mu held, so that the retention cannot delete it in between.
+	rw.mu.Lock()
+	if !rw.compressing[name] {
+		delete(rw.compressing, name)
+		rw.mu.Unlock()
+		return
+	}
+	src, err := os.Open(name)
+	rw.mu.Unlock()
+	if err == nil {
+		err = gzipFile(src, name+".gz.tmp")
+		src.Close()
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	deleted := !rw.compressing[name]
+	delete(rw.compressing, name)
+	if err == nil && !deleted {
+		if err = os.Rename(name+".gz.tmp", name+".gz"); err == nil {
+			err = os.Remove(name)
+		}
+	}
+	if err != nil || deleted {
+		os.Remove(name + ".gz.tmp")
+	}
+	if err != nil {
+		writeLastResort(fmt.Errorf("compressing rotated log file %s: %w", name, err), LogEntry{})
+	}
+}
+
+// gzipFile writes the gzipped content of src to the file dst.
+func gzipFile(src io.Reader, dst string) error {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
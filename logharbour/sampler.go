@@ -0,0 +1,56 @@
+package logharbour
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// TraceSampler keeps or drops log entries by trace, so that either all entries of a
+// trace are logged or none of them are. The decision is made by hashing the trace ID,
+// which makes it consistent across loggers, goroutines and processes using the same rate.
+// Entries with priority Crit or Sec, and entries without a trace ID, are always kept.
+type TraceSampler struct {
+	threshold uint64
+}
+
+// NewTraceSampler creates a TraceSampler that keeps approximately the given fraction of
+// traces. A rate of 0 drops all sampled traces and a rate of 1 or more keeps all of them.
+func NewTraceSampler(rate float64) *TraceSampler {
+	var threshold uint64
+	switch {
+	case rate >= 1:
+		threshold = math.MaxUint64
+	case rate > 0:
+		threshold = uint64(rate * math.MaxUint64)
+	}
+	return &TraceSampler{threshold: threshold}
+}
+
+// Keep reports whether entry should be logged.
+func (ts *TraceSampler) Keep(entry LogEntry) bool {
+	if entry.Pri >= Crit || entry.TraceID == "" {
+		return true
+	}
+	return ts.KeepTrace(entry.TraceID)
+}
+
+// KeepTrace reports whether the entries of the trace with the given ID should be logged.
+func (ts *TraceSampler) KeepTrace(traceID string) bool {
+	if ts.threshold == math.MaxUint64 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(traceID))
+	return h.Sum64() < ts.threshold
+}
+
+// WithTraceSampler returns a new Logger that logs only the entries kept by sampler.
+// Pass nil to disable sampling.
+func (l *Logger) WithTraceSampler(sampler *TraceSampler) *Logger {
+	newLogger := l.clone()
+	newLogger.sampler = sampler
+	if newLogger.tracing {
+		newLogger.traceMutation("WithTraceSampler", sampler)
+	}
+	return newLogger
+}
@@ -0,0 +1,113 @@
+package logharbour
+
+import "time"
+
+// ScheduleRule sets the minimum log priority for a recurring time-of-day window.
+//
+// Start and End are times of day on the wall clock, as durations since midnight, e.g.
+// 9*time.Hour for 09:00, so a window keeps its hours on the days the clocks change for
+// daylight saving time. A window whose End is not after its Start wraps past midnight,
+// so Start 22h and End 6h covers 22:00 to 06:00 the next morning. Days lists the days
+// on which the window starts; an empty list means every day. Times are evaluated in
+// Location, or in the local time zone if Location is nil, so a rule for 09:00-18:00 in
+// Asia/Kolkata follows that zone regardless of the time zone of the host.
+type ScheduleRule struct {
+	Days        []time.Weekday
+	Start       time.Duration
+	End         time.Duration
+	Location    *time.Location
+	MinPriority LogPriority
+}
+
+// schedule is a compiled list of rules.
+type schedule struct {
+	rules []compiledRule
+}
+
+// compiledRule is a ScheduleRule with its days converted to a bit mask for cheap matching.
+type compiledRule struct {
+	days        uint8 // bit i is set if the window starts on time.Weekday(i)
+	start, end  time.Duration
+	location    *time.Location
+	minPriority LogPriority
+}
+
+// WithSchedule returns a new Logger whose minimum log priority follows rules.
+// When logging, the rules are checked in order and the first rule whose window contains
+// the current time sets the minimum priority, overriding the LoggerContext's minimum.
+// If no rule matches, the LoggerContext's minimum applies. Pass nil to remove the schedule.
+//
+// Example: log everything from Debug0 during business hours on weekdays, and only
+// warnings otherwise.
+//
+//	logger = logger.WithSchedule([]logharbour.ScheduleRule{{
+//		Days:        []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+//		Start:       9 * time.Hour,
+//		End:         18 * time.Hour,
+//		MinPriority: logharbour.Debug0,
+//	}, {
+//		MinPriority: logharbour.Warn, // Start == End: the whole day
+//	}})
+func (l *Logger) WithSchedule(rules []ScheduleRule) *Logger {
+	newLogger := l.clone()
+	newLogger.schedule = nil
+	if len(rules) > 0 {
+		s := &schedule{rules: make([]compiledRule, len(rules))}
+		for i, r := range rules {
+			days := uint8(0x7f)
+			if len(r.Days) > 0 {
+				days = 0
+				for _, d := range r.Days {
+					days |= 1 << uint(d)
+				}
+			}
+			location := r.Location
+			if location == nil {
+				location = time.Local
+			}
+			s.rules[i] = compiledRule{days: days, start: r.Start, end: r.End, location: location, minPriority: r.MinPriority}
+		}
+		newLogger.schedule = s
+	}
+	if newLogger.tracing {
+		newLogger.traceMutation("WithSchedule", rules)
+	}
+	return newLogger
+}
+
+// minPriorityAt returns the minimum priority set by the first rule matching t.
+func (s *schedule) minPriorityAt(t time.Time) (LogPriority, bool) {
+	for _, r := range s.rules {
+		if r.matches(t) {
+			return r.minPriority, true
+		}
+	}
+	return 0, false
+}
+
+// matches reports whether t falls within the rule's window.
+func (r compiledRule) matches(t time.Time) bool {
+	t = t.In(r.location)
+	hour, min, sec := t.Clock()
+	offset := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute +
+		time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())
+	day := t.Weekday()
+
+	if r.end > r.start {
+		return r.startsOn(day) && offset >= r.start && offset < r.end
+	}
+	// The window wraps past midnight: it covers [start, 24h) on its start day
+	// and [0, end) on the following day.
+	if offset >= r.start {
+		return r.startsOn(day)
+	}
+	if offset < r.end {
+		return r.startsOn((day + 6) % 7)
+	}
+	return false
+}
+
+// startsOn reports whether the window starts on day.
+func (r compiledRule) startsOn(day time.Weekday) bool {
+	return r.days&(1<<uint(day)) != 0
+}
@@ -0,0 +1,101 @@
+package logharbour
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// SelfConfig is the Data of the entry logged by LogSelfConfig. It describes the effective
+// configuration of a Logger.
+type SelfConfig struct {
+	App               string      `json:"app"`
+	Module            string      `json:"module"`
+	Env               string      `json:"env"`
+	Priority          LogPriority `json:"priority"`                      // Priority of the Logger's entries.
+	MinPriority       LogPriority `json:"min_priority"`                  // Of the context, or of the matching schedule rule.
+	LoggerMinPriority LogPriority `json:"logger_min_priority,omitempty"` // Per-Logger override, e.g. from WithBoostedLevel.
+	ScheduleRules     int         `json:"schedule_rules"`                // Number of schedule rules.
+	DebugMode         bool        `json:"debug_mode"`
+	DebugCapture      bool        `json:"debug_capture"` // False after DisableDebugCapture.
+	Paused            bool        `json:"paused"`
+	SampleRate        *float64    `json:"sample_rate,omitempty"` // Fraction of traces kept; absent without a sampler.
+	DryRun            bool        `json:"dry_run"`
+	Writer            string      `json:"writer"` // The writer chain, by type.
+}
+
+// LogSelfConfig logs an activity entry describing the Logger's effective configuration,
+// as a SelfConfig, to help answer "why isn't this logging" questions. It is meant to be
+// called once at startup, after the Logger is set up.
+//
+// The writer chain is described by the types of its writers and encoders only, such as
+//
+//	*logharbour.FallbackWriter(*logharbour.EncodedWriter[logharbour.JSONEncoder](*os.File), *os.File)
+//
+// and never by their field values, so credentials held by writers are not logged.
+//
+// The entry is logged at the Logger's priority, raised to the minimum priority in effect
+// if it is lower, so that it is written even when most entries are filtered out. It is
+// still subject to a pause.
+func (l *Logger) LogSelfConfig() {
+	minPriority := l.baseMinPriority()
+	config := SelfConfig{
+		App:               l.app,
+		Module:            l.module,
+		Env:               l.env,
+		Priority:          l.pri,
+		MinPriority:       minPriority,
+		LoggerMinPriority: l.minPriority,
+		DebugMode:         l.context.IsDebugMode(),
+		DebugCapture:      !isDebugCaptureDisabled(),
+		DryRun:            l.dryRun,
+		Writer:            describeWriter(l.writer),
+	}
+	if l.schedule != nil {
+		config.ScheduleRules = len(l.schedule.rules)
+	}
+	if l.pause != nil {
+		l.pause.mu.Lock()
+		config.Paused = l.pause.paused
+		l.pause.mu.Unlock()
+	}
+	if l.sampler != nil {
+		rate := float64(l.sampler.threshold) / math.MaxUint64
+		config.SampleRate = &rate
+	}
+
+	if l.minPriority != 0 && l.minPriority < minPriority {
+		minPriority = l.minPriority
+	}
+	entry := l.newLogEntry("logger configuration", config)
+	entry.Type = Activity
+	if entry.Pri < minPriority {
+		entry.Pri = minPriority
+	}
+	l.log(entry)
+}
+
+// describeWriter describes the writer chain of w by the types of its writers and encoders.
+func describeWriter(w io.Writer) string {
+	var writers []io.Writer
+	switch cw := w.(type) {
+	case *FallbackWriter:
+		writers = cw.writers
+	case *MultiWriter:
+		writers = cw.writers
+	case *EncodedWriter:
+		return fmt.Sprintf("%T[%T](%s)", cw, cw.enc, describeWriter(cw.next))
+	case *PriorityWriter:
+		return fmt.Sprintf("%T[%v](%s)", cw, cw.minPriority, describeWriter(cw.next))
+	case *SplitWriter:
+		return fmt.Sprintf("%T[%v](%s, %s)", cw, cw.syncPriority, describeWriter(cw.durable), describeWriter(cw.buffered))
+	default:
+		return fmt.Sprintf("%T", w)
+	}
+	descriptions := make([]string, len(writers))
+	for i, w := range writers {
+		descriptions[i] = describeWriter(w)
+	}
+	return fmt.Sprintf("%T(%s)", w, strings.Join(descriptions, ", "))
+}
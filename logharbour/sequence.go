@@ -0,0 +1,137 @@
+package logharbour
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// SetSequencing makes all loggers sharing this context number their entries with a
+// sequence number, in the Seq field, that increases by one with every entry created
+// through this LoggerContext. It is off by default. Entries filtered out by priority or
+// sampling still use a number, so the sequence may have gaps.
+//
+// Each LoggerContext counts from 1 again, and so does a process that restarts, so the
+// entries also carry in SeqSource a random ID of the sequence, generated once per
+// LoggerContext. Seq only orders the entries that share a SeqSource.
+//
+// Unlike When, which comes from the clock of the host and may jump or differ between
+// hosts, Seq always reflects the order in which the entries of one sequence were logged.
+// When logs of many hosts are merged, we recommend enabling sequencing and ordering the
+// entries with a Reorderer: it orders the entries of each sequence by Seq, and the
+// entries of different sequences by When within a tolerance that covers the expected
+// clock skew between hosts, as kept by NTP for instance.
+func (lc *LoggerContext) SetSequencing(enable bool) {
+	var val int32
+	if enable {
+		val = 1
+	}
+	atomic.StoreInt32(&lc.sequencing, val)
+}
+
+// nextSeq returns the next sequence number and the ID of the sequence, or 0 and "" if
+// sequencing is off.
+func (lc *LoggerContext) nextSeq() (uint64, string) {
+	if atomic.LoadInt32(&lc.sequencing) == 0 {
+		return 0, ""
+	}
+	lc.seqSourceOnce.Do(func() { lc.seqSource = newRandomID(8) })
+	return lc.seq.Add(1), lc.seqSource
+}
+
+// Reorderer puts log entries collected from many hosts back in order despite clock skew.
+// Entries are added in the order they are received and released in order once no entry
+// received later can precede them, that is, once an entry at least tolerance later has
+// been received. Set tolerance to the largest clock skew expected between hosts plus the
+// largest delivery delay.
+//
+// Entries are released in order of When, except that the entries of the same sequence,
+// identified by System, App and SeqSource, always keep the order of their Seq, even when
+// the clock of the process went backwards. Entries without SeqSource are grouped by
+// System and App alone, and entries without Seq keep the order they were added in.
+// Entries received more than tolerance late are released as soon as possible, out of
+// order.
+//
+// A Reorderer is not safe for concurrent use.
+type Reorderer struct {
+	tolerance time.Duration
+	sources   []sequenceKey // Sources with pending entries, in the order they were first seen
+	queues    map[sequenceKey]*sequenceQueue
+	latest    time.Time
+}
+
+// sequenceKey identifies the sequence an entry belongs to.
+type sequenceKey struct{ system, app, seqSource string }
+
+// sequenceQueue holds the pending entries of one sequence, in the order to release them.
+type sequenceQueue struct {
+	entries  []LogEntry
+	addOrder bool // Set once an entry without Seq was added; entries are then appended
+}
+
+// NewReorderer creates a Reorderer that waits for entries up to tolerance late.
+func NewReorderer(tolerance time.Duration) *Reorderer {
+	return &Reorderer{tolerance: tolerance, queues: make(map[sequenceKey]*sequenceQueue)}
+}
+
+// Add adds entry and returns the entries that can now be released, in order.
+func (r *Reorderer) Add(entry LogEntry) []LogEntry {
+	key := sequenceKey{entry.System, entry.App, entry.SeqSource}
+	queue, ok := r.queues[key]
+	if !ok {
+		queue = &sequenceQueue{}
+		r.queues[key] = queue
+		r.sources = append(r.sources, key)
+	}
+	queue.add(entry)
+	if entry.When.After(r.latest) {
+		r.latest = entry.When
+	}
+	watermark := r.latest.Add(-r.tolerance)
+	return r.release(func(next LogEntry) bool { return !next.When.After(watermark) })
+}
+
+// Flush returns all the entries not yet released, in order.
+func (r *Reorderer) Flush() []LogEntry {
+	return r.release(func(LogEntry) bool { return true })
+}
+
+// release merges the queues in order of When and returns their entries, up to the first
+// one for which ready returns false.
+func (r *Reorderer) release(ready func(next LogEntry) bool) []LogEntry {
+	var released []LogEntry
+	for len(r.sources) > 0 {
+		next := 0
+		for i := 1; i < len(r.sources); i++ {
+			if r.queues[r.sources[i]].entries[0].When.Before(r.queues[r.sources[next]].entries[0].When) {
+				next = i
+			}
+		}
+		key := r.sources[next]
+		queue := r.queues[key]
+		if !ready(queue.entries[0]) {
+			break
+		}
+		released = append(released, queue.entries[0])
+		queue.entries = queue.entries[1:]
+		if len(queue.entries) == 0 {
+			delete(r.queues, key)
+			r.sources = append(r.sources[:next], r.sources[next+1:]...)
+		}
+	}
+	return released
+}
+
+// add inserts entry in Seq order, or appends it if some entry of the queue has no Seq.
+func (q *sequenceQueue) add(entry LogEntry) {
+	if entry.Seq == 0 {
+		q.addOrder = true
+	}
+	i := len(q.entries)
+	if !q.addOrder {
+		i = sort.Search(len(q.entries), func(i int) bool { return q.entries[i].Seq > entry.Seq })
+	}
+	q.entries = append(q.entries, LogEntry{})
+	copy(q.entries[i+1:], q.entries[i:])
+	q.entries[i] = entry
+}
@@ -0,0 +1,157 @@
+package logharbour
+
+import (
+	"container/list"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultMaxOpenShards is the default maximum number of shard writers a ShardingWriter keeps open.
+const defaultMaxOpenShards = 64
+
+// ShardingWriterOption configures a ShardingWriter.
+type ShardingWriterOption func(*ShardingWriter)
+
+// WithMaxOpenShards sets the maximum number of shard writers kept open at the same time.
+func WithMaxOpenShards(n int) ShardingWriterOption {
+	return func(sw *ShardingWriter) {
+		sw.maxOpen = n
+	}
+}
+
+// WithShardIdleTimeout sets how long a shard writer may go unused before it is closed.
+func WithShardIdleTimeout(d time.Duration) ShardingWriterOption {
+	return func(sw *ShardingWriter) {
+		sw.idleTimeout = d
+	}
+}
+
+// ShardingWriter routes each log entry to a writer chosen by a key computed from the
+// entry, for example to write the logs of each tenant of a multi-tenant system to a
+// separate file:
+//
+//	writer := logharbour.NewShardingWriter(
+//		func(e logharbour.LogEntry) string { return e.Who },
+//		func(key string) (io.Writer, error) {
+//			return os.OpenFile("logs/"+key+".log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+//		},
+//	)
+//
+// Shard writers are created by the factory on first use and cached. To stay within the
+// process's open file limit, at most 64 shard writers are kept open by default (see
+// WithMaxOpenShards): when a new shard is needed beyond that, the least recently used
+// shard is evicted. With WithShardIdleTimeout, shards that have not been written to for
+// the timeout are evicted too; idle shards are looked for on every write. Evicted shard
+// writers are closed if they implement io.Closer, and are created again by the factory
+// when their key is seen again, so the factory must append to existing files.
+//
+// Entries are passed to shard writers unencoded if they accept structured entries, such
+// as an EncodedWriter, and as JSON otherwise. If the factory fails, the entry is not
+// written and the error is returned.
+type ShardingWriter struct {
+	keyFn       func(LogEntry) string
+	factory     func(key string) (io.Writer, error)
+	maxOpen     int
+	idleTimeout time.Duration
+
+	shards map[string]*list.Element
+	lru    *list.List // of *shard, most recently used first
+	mu     sync.Mutex
+}
+
+// shard is a cached shard writer.
+type shard struct {
+	key      string
+	writer   io.Writer
+	lastUsed time.Time
+}
+
+// NewShardingWriter creates a ShardingWriter that routes entries by keyFn to the writers created by factory.
+func NewShardingWriter(keyFn func(LogEntry) string, factory func(key string) (io.Writer, error), opts ...ShardingWriterOption) *ShardingWriter {
+	sw := &ShardingWriter{
+		keyFn:   keyFn,
+		factory: factory,
+		maxOpen: defaultMaxOpenShards,
+		shards:  make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	for _, opt := range opts {
+		opt(sw)
+	}
+	return sw
+}
+
+// Write decodes a JSON log entry and writes it to its shard. It implements io.Writer.
+func (sw *ShardingWriter) Write(p []byte) (n int, err error) {
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return 0, err
+	}
+	if err := sw.writeEntry(entry); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry writes entry to the shard writer for its key.
+func (sw *ShardingWriter) writeEntry(entry LogEntry) error {
+	key := sw.keyFn(entry)
+	now := time.Now()
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.evictIdle(now)
+
+	var s *shard
+	if elem, ok := sw.shards[key]; ok {
+		sw.lru.MoveToFront(elem)
+		s = elem.Value.(*shard)
+	} else {
+		writer, err := sw.factory(key)
+		if err != nil {
+			return err
+		}
+		for sw.maxOpen > 0 && sw.lru.Len() >= sw.maxOpen {
+			sw.evict(sw.lru.Back())
+		}
+		s = &shard{key: key, writer: writer}
+		sw.shards[key] = sw.lru.PushFront(s)
+	}
+	s.lastUsed = now
+	return writeEntryTo(s.writer, entry)
+}
+
+// Close closes all open shard writers that implement io.Closer and returns the first error.
+func (sw *ShardingWriter) Close() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	var firstErr error
+	for sw.lru.Len() > 0 {
+		if err := sw.evict(sw.lru.Back()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// evictIdle evicts the shards that have not been used within the idle timeout.
+func (sw *ShardingWriter) evictIdle(now time.Time) {
+	if sw.idleTimeout <= 0 {
+		return
+	}
+	for elem := sw.lru.Back(); elem != nil && now.Sub(elem.Value.(*shard).lastUsed) > sw.idleTimeout; elem = sw.lru.Back() {
+		sw.evict(elem)
+	}
+}
+
+// evict removes a shard from the cache and closes its writer.
+func (sw *ShardingWriter) evict(elem *list.Element) error {
+	s := sw.lru.Remove(elem).(*shard)
+	delete(sw.shards, s.key)
+	if c, ok := s.writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package logharbour
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CloseWithTimeout flushes and closes the writer chain of the Logger, as FlushOnSignal
+// does, but gives up after d so that a log sink that is down cannot keep the process
+// from exiting. The writers are flushed and closed concurrently. The returned error joins
+// the errors of the writers that failed and lists the writers that did not finish in
+// time, by their position in the chain and their type.
+//
+// A writer that did not finish is abandoned, not interrupted: its flush keeps running in
+// the background, and the entries it still buffers, for instance in a CloudWatchWriter,
+// may be lost when the process exits. Entries logged after CloseWithTimeout go to closed
+// writers and are likely lost too.
+func (l *Logger) CloseWithTimeout(d time.Duration) error {
+	writers := chainWriters(l.writer)
+	results := make(chan error, len(writers))
+	done := make([]bool, len(writers))
+	finished := make(chan int, len(writers))
+	for i, w := range writers {
+		i, w := i, w
+		go func() {
+			if err := flushAndCloseWriter(w); err != nil {
+				results <- fmt.Errorf("writer %d (%T): %w", i, w, err)
+			}
+			finished <- i
+		}()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	var errs []error
+wait:
+	for remaining := len(writers); remaining > 0; remaining-- {
+		select {
+		case i := <-finished:
+			done[i] = true
+		case <-timer.C:
+			for i, w := range writers {
+				if !done[i] {
+					errs = append(errs, fmt.Errorf("writer %d (%T) did not finish within %v", i, w, d))
+				}
+			}
+			break wait
+		}
+	}
+	// Abandoned writers may still report an error later, so results is not closed.
+	for {
+		select {
+		case err := <-results:
+			errs = append(errs, err)
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
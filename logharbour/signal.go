@@ -0,0 +1,144 @@
+package logharbour
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+// FlushOnSignal installs a handler that flushes and closes the writer chain of logger
+// when one of signals is received, so that entries buffered by asynchronous writers,
+// such as CloudWatchWriter, are not lost on shutdown. If no signals are given, it handles
+// os.Interrupt and syscall.SIGTERM.
+//
+// After flushing, the handler uninstalls itself and raises the signal again, so that the
+// default behaviour, normally terminating the process, takes place. Writers are flushed
+// if they have a Flush() error method and closed if they implement io.Closer; all the
+// writers of a FallbackWriter or MultiWriter are handled. A writer found more than once
+// in the chain is handled once, os.Stdout and os.Stderr are left open, and a writer
+// already closed, for instance by CloseWithTimeout, is not reported as failing.
+//
+// If the application has its own handler for the same signals (signal.Notify), both are
+// notified, and since Go then does not run the default behaviour, the process keeps
+// running: the application is responsible for exiting, and entries it logs after the
+// flush may be lost because the writers are closed. Such applications should rather flush
+// from their own shutdown path.
+//
+// The returned stop function uninstalls the handler without flushing. It may be called
+// more than once.
+func FlushOnSignal(logger *Logger, signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case sig := <-ch:
+			signal.Stop(ch)
+			logger.mu.Lock()
+			flushAndClose(logger.writer)
+			logger.mu.Unlock()
+			raise(sig)
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// flushAndClose flushes and closes the writers of the chain of w.
+// Errors are written to the last resort writer.
+func flushAndClose(w io.Writer) {
+	for _, w := range chainWriters(w) {
+		if err := flushAndCloseWriter(w); err != nil {
+			writeLastResort(err, LogEntry{})
+		}
+	}
+}
+
+// chainWriters returns the writers at the end of the chain of w: the writers of a
+// FallbackWriter or MultiWriter and the writer wrapped by an EncodedWriter or a
+// PriorityWriter, recursively, or w itself. A writer found more than once is returned once.
+func chainWriters(w io.Writer) []io.Writer {
+	var writers []io.Writer
+	switch w := w.(type) {
+	case *FallbackWriter:
+		writers = w.writers
+	case *MultiWriter:
+		writers = w.writers
+	case *EncodedWriter:
+		writers = []io.Writer{w.next}
+	case *PriorityWriter:
+		writers = []io.Writer{w.next}
+	default:
+		return []io.Writer{w}
+	}
+	var chain []io.Writer
+	for _, w := range writers {
+		for _, w := range chainWriters(w) {
+			if !containsWriter(chain, w) {
+				chain = append(chain, w)
+			}
+		}
+	}
+	return chain
+}
+
+// containsWriter reports whether writers contains w.
+func containsWriter(writers []io.Writer, w io.Writer) bool {
+	if w == nil || !reflect.TypeOf(w).Comparable() {
+		return false
+	}
+	for _, other := range writers {
+		if other == w {
+			return true
+		}
+	}
+	return false
+}
+
+// flushAndCloseWriter flushes w if it has a Flush() error method and closes it if it
+// implements io.Closer. It returns the first error. The standard streams, which the rest
+// of the process still uses, are left alone, and closing a closed file is not an error.
+func flushAndCloseWriter(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+	var err error
+	if f, ok := w.(interface{ Flush() error }); ok {
+		err = f.Flush()
+	}
+	if c, ok := w.(io.Closer); ok {
+		if closeErr := c.Close(); err == nil && !errors.Is(closeErr, os.ErrClosed) {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// raise sends sig to the current process. If that is not possible, the process exits.
+func raise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,68 @@
+package logharbour
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Span is a timed operation logged as a single completion entry, for lightweight
+// hierarchical tracing through logs only. Spans nest: a child span shares the trace ID
+// of its parent and records the parent's span ID, so the call tree can be reconstructed
+// from the trace_id, span_id and parent_span_id fields of the entries.
+//
+//	span := logger.StartSpan("checkout")
+//	child := span.Child("charge-card")
+//	err := charge(child.Logger())
+//	child.End(err)
+//	span.End(nil)
+type Span struct {
+	logger *Logger
+	start  time.Time
+	once   sync.Once
+}
+
+// StartSpan starts a span for op. If the Logger already carries a span, for instance
+// because it was returned by Span.Logger, the new span is a child of that span.
+// The span's trace ID is the Logger's trace ID, or a new random one if it has none.
+func (l *Logger) StartSpan(op string) *Span {
+	traceID := l.traceID
+	if traceID == "" {
+		traceID = newRandomID(16)
+	}
+	newLogger := l.WithOp(op).WithTraceID(traceID)
+	newLogger.parentSpanID = l.spanID
+	newLogger.spanID = newRandomID(8)
+	return &Span{logger: newLogger, start: time.Now()}
+}
+
+// Child starts a span for op that is a child of s.
+func (s *Span) Child(op string) *Span {
+	return s.logger.StartSpan(op)
+}
+
+// Logger returns a Logger whose entries carry the span's op, trace ID and span IDs.
+func (s *Span) Logger() *Logger {
+	return s.logger
+}
+
+// End logs the completion entry of the span, as LogResult does, with the span's
+// duration in milliseconds in its data. Only the first call logs an entry.
+func (s *Span) End(err error) {
+	s.once.Do(func() {
+		duration := time.Since(s.start)
+		s.logger.LogResult("span "+s.logger.op+" completed", err, map[string]any{
+			"duration_ms": duration.Milliseconds(),
+		})
+	})
+}
+
+// newRandomID generates a random ID of n bytes encoded as hex.
+func newRandomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,251 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultSplitBuffer = 10000
+
+// ErrSplitWriterClosed is returned by a SplitWriter for the entries written after Close.
+var ErrSplitWriterClosed = errors.New("split writer closed")
+
+// SplitWriterConfig holds the configuration for a SplitWriter.
+// Optional fields are pointers, which allows us to distinguish between a field that is not set and a field set with its zero value.
+type SplitWriterConfig struct {
+	SyncPriority *LogPriority // Entries at or above this priority are written synchronously; defaults to Err
+	BufferSize   *int         // Maximum number of entries waiting for the asynchronous writer; defaults to 10000
+}
+
+// SplitWriter sends the entries of one Logger down two paths by priority, so that the
+// entries that must not be lost are written durably while the bulk of the entries does
+// not slow the application down:
+//
+//   - entries at or above the sync priority (Err by default, so Err, Crit and Sec) are
+//     written to the durable writer before Write returns, and its error, if any, is
+//     returned to the Logger, which then reports the entry as usual;
+//   - the other entries are queued and written to the buffered writer by a background
+//     goroutine. Write never waits for them: if the queue is full, the entry is dropped
+//     and counted in Dropped. Errors of the buffered writer are written to the last
+//     resort writer, since the Logger has moved on by then.
+//
+// The durable writer should be one that does not buffer, such as an *os.File, possibly
+// wrapped in a FallbackWriter; the buffered writer may be anything, since it never holds
+// up the Logger. Both may be EncodedWriters. The paths are independent, so an entry on
+// the durable path may be written before lower priority entries logged earlier.
+//
+//	w := logharbour.NewSplitWriter(auditFile, logharbour.NewEncodedWriter(os.Stdout, logharbour.ConsoleEncoder{}), logharbour.SplitWriterConfig{})
+//	defer w.Close()
+//	logger := logharbour.NewLogger(lctx, "payments", w)
+//
+// Flush drains the queue and then flushes both writers, and Close does the same before
+// stopping the goroutine and closing both writers; FlushOnSignal and CloseWithTimeout
+// handle a SplitWriter in the chain of a Logger that way.
+type SplitWriter struct {
+	durable      io.Writer
+	buffered     io.Writer
+	syncPriority LogPriority
+
+	queue   chan splitItem
+	done    chan struct{} // closed by Close
+	stopped chan struct{} // closed when the goroutine exits
+	closed  atomic.Bool
+	closeMu sync.RWMutex // held for reading by writes, so that Close waits for them
+	dropped atomic.Uint64
+	mu      sync.Mutex // serializes writes to the durable writer
+}
+
+// splitItem is an entry for the buffered writer, with its acknowledgment callback, if
+// any, or, if flushed is set, a flush marker.
+type splitItem struct {
+	entry   LogEntry
+	ack     func(error)
+	flushed chan struct{}
+}
+
+// NewSplitWriter creates a SplitWriter that writes the entries at or above the sync
+// priority to durable and the others, in the background, to buffered.
+// The writer runs until Close is called.
+func NewSplitWriter(durable, buffered io.Writer, cfg SplitWriterConfig) *SplitWriter {
+	sw := &SplitWriter{
+		durable:      durable,
+		buffered:     buffered,
+		syncPriority: Err,
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	if cfg.SyncPriority != nil {
+		sw.syncPriority = *cfg.SyncPriority
+	}
+	size := defaultSplitBuffer
+	if cfg.BufferSize != nil {
+		size = *cfg.BufferSize
+	}
+	sw.queue = make(chan splitItem, size)
+	go sw.run()
+	return sw
+}
+
+// Write decodes a JSON log entry and writes it down the path of its priority.
+// It implements io.Writer.
+func (sw *SplitWriter) Write(p []byte) (n int, err error) {
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return 0, err
+	}
+	if err := sw.writeEntry(entry); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry writes entry to the durable writer or queues it for the buffered writer.
+func (sw *SplitWriter) writeEntry(entry LogEntry) error {
+	return sw.write(entry, nil)
+}
+
+// writeEntryAck writes entry as writeEntry does and calls ack once it is written.
+func (sw *SplitWriter) writeEntryAck(entry LogEntry, ack func(error)) {
+	if err := sw.write(entry, ack); err != nil {
+		writeLastResort(err, entry)
+	}
+}
+
+// write writes entry to the durable writer or queues it for the buffered writer, and
+// calls ack, if not nil, with the outcome, right away or once the queued entry is written.
+func (sw *SplitWriter) write(entry LogEntry, ack func(error)) error {
+	queued, err := sw.writeOrQueue(entry, ack)
+	if queued {
+		return nil
+	}
+	// ack is called without closeMu held, since it may log to this writer.
+	acked(ack, err)
+	if err == ErrNotWritten {
+		return nil // dropped entries are counted, not reported
+	}
+	return err
+}
+
+// writeOrQueue writes entry to the durable writer or queues it for the buffered writer,
+// holding closeMu so that Close cannot stop the goroutine in between. It reports whether
+// the entry was queued, and otherwise the outcome: ErrNotWritten if the queue was full
+// and the entry dropped.
+func (sw *SplitWriter) writeOrQueue(entry LogEntry, ack func(error)) (queued bool, err error) {
+	sw.closeMu.RLock()
+	defer sw.closeMu.RUnlock()
+	if sw.closed.Load() {
+		return false, ErrSplitWriterClosed
+	}
+	if entry.Pri >= sw.syncPriority {
+		sw.mu.Lock()
+		defer sw.mu.Unlock()
+		return false, writeEntryTo(sw.durable, entry)
+	}
+	select {
+	case sw.queue <- splitItem{entry: entry, ack: ack}:
+		return true, nil
+	default:
+		sw.dropped.Add(1)
+		return false, ErrNotWritten
+	}
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (sw *SplitWriter) Dropped() uint64 {
+	return sw.dropped.Load()
+}
+
+// Pressure returns how full the queue of the buffered writer is, as a ratio between 0
+// and 1; it implements PressureReporter. A SplitWriter without a queue reports 1.
+func (sw *SplitWriter) Pressure() float64 {
+	if cap(sw.queue) == 0 {
+		return 1
+	}
+	return float64(len(sw.queue)) / float64(cap(sw.queue))
+}
+
+// Flush waits until the entries queued so far are written to the buffered writer, then
+// flushes the writers of the chains of both writers that have a Flush() error method.
+func (sw *SplitWriter) Flush() error {
+	flushed := make(chan struct{})
+	select {
+	case sw.queue <- splitItem{flushed: flushed}:
+		select {
+		case <-flushed:
+		case <-sw.stopped:
+		}
+	case <-sw.stopped:
+	}
+	return sw.forEachWriter(func(w io.Writer) error {
+		if f, ok := w.(interface{ Flush() error }); ok {
+			return f.Flush()
+		}
+		return nil
+	})
+}
+
+// Close drains the queue, stops the background goroutine, and flushes and closes both
+// writers. Entries written after Close are rejected with ErrSplitWriterClosed; Close
+// waits for the writes in progress, so that their entries are written too.
+func (sw *SplitWriter) Close() error {
+	sw.closeMu.Lock()
+	closing := sw.closed.CompareAndSwap(false, true)
+	sw.closeMu.Unlock()
+	if !closing {
+		return nil
+	}
+	close(sw.done)
+	<-sw.stopped
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.forEachWriter(flushAndCloseWriter)
+}
+
+// forEachWriter calls fn with the writers of the chains of both writers and returns the
+// errors joined.
+func (sw *SplitWriter) forEachWriter(fn func(io.Writer) error) error {
+	var errs []error
+	for _, w := range append(chainWriters(sw.durable), chainWriters(sw.buffered)...) {
+		if err := fn(w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run writes the queued entries to the buffered writer until the writer is closed, then
+// writes the entries still queued.
+func (sw *SplitWriter) run() {
+	defer close(sw.stopped)
+	for {
+		select {
+		case item := <-sw.queue:
+			sw.handle(item)
+		case <-sw.done:
+			for {
+				select {
+				case item := <-sw.queue:
+					sw.handle(item)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle writes a queued entry to the buffered writer, or signals a flush marker.
+func (sw *SplitWriter) handle(item splitItem) {
+	if item.flushed != nil {
+		close(item.flushed)
+		return
+	}
+	err := writeEntryTo(sw.buffered, item.entry)
+	if err != nil {
+		writeLastResort(err, item.entry)
+	}
+	acked(item.ack, err)
+}
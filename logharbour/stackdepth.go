@@ -0,0 +1,36 @@
+package logharbour
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithMaxStackDepth returns a new Logger that keeps at most n frames, the innermost
+// ones, in the stack traces of its debug entries. The omitted frames are replaced by
+// a line such as "... 12 frames omitted". If n is zero or negative, stack traces are
+// not limited, which is the default.
+func (l *Logger) WithMaxStackDepth(n int) *Logger {
+	newLogger := l.clone()
+	newLogger.maxStackDepth = n
+	if newLogger.tracing {
+		newLogger.traceMutation("WithMaxStackDepth", n)
+	}
+	return newLogger
+}
+
+// truncateStackTrace keeps the goroutine header and the first maxDepth frames of a
+// stack trace formatted by formatStackTrace. Each frame takes two lines: the function
+// and its file and line.
+func truncateStackTrace(stackTrace string, maxDepth int) string {
+	if maxDepth <= 0 {
+		return stackTrace
+	}
+	lines := strings.Split(strings.TrimRight(stackTrace, "\x00\n"), "\n")
+	// A trace cut off by the capture buffer may end with half a frame, which counts as one.
+	frames := len(lines) / 2
+	if frames <= maxDepth {
+		return stackTrace
+	}
+	kept := lines[:1+2*maxDepth]
+	return strings.Join(kept, "\n") + fmt.Sprintf("\n... %d frames omitted", frames-maxDepth)
+}
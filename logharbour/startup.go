@@ -0,0 +1,45 @@
+package logharbour
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// BuildInfo describes the build of a service, as logged by LogStartup.
+type BuildInfo struct {
+	Version   string    `json:"version"`    // Version of the service, e.g. "v1.4.2".
+	Commit    string    `json:"commit"`     // VCS revision the service was built from.
+	GoVersion string    `json:"go_version"` // Go version the service was built with.
+	StartTime time.Time `json:"start_time"` // Time at which the service started.
+}
+
+// LogStartup logs the standard startup entry of a service, an Info activity entry with
+// the message "<app> version <version> started" and buildInfo as its data.
+//
+// Fields of buildInfo left empty are filled in: the Go version from the runtime, the
+// start time with the current time, and the version and commit from the build information
+// embedded in the binary (see runtime/debug.ReadBuildInfo), if available. The embedded
+// version is "(devel)" for binaries not built from a module version, so services usually
+// pass their version explicitly, for instance one set with -ldflags at build time.
+func (l *Logger) LogStartup(buildInfo BuildInfo) {
+	if buildInfo.GoVersion == "" {
+		buildInfo.GoVersion = runtime.Version()
+	}
+	if buildInfo.StartTime.IsZero() {
+		buildInfo.StartTime = time.Now().UTC()
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if buildInfo.Version == "" {
+			buildInfo.Version = info.Main.Version
+		}
+		if buildInfo.Commit == "" {
+			for _, setting := range info.Settings {
+				if setting.Key == "vcs.revision" {
+					buildInfo.Commit = setting.Value
+				}
+			}
+		}
+	}
+	l.Info().LogActivity(l.app+" version "+buildInfo.Version+" started", buildInfo)
+}
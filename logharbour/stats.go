@@ -0,0 +1,99 @@
+package logharbour
+
+import (
+	"sync"
+	"time"
+)
+
+// statsBucket holds the number of entries logged per priority during one time slot.
+type statsBucket struct {
+	slot   int64 // start of the slot, in seconds or minutes since the epoch
+	counts [Sec + 1]int64
+}
+
+// logStats counts logged entries per priority, in total and over a rolling window
+// of the last minute (per-second buckets) and the last hour (per-minute buckets).
+type logStats struct {
+	total   [Sec + 1]int64
+	seconds [60]statsBucket
+	minutes [60]statsBucket
+	mu      sync.Mutex
+}
+
+// newLogStats creates an empty set of counters.
+func newLogStats() *logStats {
+	return &logStats{}
+}
+
+// record counts one entry with priority p logged at time now.
+func (s *logStats) record(p LogPriority, now time.Time) {
+	if p < Debug2 || p > Sec {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total[p]++
+	incrementBucket(s.seconds[:], now.Unix(), p)
+	incrementBucket(s.minutes[:], now.Unix()/60, p)
+}
+
+// incrementBucket increments the count for p in the bucket of the given slot,
+// resetting the bucket first if it still holds an older slot.
+func incrementBucket(buckets []statsBucket, slot int64, p LogPriority) {
+	b := &buckets[slot%int64(len(buckets))]
+	if b.slot != slot {
+		*b = statsBucket{slot: slot}
+	}
+	b.counts[p]++
+}
+
+// sumBuckets adds up the counts of all buckets whose slot is within (now-n, now].
+func sumBuckets(buckets []statsBucket, now, n int64) map[LogPriority]int64 {
+	result := make(map[LogPriority]int64)
+	for i := range buckets {
+		b := &buckets[i]
+		if b.slot <= now-n || b.slot > now {
+			continue
+		}
+		for p := Debug2; p <= Sec; p++ {
+			if b.counts[p] > 0 {
+				result[p] += b.counts[p]
+			}
+		}
+	}
+	return result
+}
+
+// Stats returns the number of entries written per priority since the Logger was created.
+// Counters are shared by the Logger and all loggers cloned from it.
+// Entries suppressed by the minimum log priority are not counted.
+func (l *Logger) Stats() map[LogPriority]int64 {
+	result := make(map[LogPriority]int64)
+	if l.stats == nil {
+		return result
+	}
+	l.stats.mu.Lock()
+	defer l.stats.mu.Unlock()
+	for p := Debug2; p <= Sec; p++ {
+		if l.stats.total[p] > 0 {
+			result[p] = l.stats.total[p]
+		}
+	}
+	return result
+}
+
+// StatsWindow returns the number of entries written per priority within the last window.
+// Windows of up to a minute are counted with one-second resolution; longer windows
+// are counted with one-minute resolution and are capped at one hour.
+func (l *Logger) StatsWindow(window time.Duration) map[LogPriority]int64 {
+	if l.stats == nil {
+		return make(map[LogPriority]int64)
+	}
+	now := time.Now()
+	l.stats.mu.Lock()
+	defer l.stats.mu.Unlock()
+	if window <= time.Minute {
+		return sumBuckets(l.stats.seconds[:], now.Unix(), int64(window/time.Second))
+	}
+	return sumBuckets(l.stats.minutes[:], now.Unix()/60, int64(window/time.Minute))
+}
@@ -0,0 +1,147 @@
+package logharbour
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// summaryTopOps is the number of operations listed in a LogSummary.
+const summaryTopOps = 10
+
+// OpCount is the number of entries of an operation in a LogSummary.
+type OpCount struct {
+	Op    string `json:"op"`
+	Count int    `json:"count"`
+}
+
+// LogSummary is the Data of an entry logged by a Summarizer. It counts the entries
+// logged over an interval.
+type LogSummary struct {
+	Entries    int            `json:"entries"`     // Number of entries counted.
+	ByPriority map[string]int `json:"by_priority"` // Number of entries per priority, e.g. "Info".
+	ByStatus   map[string]int `json:"by_status"`   // Number of entries per status, e.g. "Failure".
+	TopOps     []OpCount      `json:"top_ops"`     // The 10 most frequent operations, most frequent first.
+	From       time.Time      `json:"from"`        // Start of the interval.
+	To         time.Time      `json:"to"`          // End of the interval.
+}
+
+// Summarizer counts the entries of high-volume, low-signal Loggers and logs one summary
+// entry per interval, with the number of entries per priority, per status and for the
+// most frequent operations, which gives operators a heartbeat of the activity without
+// the individual lines. Loggers are attached to it with WithSummarizer, which also says
+// whether their entries are still written individually.
+//
+//	summarizer := logharbour.NewSummarizer(logger, time.Minute)
+//	defer summarizer.Close()
+//	pollLogger := logger.WithSummarizer(summarizer, false)
+//
+// Entries are counted after the priority filter and before sampling, so that a Logger
+// with a sampler (see WithTraceSampler) that also writes entries individually writes the
+// entries of the sampled traces, while the summary counts them all. A summary is logged
+// every interval, even with no entries, as an Info activity entry of the Logger given to
+// NewSummarizer, which should not itself be attached to the Summarizer.
+type Summarizer struct {
+	logger  *Logger
+	summary LogSummary
+	ops     map[string]int
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+	mu      sync.Mutex
+}
+
+// NewSummarizer creates a Summarizer that logs its summaries with logger. If interval
+// is positive, a summary is logged every interval until Close is called.
+func NewSummarizer(logger *Logger, interval time.Duration) *Summarizer {
+	s := &Summarizer{
+		logger: logger.Info(),
+		done:   make(chan struct{}),
+	}
+	s.reset(time.Now())
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.run(interval)
+	}
+	return s
+}
+
+// WithSummarizer returns a new Logger whose entries are counted by s. If individual is
+// false, the entries are only counted and not written, so only the summaries are.
+// Pass a nil s to stop summarizing.
+func (l *Logger) WithSummarizer(s *Summarizer, individual bool) *Logger {
+	newLogger := l.clone()
+	newLogger.summarizer = s
+	newLogger.summarizeOnly = s != nil && !individual
+	if newLogger.tracing {
+		newLogger.traceMutation("WithSummarizer", s)
+	}
+	return newLogger
+}
+
+// add counts entry.
+func (s *Summarizer) add(entry LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summary.Entries++
+	s.summary.ByPriority[entry.Pri.String()]++
+	s.summary.ByStatus[entry.Status.String()]++
+	if entry.Op != "" {
+		s.ops[entry.Op]++
+	}
+}
+
+// reset starts a new summary at now.
+func (s *Summarizer) reset(now time.Time) {
+	s.summary = LogSummary{ByPriority: make(map[string]int), ByStatus: make(map[string]int), From: now}
+	s.ops = make(map[string]int)
+}
+
+// Flush logs the summary of the entries counted since the last flush and starts a new one.
+func (s *Summarizer) Flush() {
+	now := time.Now()
+	s.mu.Lock()
+	summary, ops := s.summary, s.ops
+	s.reset(now)
+	s.mu.Unlock()
+
+	summary.To = now
+	summary.TopOps = make([]OpCount, 0, len(ops))
+	for op, count := range ops {
+		summary.TopOps = append(summary.TopOps, OpCount{Op: op, Count: count})
+	}
+	sort.Slice(summary.TopOps, func(i, j int) bool {
+		a, b := summary.TopOps[i], summary.TopOps[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Op < b.Op
+	})
+	if len(summary.TopOps) > summaryTopOps {
+		summary.TopOps = summary.TopOps[:summaryTopOps]
+	}
+	s.logger.LogActivity(fmt.Sprintf("summary of %d entries", summary.Entries), summary)
+}
+
+// Close stops the periodic summaries, if any, and logs the last one.
+// It may be called more than once.
+func (s *Summarizer) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+	})
+	s.Flush()
+}
+
+// run logs a summary every interval until the Summarizer is closed.
+func (s *Summarizer) run(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.done:
+			return
+		}
+	}
+}
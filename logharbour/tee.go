@@ -0,0 +1,55 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// PriorityWriter passes on to another writer only the entries at or above a minimum
+// priority. It is meant for the branches of a MultiWriter that should receive fewer
+// entries than the Logger logs, such as a console that only shows warnings.
+type PriorityWriter struct {
+	next        io.Writer
+	minPriority LogPriority
+}
+
+// NewPriorityWriter creates a PriorityWriter that writes the entries at or above minPriority to next.
+func NewPriorityWriter(next io.Writer, minPriority LogPriority) *PriorityWriter {
+	return &PriorityWriter{next: next, minPriority: minPriority}
+}
+
+// Write decodes a JSON log entry and passes it on if its priority is high enough.
+// It implements io.Writer.
+func (pw *PriorityWriter) Write(p []byte) (n int, err error) {
+	var entry LogEntry
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return 0, err
+	}
+	if err := pw.writeEntry(entry); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry writes entry to the next writer if its priority is high enough.
+func (pw *PriorityWriter) writeEntry(entry LogEntry) error {
+	if entry.Pri < pw.minPriority {
+		return nil
+	}
+	return writeEntryTo(pw.next, entry)
+}
+
+// NewTeeLogger creates a Logger that writes every entry to fileWriter and only the
+// entries at or above consoleMinPriority to consoleWriter, a common setup in development
+// where a file keeps everything and the console shows warnings and errors only:
+//
+//	logger := logharbour.NewTeeLogger(lctx, "myapp", file,
+//		logharbour.NewEncodedWriter(os.Stdout, logharbour.ConsoleEncoder{Color: true}), logharbour.Warn)
+//
+// The file branch logs at the Logger's own level, set by the LoggerContext and the
+// Logger's minimum priority; the console minimum only filters further, so a console
+// minimum below the Logger's level has no effect. The writer is a MultiWriter of
+// fileWriter and a PriorityWriter wrapping consoleWriter.
+func NewTeeLogger(context *LoggerContext, appName string, fileWriter io.Writer, consoleWriter io.Writer, consoleMinPriority LogPriority) *Logger {
+	return NewLogger(context, appName, NewMultiWriter(fileWriter, NewPriorityWriter(consoleWriter, consoleMinPriority)))
+}
@@ -0,0 +1,138 @@
+package logharbour
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRedactedHeaders are the request headers whose values a LoggingTransport
+// replaces with RedactedArg, when it logs headers, unless configured otherwise.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-Auth-Token"}
+
+// LoggingTransport is an http.RoundTripper that logs the outbound requests of an
+// http.Client, mirroring Middleware for inbound requests. Create one with Transport.
+//
+// Each request is logged as an activity entry with op set to the method and host, e.g.
+// "POST payments.internal", and its method, URL, response status code and duration as
+// data. Requests that fail or get a 5xx response are logged with status Failure at
+// priority Err; all others with status Success.
+//
+// Credentials often travel in headers and query parameters, so neither is logged as it
+// is by default. The URL is logged without its password, if any, and with the values of
+// its query parameters replaced with RedactedArg, e.g. "?access_token=[redacted]"; set
+// LogQuery to log them. Request headers are only logged if LogHeaders is set, and then
+// the values of the headers listed in RedactHeaders (DefaultRedactedHeaders by default)
+// are logged as RedactedArg. Services that use other headers for credentials should add
+// them to the list before logging headers:
+//
+//	client := &http.Client{Transport: logharbour.Transport(logger, http.DefaultTransport)}
+//
+//	t := logharbour.Transport(logger, nil)
+//	t.LogHeaders = true
+//	t.RedactHeaders = append(t.RedactHeaders, "X-Vault-Token")
+type LoggingTransport struct {
+	base          *Logger
+	next          http.RoundTripper
+	RedactHeaders []string // Headers whose values are replaced with RedactedArg.
+	LogHeaders    bool     // If true, request headers are logged.
+	LogQuery      bool     // If true, the values of query parameters are logged.
+}
+
+// Transport returns a LoggingTransport that sends requests with next, or with
+// http.DefaultTransport if next is nil, and logs them with base.
+//
+// If the request context carries a Logger (see NewContext), that Logger is used instead,
+// so that outbound calls made while handling a request are logged with its fields. Its
+// trace ID is propagated in the X-Request-ID header, unless the request already has one.
+func Transport(base *Logger, next http.RoundTripper) *LoggingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &LoggingTransport{
+		base:          base,
+		next:          next,
+		RedactHeaders: append([]string(nil), DefaultRedactedHeaders...),
+	}
+}
+
+// RoundTrip sends the request, logs it and returns the response. It implements http.RoundTripper.
+func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	logger, ok := FromContext(req.Context())
+	if !ok {
+		logger = t.base
+	}
+	if logger.traceID != "" && req.Header.Get(RequestIDHeader) == "" {
+		// RoundTrippers must not modify the request they are given.
+		req = req.Clone(req.Context())
+		req.Header.Set(RequestIDHeader, logger.traceID)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	data := map[string]any{
+		"method":      req.Method,
+		"url":         t.loggedURL(req.URL),
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	if t.LogHeaders {
+		data["headers"] = t.redactedHeaders(req.Header)
+	}
+	logger = logger.WithOp(req.Method + " " + req.URL.Host)
+	switch {
+	case err != nil:
+		logger.WithStatus(Failure).Error(err).Err().LogActivity("outbound request failed", data)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		data["status_code"] = resp.StatusCode
+		logger.WithStatus(Failure).Err().LogActivity("outbound request completed", data)
+	default:
+		data["status_code"] = resp.StatusCode
+		logger.WithStatus(Success).LogActivity("outbound request completed", data)
+	}
+	return resp, err
+}
+
+// loggedURL returns u without its password and, unless LogQuery is set, with the values
+// of its query parameters replaced with RedactedArg.
+func (t *LoggingTransport) loggedURL(u *url.URL) string {
+	if t.LogQuery || u.RawQuery == "" {
+		return u.Redacted()
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var params []string
+	for _, name := range names {
+		for range query[name] {
+			params = append(params, url.QueryEscape(name)+"="+RedactedArg)
+		}
+	}
+	redacted := *u
+	redacted.RawQuery = strings.Join(params, "&")
+	return redacted.Redacted()
+}
+
+// redactedHeaders returns a copy of header with the values of the redacted headers replaced.
+func (t *LoggingTransport) redactedHeaders(header http.Header) map[string][]string {
+	headers := make(map[string][]string, len(header))
+	for name, values := range header {
+		headers[name] = values
+	}
+	for _, name := range t.RedactHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if values, ok := headers[name]; ok {
+			redacted := make([]string, len(values))
+			for i := range redacted {
+				redacted[i] = RedactedArg
+			}
+			headers[name] = redacted
+		}
+	}
+	return headers
+}
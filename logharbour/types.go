@@ -5,8 +5,10 @@ package logharbour
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 )
@@ -67,31 +69,59 @@ func (lp LogPriority) MarshalJSON() ([]byte, error) {
 	return json.Marshal(lp.String())
 }
 
+// UnmarshalJSON is required by the encoding/json package.
+// It accepts either the string representation of a priority (case-insensitive)
+// or its numeric value.
 func (lp *LogPriority) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		value := LogPriority(n)
+		if value < Debug2 || value > Sec {
+			return fmt.Errorf("invalid LogPriority %d", n)
+		}
+		*lp = value
+		return nil
+	}
+
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
 	}
 
-	value, ok := map[string]LogPriority{
-		"Debug2": Debug2,
-		"Debug1": Debug1,
-		"Debug0": Debug0,
-		"Info":   Info,
-		"Warn":   Warn,
-		"Err":    Err,
-		"Crit":   Crit,
-		// Add other LogPriority values here
-	}[s]
-
-	if !ok {
-		return fmt.Errorf("invalid LogPriority %q", s)
+	value, err := ParseLogPriority(s)
+	if err != nil {
+		return err
 	}
 
 	*lp = value
 	return nil
 }
 
+// ParseLogPriority converts a priority name such as "warn" or "Debug1" to a LogPriority.
+// The comparison is case-insensitive. An error is returned for unknown names.
+func ParseLogPriority(s string) (LogPriority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug2":
+		return Debug2, nil
+	case "debug1":
+		return Debug1, nil
+	case "debug0":
+		return Debug0, nil
+	case "info":
+		return Info, nil
+	case "warn":
+		return Warn, nil
+	case "err":
+		return Err, nil
+	case "crit":
+		return Crit, nil
+	case "sec":
+		return Sec, nil
+	default:
+		return 0, fmt.Errorf("invalid LogPriority %q", s)
+	}
+}
+
 // LogType defines the category of a log message.
 type LogType int
 
@@ -104,6 +134,8 @@ const (
 	Debug
 	// Unknown represents an unknown log type.
 	Unknown
+	// Metric represents a metric event logged for a metrics-from-logs pipeline.
+	Metric
 )
 
 const (
@@ -111,6 +143,7 @@ const (
 	LogTypeActivity = "A"
 	LogTypeDebug    = "D"
 	LogTypeUnknown  = "U"
+	LogTypeMetric   = "M"
 )
 
 // String returns the string representation of the LogType.
@@ -122,9 +155,22 @@ func (lt LogType) String() string {
 		return LogTypeActivity
 	case Debug:
 		return LogTypeDebug
-	default:
-		return LogTypeUnknown
+	case Metric:
+		return LogTypeMetric
+	}
+	if name, ok := customLogTypeName(lt); ok {
+		return name
+	}
+	return LogTypeUnknown
+}
+
+// IsValid reports whether lt is one of the defined log types or a registered custom type.
+func (lt LogType) IsValid() bool {
+	if lt >= Change && lt <= Metric {
+		return true
 	}
+	_, ok := customLogTypeName(lt)
+	return ok
 }
 
 // MarshalJSON is required by the encoding/json package.
@@ -144,8 +190,12 @@ func (lt *LogType) UnmarshalJSON(data []byte) error {
 		"A": Activity,
 		"C": Change,
 		"D": Debug,
+		"M": Metric,
 		// Add other LogType values here
 	}[s]
+	if !ok {
+		value, ok = lookupLogType(s)
+	}
 
 	if !ok {
 		return fmt.Errorf("invalid LogType %q", s)
@@ -155,6 +205,36 @@ func (lt *LogType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ParseLogType converts a log type name to a LogType. It accepts both the short
+// form used in serialized entries ("C", "A", "D", "U", "M") and the long form
+// ("Change", "Activity", "Debug", "Unknown", "Metric"), case-insensitively, as well as
+// the names of the types registered with RegisterLogType.
+func ParseLogType(s string) (LogType, error) {
+	if lt, ok := lookupLogType(s); ok {
+		return lt, nil
+	}
+	return parseBuiltinLogType(s)
+}
+
+// parseBuiltinLogType converts the name of a built-in log type to a LogType.
+func parseBuiltinLogType(s string) (LogType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "c", "change":
+		return Change, nil
+	case "a", "activity":
+		return Activity, nil
+	case "d", "debug":
+		return Debug, nil
+	case "u", "unknown":
+		return Unknown, nil
+	case "m", "metric":
+		return Metric, nil
+	default:
+		return 0, fmt.Errorf("invalid LogType %q", s)
+	}
+}
+
+// Status defines the outcome of the operation recorded in a log entry.
 type Status int
 
 const (
@@ -162,23 +242,131 @@ const (
 	Failure
 )
 
+const (
+	StatusSuccess = "Success"
+	StatusFailure = "Failure"
+	StatusUnknown = "Unknown"
+)
+
+// String returns the string representation of the Status.
+func (s Status) String() string {
+	switch s {
+	case Success:
+		return StatusSuccess
+	case Failure:
+		return StatusFailure
+	default:
+		return StatusUnknown
+	}
+}
+
+// ParseStatus converts a status name such as "success" or "Failure" to a Status.
+// The comparison is case-insensitive. An error is returned for unknown names.
+func ParseStatus(s string) (Status, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "success":
+		return Success, nil
+	case "failure":
+		return Failure, nil
+	default:
+		return 0, fmt.Errorf("invalid Status %q", s)
+	}
+}
+
+// MarshalJSON is required by the encoding/json package.
+// It writes the Status as an integer; see JSONEncoder.StatusAsString to write its name.
+func (s Status) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(s))
+}
+
+// UnmarshalJSON is required by the encoding/json package.
+// It accepts either the numeric value of a Status or its name.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*s = Status(n)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	value, err := ParseStatus(name)
+	if err != nil {
+		return err
+	}
+
+	*s = value
+	return nil
+}
+
 // LogEntry encapsulates all the relevant information for a log message.
+//
+// ActorType and SubjectType are optional and are omitted from the JSON output when empty.
+// They let audit entries distinguish the kind of actor (Who) from the kind of subject
+// (Class/InstanceId), e.g. "admin changed user X" versus "user X changed self".
+// When ActorType is set, Who is required; when SubjectType is set, InstanceId is required.
+// Type must be one of the defined LogType values or a type registered with
+// RegisterLogType; entries with any other type fail
+// validation and are written to the fallback writer, like other invalid entries.
+// Fields tagged omitempty are left out of the output when empty; see JSONEncoder.Compact
+// for the fields that are only left out in compact mode. When adding a field, add it to
+// compactLogEntry too.
 type LogEntry struct {
-	App        string      `json:"app"`             // Name of the application.
-	System     string      `json:"system"`          // System where the application is running.
-	Module     string      `json:"module"`          // The module or subsystem within the application
-	Type       LogType     `json:"type"`            // Type of the log entry.
-	Pri        LogPriority `json:"pri"`             // Severity level of the log entry.
-	When       time.Time   `json:"when"`            // Time at which the log entry was created.
-	Who        string      `json:"who"`             // User or service performing the operation.
-	Op         string      `json:"op"`              // Operation being performed
-	Class      string      `json:"class"`           // Unique ID, name of the object instance on which the operation was being attempted
-	InstanceId string      `json:"instance"`        // Unique ID, name, or other "primary key" information of the object instance on which the operation was being attempted
-	Status     Status      `json:"status"`          // 0 or 1, indicating success (1) or failure (0), or some other binary representation
-	Error      string      `json:"error,omitempty"` // Error message or error chain related to the log entry, if any.
-	RemoteIP   string      `json:"remote_ip"`       // IP address of the caller from where the operation is being performed.
-	Msg        string      `json:"msg"`             // A descriptive message for the log entry.
-	Data       any         `json:"data"`            // The payload of the log entry, can be any type.
+	App                 string                 `json:"app"`                                           // Name of the application.
+	System              string                 `json:"system"`                                        // System where the application is running.
+	Env                 string                 `json:"env,omitempty"`                                 // Deployment environment, such as dev, staging or prod, if set.
+	Tenant              string                 `json:"tenant,omitempty"`                              // Tenant the entry belongs to in a multi-tenant system, if set.
+	Module              string                 `json:"module"`                                        // The module or subsystem within the application
+	Type                LogType                `json:"type" validate:"logtype"`                       // Type of the log entry.
+	Pri                 LogPriority            `json:"pri"`                                           // Severity level of the log entry.
+	When                time.Time              `json:"when"`                                          // Time at which the log entry was created.
+	Seq                 uint64                 `json:"seq,omitempty"`                                 // Sequence number of the entry in its LoggerContext, if sequencing is enabled.
+	SeqSource           string                 `json:"seq_source,omitempty"`                          // Random ID of the LoggerContext and process run that numbered Seq, if sequencing is enabled.
+	Who                 string                 `json:"who" validate:"required_with=ActorType"`        // User or service performing the operation.
+	ActorType           string                 `json:"actor_type,omitempty"`                          // Kind of actor in Who, e.g. "admin", "user" or "service".
+	Op                  string                 `json:"op"`                                            // Operation being performed
+	Class               string                 `json:"class"`                                         // Unique ID, name of the object instance on which the operation was being attempted
+	InstanceId          string                 `json:"instance" validate:"required_with=SubjectType"` // Unique ID, name, or other "primary key" information of the object instance on which the operation was being attempted
+	SubjectType         string                 `json:"subject_type,omitempty"`                        // Kind of subject in Class/InstanceId, e.g. "user".
+	Status              Status                 `json:"status"`                                        // 0 or 1, indicating success (1) or failure (0), or some other binary representation
+	Error               string                 `json:"error,omitempty"`                               // Error message or error chain related to the log entry, if any.
+	RemoteIP            string                 `json:"remote_ip"`                                     // IP address of the caller from where the operation is being performed.
+	TraceID             string                 `json:"trace_id,omitempty"`                            // ID of the distributed trace the entry belongs to, if any.
+	SpanID              string                 `json:"span_id,omitempty"`                             // ID of the span the entry was logged in, if any.
+	ParentSpanID        string                 `json:"parent_span_id,omitempty"`                      // ID of the parent of that span, if any.
+	Baggage             map[string]string      `json:"baggage,omitempty"`                             // Business context propagated across services, if set with WithBaggage.
+	DeadlineRemainingMs *int64                 `json:"deadline_remaining_ms,omitempty"`               // Time left before the request deadline when the entry was created, if bound; negative once past.
+	UptimeMs            *int64                 `json:"uptime_ms,omitempty"`                           // Time since the process started when the entry was created, if enabled.
+	LibVersion          string                 `json:"lh_version,omitempty"`                          // Version of logharbour that produced the entry, if enabled.
+	ID                  string                 `json:"id,omitempty"`                                  // Unique ID of the entry, if it was logged with a method returning it.
+	CausedBy            string                 `json:"caused_by,omitempty"`                           // ID of the entry that caused this one, if any.
+	DedupKey            string                 `json:"dedup_key,omitempty"`                           // Caller-supplied key identifying the entry for idempotent delivery, if any.
+	Alert               bool                   `json:"alert,omitempty"`                               // True if the entry should page someone regardless of its priority.
+	Msg                 string                 `json:"msg"`                                           // A descriptive message for the log entry.
+	Data                any                    `json:"data"`                                          // The payload of the log entry, can be any type.
+	EscalatedFrom       LogPriority            `json:"escalated_from,omitempty"`                      // Original priority if the entry was escalated by an escalation policy.
+	Exemplar            *MetricExemplar        `json:"exemplar,omitempty"`                            // Optional link to a metric exemplar for the same trace.
+	Attachments         []Attachment           `json:"attachments,omitempty"`                         // References to out-of-band artifacts related to the entry.
+	Truncated           bool                   `json:"truncated,omitempty"`                           // True if Data or Msg was shortened to respect JSONEncoder.MaxLineBytes.
+	ValidationErrors    []FieldValidationError `json:"validation_errors,omitempty"`                   // Why the entry failed validation, on entries written to the fallback or last resort writer.
+}
+
+// IsSelfAction reports whether the actor and the subject of the entry are the same,
+// that is, both ActorType and SubjectType are set and equal, and Who equals InstanceId.
+func (e LogEntry) IsSelfAction() bool {
+	return e.ActorType != "" && e.ActorType == e.SubjectType && e.Who != "" && e.Who == e.InstanceId
+}
+
+// MetricExemplar links a log entry to a metric sample, following the OpenMetrics
+// exemplar model. A metrics dashboard showing an exemplar for Metric with TraceID
+// can use these fields to find the log entries recorded for the same trace.
+type MetricExemplar struct {
+	Metric  string            `json:"metric"`           // Name of the metric the exemplar belongs to.
+	TraceID string            `json:"trace_id"`         // Trace ID recorded in the exemplar.
+	Labels  map[string]string `json:"labels,omitempty"` // Optional labels of the metric sample.
 }
 
 type ChangeDetail struct {
@@ -198,10 +386,25 @@ type ChangeDetail struct {
 //			 AddChange("username", "oldUsername", "newUsername")
 //		 logger.LogDataChange("User details updated", *changeInfo)
 //	}
+//
+// Before and After optionally carry complete snapshots of the object before and after
+// the change. They are omitted from the output unless set, because including them
+// roughly doubles (or more) the size of each change entry compared to the field-level
+// Changes list alone. Set them with WithSnapshots or NewChangeInfoFromDiff only when
+// reviewers need the full objects.
+//
+// AffectedCount records how many objects a bulk change applied to, such as the rows
+// updated by one statement, as a number that dashboards can sum. It is omitted when
+// zero, so single-object changes, identified by the entry's InstanceId, are unchanged.
+// For a bulk change, InstanceId identifies the objects as a group, if at all, for
+// instance with a batch or job ID. Set it with WithAffectedCount.
 type ChangeInfo struct {
-	Entity  string         `json:"entity"`
-	Op      string         `json:"op"`
-	Changes []ChangeDetail `json:"changes"`
+	Entity        string         `json:"entity"`
+	Op            string         `json:"op"`
+	Changes       []ChangeDetail `json:"changes"`
+	AffectedCount int            `json:"affected_count,omitempty"`
+	Before        any            `json:"before,omitempty"`
+	After         any            `json:"after,omitempty"`
 }
 
 // ActivityInfo holds information about system activities like web service calls or function executions.
@@ -220,29 +423,131 @@ type DebugInfo struct {
 
 // FallbackWriter provides an io.Writer that automatically falls back to a secondary writer if the primary writer fails.
 // It is also used if logentry is not valid so that we can still log erroneous entries without writing them to the primary writer.
+//
+// A FallbackWriter created with NewFallbackChain has any number of levels: each entry is
+// written to the first writer, and if that fails, to the second, and so on, until a
+// writer succeeds. If all of them fail, the error returned lists the error of each level,
+// and the Logger writes the entry to the last resort writer (see SetLastResortWriter).
+// Invalid entries skip the first writer and go through the rest of the chain in the same way.
+// By default any error moves the entry to the next level; see SetFallbackPredicate.
 type FallbackWriter struct {
-	primary  io.Writer // The main writer to which log entries will be written.
-	fallback io.Writer // The fallback writer used if the primary writer fails.
-	mu       sync.Mutex
+	writers        []io.Writer      // The writers to try, in order; the first one is the primary writer.
+	shouldFallback func(error) bool // Whether an error moves the entry to the next writer; nil means always.
+	mu             sync.Mutex
 }
 
 // NewFallbackWriter creates a new FallbackWriter with a specified primary and fallback writer.
 func NewFallbackWriter(primary, fallback io.Writer) *FallbackWriter {
+	return NewFallbackChain(primary, fallback)
+}
+
+// NewFallbackChain creates a FallbackWriter that tries writers in the given order,
+// for example primary -> secondary -> tertiary.
+func NewFallbackChain(writers ...io.Writer) *FallbackWriter {
 	return &FallbackWriter{
-		primary:  primary,
-		fallback: fallback,
+		writers: writers,
 	}
 }
 
-// Write attempts to write the byte slice to the primary writer, falling back to the secondary writer on error.
-// It returns the number of bytes written and any error encountered that caused the write to stop early.
+// Write attempts to write the byte slice to each writer in turn until one succeeds.
+// It returns the number of bytes written, or the errors of all writers if none succeeded.
 func (fw *FallbackWriter) Write(p []byte) (n int, err error) {
 	fw.mu.Lock()
 	defer fw.mu.Unlock()
-	n, err = fw.primary.Write(p)
-	if err != nil {
-		// Primary writer failed; attempt to write to the fallback writer.
-		n, err = fw.fallback.Write(p)
+	err = fw.try(fw.writers, func(w io.Writer) error {
+		n, err = w.Write(p)
+		return err
+	})
+	return n, err
+}
+
+// writeEntry writes a structured entry to each writer in turn until one succeeds.
+func (fw *FallbackWriter) writeEntry(entry LogEntry) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.try(fw.writers, func(w io.Writer) error {
+		return writeEntryTo(w, entry)
+	})
+}
+
+// writeInvalid writes an entry that failed validation to the writers after the primary
+// writer, in turn, until one succeeds.
+func (fw *FallbackWriter) writeInvalid(entry LogEntry) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if len(fw.writers) < 2 {
+		return errors.New("no fallback writer")
+	}
+	return fw.try(fw.writers[1:], func(w io.Writer) error {
+		return writeEntryTo(w, entry)
+	})
+}
+
+// try calls write for each of writers until it succeeds, and returns the errors of all
+// levels, joined, if it never does.
+func (fw *FallbackWriter) try(writers []io.Writer, write func(io.Writer) error) error {
+	var errs []error
+	for i, w := range writers {
+		err := write(w)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("writer %d: %w", i+1, err))
+		if !fw.fallsBackOn(err) {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fallsBackOn reports whether err moves an entry to the next writer. If the predicate
+// panics, the panic is reported to the last resort writer and the entry falls back,
+// which keeps it from being lost.
+func (fw *FallbackWriter) fallsBackOn(err error) (fallBack bool) {
+	if fw.shouldFallback == nil {
+		return true
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			writeLastResort(fmt.Errorf("panic in fallback predicate: %v", r), LogEntry{})
+			fallBack = true
+		}
+	}()
+	return fw.shouldFallback(err)
+}
+
+// SetFallbackPredicate makes the FallbackWriter move an entry to the next writer only if
+// shouldFallback returns true for the error of the writer that failed. Otherwise, the
+// error is returned as is and the Logger writes it, with the entry, to the last resort
+// writer (see SetLastResortWriter), so that errors revealing a bug are not hidden in the
+// fallback logs. Passing nil restores the default, which is to fall back on every error.
+// If shouldFallback panics, the entry moves to the next writer.
+//
+// We recommend falling back on errors that a retry elsewhere may avoid, such as network
+// errors (net.Error, syscall.ECONNREFUSED), timeouts (os.ErrDeadlineExceeded), a full
+// disk (syscall.ENOSPC) and ErrCloudWatchThrottled, and not on errors that the next
+// writer would get too, such as JSON encoding errors (*json.UnsupportedTypeError,
+// *json.UnsupportedValueError, *json.MarshalerError):
+//
+//	fw.SetFallbackPredicate(func(err error) bool {
+//		var netErr net.Error
+//		return errors.As(err, &netErr) || errors.Is(err, syscall.ECONNREFUSED) ||
+//			errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, syscall.ENOSPC)
+//	})
+func (fw *FallbackWriter) SetFallbackPredicate(shouldFallback func(err error) bool) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.shouldFallback = shouldFallback
+}
+
+// Pressure reports the pressure of the primary writer if it implements PressureReporter.
+// Otherwise it returns 0.
+func (fw *FallbackWriter) Pressure() float64 {
+	if len(fw.writers) == 0 {
+		return 0
+	}
+	if pr, ok := fw.writers[0].(PressureReporter); ok {
+		return pr.Pressure()
 	}
-	return n, err // Return the result of the write operation.
+	return 0
 }
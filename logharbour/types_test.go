@@ -0,0 +1,126 @@
+package logharbour
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseLogPriority(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    LogPriority
+		wantErr bool
+	}{
+		{"Debug2", Debug2, false},
+		{"debug1", Debug1, false},
+		{"DEBUG0", Debug0, false},
+		{"info", Info, false},
+		{"warn", Warn, false},
+		{"Err", Err, false},
+		{"crit", Crit, false},
+		{"sec", Sec, false},
+		{" Warn ", Warn, false},
+		{"verbose", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLogPriority(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLogPriority(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLogPriority(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLogPriorityJSONRoundTrip(t *testing.T) {
+	for p := Debug2; p <= Sec; p++ {
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(data) != `"`+p.String()+`"` {
+			t.Errorf("Expected %s to marshal as its name. Got: %s", p, data)
+		}
+
+		var got LogPriority
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != p {
+			t.Errorf("Expected %v after round trip. Got: %v", p, got)
+		}
+	}
+
+	var got LogPriority
+	if err := json.Unmarshal([]byte("5"), &got); err != nil || got != Warn {
+		t.Errorf("Expected numeric 5 to unmarshal as Warn. Got: %v, %v", got, err)
+	}
+	if err := json.Unmarshal([]byte("42"), &got); err == nil {
+		t.Errorf("Expected error for out of range priority")
+	}
+}
+
+func TestParseLogType(t *testing.T) {
+	tests := map[string]LogType{
+		"C":        Change,
+		"change":   Change,
+		"a":        Activity,
+		"Activity": Activity,
+		"D":        Debug,
+		"debug":    Debug,
+		"U":        Unknown,
+		"Metric":   Metric,
+	}
+	for input, want := range tests {
+		got, err := ParseLogType(input)
+		if err != nil {
+			t.Errorf("ParseLogType(%q) unexpected error: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseLogType(%q) = %v, want %v", input, got, want)
+		}
+	}
+	if _, err := ParseLogType("X"); err == nil {
+		t.Errorf("Expected error for invalid log type")
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	data, err := json.Marshal(Failure)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "1" {
+		t.Errorf("Expected Failure to marshal as 1 by default. Got: %s", data)
+	}
+
+	data, err = JSONEncoder{StatusAsString: true}.Encode(LogEntry{Pri: Info, Status: Failure})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), `"status":"Failure"`) || strings.Count(string(data), `"status"`) != 1 {
+		t.Errorf("Expected Failure to be encoded as \"Failure\". Got: %s", data)
+	}
+	var entry LogEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Status != Failure {
+		t.Errorf("Expected the entry to be read back with Failure. Got: %v, %v", entry.Status, err)
+	}
+
+	var got Status
+	data = []byte(`"Failure"`)
+	if err := json.Unmarshal(data, &got); err != nil || got != Failure {
+		t.Errorf("Expected \"Failure\" to unmarshal as Failure. Got: %v, %v", got, err)
+	}
+	if err := json.Unmarshal([]byte("0"), &got); err != nil || got != Success {
+		t.Errorf("Expected 0 to unmarshal as Success. Got: %v, %v", got, err)
+	}
+	if _, err := ParseStatus("maybe"); err == nil {
+		t.Errorf("Expected error for invalid status")
+	}
+}
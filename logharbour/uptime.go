@@ -0,0 +1,42 @@
+package logharbour
+
+import "time"
+
+// processStart is the time the package was initialized, taken as the process start time.
+var processStart = time.Now()
+
+// ProcessStart returns the time the process started, as captured when the package was
+// initialized.
+func ProcessStart() time.Time {
+	return processStart
+}
+
+// Uptime returns the time elapsed since ProcessStart.
+func Uptime() time.Duration {
+	return time.Since(processStart)
+}
+
+// WithUptime returns a new Logger whose entries record, in uptime_ms, the process uptime
+// when they were created. This helps tell errors logged right after a restart, such as in
+// a restart loop, from those of a process that has been running for a while.
+func (l *Logger) WithUptime() *Logger {
+	newLogger := l.clone()
+	newLogger.uptime = true
+	if newLogger.tracing {
+		newLogger.traceMutation("WithUptime", true)
+	}
+	return newLogger
+}
+
+// LogUptime logs an activity entry recording the process start time and uptime, for
+// instance from a periodic health check, whether or not the Logger was set up with
+// WithUptime.
+func (l *Logger) LogUptime() {
+	entry := l.newLogEntry("process uptime", map[string]any{
+		"started": processStart.UTC().Format(time.RFC3339Nano),
+	})
+	entry.Type = Activity
+	uptime := Uptime().Milliseconds()
+	entry.UptimeMs = &uptime
+	l.log(entry)
+}
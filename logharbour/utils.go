@@ -1,14 +1,46 @@
 package logharbour
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 )
 
-// GetSystemName returns the host name of the system.
+var (
+	systemNameFunc func() string // nil means the host name
+	systemNameMu   sync.Mutex
+)
+
+// SetSystemNameFunc sets the function that gives the system name of new Loggers, which
+// is the host name by default. In containers the host name is often a random ID, so
+// deployments can supply a pod name, node name or a combination instead, for example:
+//
+//	logharbour.SetSystemNameFunc(func() string {
+//		return os.Getenv("NODE_NAME") + "/" + os.Getenv("POD_NAME")
+//	})
+//
+// The function is called when a Logger is created, so it must be set before creating
+// Loggers; existing Loggers and their clones keep their system name. Passing nil
+// restores the default.
+func SetSystemNameFunc(fn func() string) {
+	systemNameMu.Lock()
+	defer systemNameMu.Unlock()
+	systemNameFunc = fn
+}
+
+// GetSystemName returns the name of the system: the result of the function set with
+// SetSystemNameFunc, or the host name.
 func getSystemName() string {
+	systemNameMu.Lock()
+	fn := systemNameFunc
+	systemNameMu.Unlock()
+	if fn != nil {
+		return fn()
+	}
 	host, err := os.Hostname()
 	if err != nil {
 		return "unknown"
@@ -58,3 +90,17 @@ func formatStackTrace(stackTraceRaw string) string {
 	}
 	return strings.Join(stackTraceLines, "\n")
 }
+
+// entryDedupKey returns the dedup key of a serialized log entry, or "" if it has none.
+func entryDedupKey(p []byte) string {
+	if !bytes.Contains(p, []byte(`"dedup_key"`)) {
+		return ""
+	}
+	var entry struct {
+		DedupKey string `json:"dedup_key"`
+	}
+	if err := json.Unmarshal(p, &entry); err != nil {
+		return ""
+	}
+	return entry.DedupKey
+}
@@ -1,6 +1,8 @@
 package logharbour
 
 import (
+	"io"
+	"os"
 	"strings"
 	"testing"
 )
@@ -37,3 +39,46 @@ func TestGetDebugInfoNestedInline(t *testing.T) {
 
 	nestedFunction()
 }
+
+func TestEntryDedupKey(t *testing.T) {
+	if key := entryDedupKey([]byte(`{"msg":"x","dedup_key":"order-123-created"}`)); key != "order-123-created" {
+		t.Errorf("Expected dedup key 'order-123-created', got %q", key)
+	}
+	if key := entryDedupKey([]byte(`{"msg":"x"}`)); key != "" {
+		t.Errorf("Expected no dedup key, got %q", key)
+	}
+}
+
+func TestTruncateStackTrace(t *testing.T) {
+	stackTrace := "goroutine 1 [running]:\nfuncA(...)\n\t/src/a.go:10\nfuncB(...)\n\t/src/b.go:20\nfuncC(...)\n\t/src/c.go:30\n"
+
+	truncated := truncateStackTrace(stackTrace, 1)
+	if !strings.Contains(truncated, "funcA") || strings.Contains(truncated, "funcB") {
+		t.Errorf("Expected only the first frame to be kept, got %v", truncated)
+	}
+	if !strings.HasSuffix(truncated, "... 2 frames omitted") {
+		t.Errorf("Expected a marker for the omitted frames, got %v", truncated)
+	}
+
+	if got := truncateStackTrace(stackTrace, 3); got != stackTrace {
+		t.Errorf("Expected the stack trace to be unchanged, got %v", got)
+	}
+	if got := truncateStackTrace(stackTrace, 0); got != stackTrace {
+		t.Errorf("Expected no limit by default, got %v", got)
+	}
+}
+
+func TestSetSystemNameFunc(t *testing.T) {
+	SetSystemNameFunc(func() string { return "node-1/pod-a" })
+	defer SetSystemNameFunc(nil)
+
+	logger := NewLogger(NewLoggerContext(Info), "testApp", io.Discard)
+	if logger.system != "node-1/pod-a" {
+		t.Errorf("Expected system name from the resolver, got %v", logger.system)
+	}
+
+	SetSystemNameFunc(nil)
+	if host, _ := os.Hostname(); getSystemName() != host {
+		t.Errorf("Expected the host name by default, got %v", getSystemName())
+	}
+}
@@ -0,0 +1,132 @@
+package logharbour
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldValidationError describes a field of a LogEntry that failed validation. Entries
+// that fail validation carry the list in their ValidationErrors when they are written to
+// the fallback writer or the last resort writer, so that the most common failures can be
+// found by aggregating on the field and tag.
+type FieldValidationError struct {
+	Field string `json:"field"`           // Name of the field in the Go struct, e.g. "InstanceId".
+	Tag   string `json:"tag"`             // Validation rule that failed, e.g. "required_with".
+	Value any    `json:"value,omitempty"` // Value of the field, if any.
+}
+
+// fieldValidationErrors returns the field failures of a validation error, or nil if err
+// does not come from the validator, such as an error of checkEnv.
+func fieldValidationErrors(err error) []FieldValidationError {
+	var failures validator.ValidationErrors
+	if !errors.As(err, &failures) {
+		return nil
+	}
+	fields := make([]FieldValidationError, len(failures))
+	for i, failure := range failures {
+		fields[i] = FieldValidationError{Field: failure.Field(), Tag: failure.Tag(), Value: failure.Value()}
+	}
+	return fields
+}
+
+// ValidationFailure records a log entry that failed validation together with the validation error.
+type ValidationFailure struct {
+	Entry LogEntry
+	Err   error
+}
+
+// ValidationCollector accumulates validation failures reported by loggers.
+// It is typically attached to a LoggerContext in tests, together with WithDryRun,
+// to check that no code path produces an invalid log entry.
+//
+//	collector := logharbour.NewValidationCollector()
+//	lctx.SetValidationCollector(collector)
+//	// ... run code that logs ...
+//	if failures := collector.Failures(); len(failures) > 0 {
+//		t.Errorf("invalid log entries: %v", failures)
+//	}
+type ValidationCollector struct {
+	failures []ValidationFailure
+	mu       sync.Mutex
+}
+
+// NewValidationCollector creates an empty ValidationCollector.
+func NewValidationCollector() *ValidationCollector {
+	return &ValidationCollector{}
+}
+
+// add records a validation failure.
+func (c *ValidationCollector) add(entry LogEntry, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = append(c.failures, ValidationFailure{Entry: entry, Err: err})
+}
+
+// Failures returns a copy of the validation failures recorded so far.
+func (c *ValidationCollector) Failures() []ValidationFailure {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ValidationFailure(nil), c.failures...)
+}
+
+// Reset discards all recorded validation failures.
+func (c *ValidationCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = nil
+}
+
+// ValidationPolicy classifies validation failures by field. The key is the name of the
+// LogEntry field that failed validation, as in the Go struct, e.g. "Who" or "InstanceId".
+// A field with a rule is soft: when it fails validation, its function is called to fix
+// the entry, typically by setting a default, and the fixed entry is logged as usual.
+// A field without a rule is hard: the entry is handled as invalid, that is, recorded by
+// the context's ValidationCollector, if any, and written to the fallback writer or the
+// last resort writer.
+//
+// An entry is only fixed if all its failures are soft, and it is handled as invalid if
+// it still fails validation after being fixed. Fixed entries are not reported anywhere.
+// If a function panics, the panic is handled like that of other callbacks (see
+// LoggerContext.SetPropagatePanics) and the entry is handled as invalid, unfixed.
+//
+//	policy := logharbour.ValidationPolicy{
+//		"Who": func(e *logharbour.LogEntry) { e.Who = "unknown" },
+//	}
+//	logger = logger.WithValidationPolicy(policy)
+type ValidationPolicy map[string]func(entry *LogEntry)
+
+// WithValidationPolicy returns a new Logger that applies policy to validation failures.
+// Without a policy, which is the default, all failures are hard, as they have always been.
+func (l *Logger) WithValidationPolicy(policy ValidationPolicy) *Logger {
+	newLogger := l.clone()
+	newLogger.validationPolicy = policy
+	if newLogger.tracing {
+		newLogger.traceMutation("WithValidationPolicy", policy)
+	}
+	return newLogger
+}
+
+// fixSoftFailures applies the fixes of the Logger's validation policy to entry if all
+// failures in err are soft. It reports whether the entry was fixed.
+func (l *Logger) fixSoftFailures(entry *LogEntry, err error) bool {
+	var failures validator.ValidationErrors
+	if len(l.validationPolicy) == 0 || !errors.As(err, &failures) {
+		return false
+	}
+	for _, failure := range failures {
+		if l.validationPolicy[failure.Field()] == nil {
+			return false
+		}
+	}
+	fixed := *entry
+	for _, failure := range failures {
+		fix := l.validationPolicy[failure.Field()]
+		if !l.callSafely("validation policy", *entry, func() { fix(&fixed) }) {
+			return false
+		}
+	}
+	*entry = fixed
+	return true
+}
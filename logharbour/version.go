@@ -0,0 +1,56 @@
+package logharbour
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// modulePath is the path of the logharbour module.
+const modulePath = "github.com/remiges-tech/logharbour"
+
+// version is the version reported by Version when the build information of the binary
+// does not give one, as in tests or binaries built without module support. Update it
+// when tagging a release.
+const version = "(devel)"
+
+// Version returns the version of logharbour linked into the binary, as recorded in its
+// build information (see runtime/debug.ReadBuildInfo), such as "v1.2.0", or a pseudo-version
+// for untagged commits. When the build information has no version for logharbour, it
+// returns the package's version constant, "(devel)" between releases.
+func Version() string {
+	return moduleVersion()
+}
+
+// moduleVersion reads the version of logharbour from the build information once.
+var moduleVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+	modules := append([]*debug.Module{&info.Main}, info.Deps...)
+	for _, m := range modules {
+		if m.Path != modulePath {
+			continue
+		}
+		if m.Replace != nil && m.Replace.Version != "" {
+			m = m.Replace
+		}
+		if m.Version != "" && m.Version != "(devel)" {
+			return m.Version
+		}
+	}
+	return version
+})
+
+// WithLibraryVersion returns a new Logger whose entries record, in lh_version, the version
+// of logharbour that produced them, as returned by Version. This helps track down pipeline
+// issues caused by changes to the entry schema, by showing which producers run which
+// version. It is off by default to keep entries small.
+func (l *Logger) WithLibraryVersion() *Logger {
+	newLogger := l.clone()
+	newLogger.libVersion = true
+	if newLogger.tracing {
+		newLogger.traceMutation("WithLibraryVersion", true)
+	}
+	return newLogger
+}